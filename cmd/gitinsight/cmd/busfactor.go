@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/robotism/flagger"
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/robotism/gitinsight/server"
+	"github.com/spf13/cobra"
+)
+
+type BusFactorConfig struct {
+	Repos   string            `mapstructure:"repos" short:"r" description:"comma separated repository urls, defaults to all"`
+	Since   string            `mapstructure:"since" description:"window start, defaults to insight.since"`
+	Until   string            `mapstructure:"until" description:"window end, defaults to now"`
+	Path    string            `mapstructure:"path" description:"path prefix"`
+	Depth   int               `mapstructure:"depth" description:"directory levels below the path prefix" default:"1"`
+	Server  server.Server     `mapstructure:"server" group:"server"`
+	Insight gitinsight.Config `mapstructure:"insight" group:"insight"`
+}
+
+var (
+	busFactorFlagger = flagger.New()
+	busFactorConfig  = &BusFactorConfig{}
+)
+
+var busFactorCmd = &cobra.Command{
+	Use:   "bus-factor",
+	Short: "report the directories whose knowledge is held by few or inactive authors",
+	Run: func(cmd *cobra.Command, args []string) {
+		insight := &busFactorConfig.Insight
+		database := busFactorConfig.Server.Database
+		if err := gitinsight.OpenDb(database.Type, database.Dsn); err != nil {
+			log.Fatalf("failed to open database: %v", err)
+		}
+		defer gitinsight.CloseDb()
+		if err := gitinsight.InitDb(); err != nil {
+			log.Fatalf("failed to init database: %v", err)
+		}
+
+		since := busFactorConfig.Since
+		if since == "" {
+			since = insight.Since
+		}
+		filter := &gitinsight.CommitLogFilter{
+			RepoUrl:        busFactorConfig.Repos,
+			SinceUTC:       since,
+			UntilUTC:       busFactorConfig.Until,
+			SinceTime:      gitinsight.ParseTime(since),
+			UntilTime:      gitinsight.ParseTime(busFactorConfig.Until),
+			ExcludeReverts: insight.ExcludeReverts,
+			Path:           busFactorConfig.Path,
+			Depth:          busFactorConfig.Depth,
+		}
+		risks, err := gitinsight.GetBusFactor(filter, &insight.BusFactor)
+		if err != nil {
+			log.Fatalf("failed to compute bus factor: %v", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "RISK\tREPO\tPATH\tBUS FACTOR\tAUTHORS\tCHANGES\tINACTIVE %\tKEY AUTHORS")
+		for _, risk := range risks {
+			flag := ""
+			if risk.AtRisk {
+				flag = "!"
+			}
+			keyAuthors := make([]string, len(risk.KeyAuthors))
+			for i, author := range risk.KeyAuthors {
+				keyAuthors[i] = fmt.Sprintf("%s %.1f%%", author.Nickname, author.Share)
+				if author.Inactive {
+					keyAuthors[i] += " (inactive)"
+				}
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%.1f\t%s\n",
+				flag, risk.RepoUrl, risk.Path, risk.BusFactor, risk.Authors, risk.Changes,
+				risk.InactiveShare, strings.Join(keyAuthors, ", "))
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+
+	busFactorFlagger.UseFlags(busFactorCmd.Flags())
+	busFactorFlagger.UseConfigFileArgDefault()
+	busFactorFlagger.UseConfigPathDefault()
+	busFactorFlagger.UseConfigTypeYaml()
+	busFactorFlagger.Parse(busFactorConfig)
+
+	rootCmd.AddCommand(busFactorCmd)
+
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+
+	"github.com/robotism/flagger"
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/spf13/cobra"
+)
+
+type ChangelogConfig struct {
+	Repo    string            `mapstructure:"repo" short:"r" description:"configured repository url or local repository path"`
+	From    string            `mapstructure:"from" description:"start tag, branch or hash, exclusive"`
+	To      string            `mapstructure:"to" description:"end tag, branch or hash" default:"HEAD"`
+	Format  string            `mapstructure:"format" description:"markdown or json" default:"markdown"`
+	Output  string            `mapstructure:"output" short:"o" description:"output file, defaults to stdout"`
+	Insight gitinsight.Config `mapstructure:"insight" group:"insight"`
+}
+
+var (
+	changelogFlagger = flagger.New()
+	changelogConfig  = &ChangelogConfig{}
+)
+
+var changelogCmd = &cobra.Command{
+	Use:   "changelog",
+	Short: "generate release notes between two refs",
+	Run: func(cmd *cobra.Command, args []string) {
+		insight := &changelogConfig.Insight
+		repoUrl := changelogConfig.Repo
+		repoPath := repoUrl
+		if gitinsight.IsConfiguredRepo(insight, repoUrl) {
+			repoPath = gitinsight.GetRepoPath(insight, repoUrl)
+		} else {
+			repoUrl = gitinsight.GetRepoRemoteUrl(repoPath)
+		}
+
+		changelog, err := gitinsight.GenerateChangelog(insight, repoPath, repoUrl, changelogConfig.From, changelogConfig.To)
+		if err != nil {
+			log.Fatalf("failed to generate changelog: %v", err)
+		}
+
+		var data []byte
+		switch changelogConfig.Format {
+		case "json":
+			data, err = json.MarshalIndent(changelog, "", "  ")
+			if err != nil {
+				log.Fatalf("failed to marshal changelog: %v", err)
+			}
+		case "markdown", "md":
+			data = []byte(gitinsight.RenderChangelogMarkdown(changelog))
+		default:
+			log.Fatalf("unsupported changelog format: %s", changelogConfig.Format)
+		}
+
+		if changelogConfig.Output == "" {
+			os.Stdout.Write(data)
+			return
+		}
+		if err := os.WriteFile(changelogConfig.Output, data, 0644); err != nil {
+			log.Fatalf("failed to write changelog: %v", err)
+		}
+	},
+}
+
+func init() {
+
+	changelogFlagger.UseFlags(changelogCmd.Flags())
+	changelogFlagger.UseConfigFileArgDefault()
+	changelogFlagger.UseConfigPathDefault()
+	changelogFlagger.UseConfigTypeYaml()
+	changelogFlagger.Parse(changelogConfig)
+
+	rootCmd.AddCommand(changelogCmd)
+
+}
@@ -0,0 +1,244 @@
+package gitinsight
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+)
+
+var changelogTitles = map[string]string{
+	"feat":     "Features",
+	"fix":      "Bug Fixes",
+	"perf":     "Performance Improvements",
+	"refactor": "Code Refactoring",
+	"revert":   "Reverts",
+	"docs":     "Documentation",
+	"style":    "Styles",
+	"test":     "Tests",
+	"build":    "Build System",
+	"ci":       "Continuous Integration",
+	"chore":    "Chores",
+	"":         "Other Changes",
+}
+
+// Changelog is the release notes of the commits reachable from To but not
+// from From.
+type Changelog struct {
+	RepoUrl      string                 `json:"repoUrl"`
+	From         string                 `json:"from"`
+	To           string                 `json:"to"`
+	Date         time.Time              `json:"date"`
+	Breaking     []ChangelogEntry       `json:"breaking"`
+	Sections     []ChangelogSection     `json:"sections"`
+	Contributors []ChangelogContributor `json:"contributors"`
+}
+
+// ChangelogSection holds the commits of one type, grouped by scope.
+type ChangelogSection struct {
+	Type   string           `json:"type"`
+	Title  string           `json:"title"`
+	Scopes []ChangelogScope `json:"scopes"`
+}
+
+type ChangelogScope struct {
+	Scope   string           `json:"scope"`
+	Entries []ChangelogEntry `json:"entries"`
+}
+
+type ChangelogEntry struct {
+	Hash         string         `json:"hash"`
+	Type         string         `json:"type"`
+	Scope        string         `json:"scope"`
+	Subject      string         `json:"subject"`
+	Breaking     bool           `json:"breaking"`
+	BreakingNote string         `json:"breakingNote,omitempty"`
+	Footers      []CommitFooter `json:"footers"`
+	Nickname     string         `json:"nickname"`
+	Date         time.Time      `json:"date"`
+}
+
+type ChangelogContributor struct {
+	Nickname string `json:"nickname"`
+	Commits  int    `json:"commits"`
+}
+
+// GenerateChangelog walks the non-merge commits between two revisions of the
+// repository, tags, branches or hashes. An empty from starts at the root
+// commit, an empty to is HEAD.
+func GenerateChangelog(config *Config, repoPath string, repoUrl string, from string, to string) (*Changelog, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	if to == "" {
+		to = "HEAD"
+	}
+	toCommit, err := resolveCommit(repo, to)
+	if err != nil {
+		return nil, err
+	}
+	var fromCommit *object.Commit
+	if from != "" {
+		fromCommit, err = resolveCommit(repo, from)
+		if err != nil {
+			return nil, err
+		}
+	}
+	cIter, err := newCommitRangeIter(fromCommit, toCommit, CheckUpTodateFilter{})
+	if err != nil {
+		return nil, err
+	}
+	defer cIter.Close()
+
+	identities := NewRepoIdentityResolver(config, repo)
+	entries := make([]ChangelogEntry, 0)
+	commits := make(map[string]int)
+	for {
+		c, err := cIter.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if c.NumParents() > 1 {
+			continue
+		}
+		conventional := ParseConventionalCommit(c.Message, config.CommitTypes)
+		nickname := identities.Nickname(c.Author.Name, c.Author.Email)
+		entry := ChangelogEntry{
+			Hash:     c.Hash.String(),
+			Type:     conventional.Type,
+			Scope:    conventional.Scope,
+			Subject:  conventional.Subject,
+			Breaking: conventional.Breaking,
+			Footers:  conventional.Footers,
+			Nickname: nickname,
+			Date:     c.Author.When.UTC(),
+		}
+		for _, footer := range conventional.Footers {
+			if footer.Token == "BREAKING CHANGE" || footer.Token == "BREAKING-CHANGE" {
+				entry.BreakingNote = footer.Value
+			}
+		}
+		entries = append(entries, entry)
+		commits[nickname]++
+		for _, coAuthor := range ResolveCoAuthors(ParseCoAuthors(c.Message), nickname, identities) {
+			commits[coAuthor.Nickname]++
+		}
+	}
+
+	changelog := &Changelog{
+		RepoUrl:      repoUrl,
+		From:         from,
+		To:           to,
+		Date:         toCommit.Committer.When.UTC(),
+		Breaking:     make([]ChangelogEntry, 0),
+		Sections:     changelogSections(entries, config.CommitTypes),
+		Contributors: make([]ChangelogContributor, 0, len(commits)),
+	}
+	for _, entry := range entries {
+		if entry.Breaking {
+			changelog.Breaking = append(changelog.Breaking, entry)
+		}
+	}
+	for nickname, count := range commits {
+		changelog.Contributors = append(changelog.Contributors, ChangelogContributor{Nickname: nickname, Commits: count})
+	}
+	sort.Slice(changelog.Contributors, func(i, j int) bool {
+		if changelog.Contributors[i].Commits != changelog.Contributors[j].Commits {
+			return changelog.Contributors[i].Commits > changelog.Contributors[j].Commits
+		}
+		return changelog.Contributors[i].Nickname < changelog.Contributors[j].Nickname
+	})
+	return changelog, nil
+}
+
+// changelogSections groups the entries by type in the order of the commit
+// types, commits without a type last, and by scope within a type.
+func changelogSections(entries []ChangelogEntry, types []string) []ChangelogSection {
+	if len(types) == 0 {
+		types = DefaultCommitTypes
+	}
+	sections := make([]ChangelogSection, 0)
+	for _, typ := range append(append([]string{}, types...), "") {
+		typ = strings.ToLower(typ)
+		scopes := make(map[string][]ChangelogEntry)
+		for _, entry := range entries {
+			if entry.Type == typ {
+				scopes[entry.Scope] = append(scopes[entry.Scope], entry)
+			}
+		}
+		if len(scopes) == 0 {
+			continue
+		}
+		title, ok := changelogTitles[typ]
+		if !ok {
+			title = typ
+		}
+		section := ChangelogSection{Type: typ, Title: title, Scopes: make([]ChangelogScope, 0, len(scopes))}
+		for _, scope := range sortedKeys(scopes) {
+			section.Scopes = append(section.Scopes, ChangelogScope{Scope: scope, Entries: scopes[scope]})
+		}
+		sections = append(sections, section)
+	}
+	return sections
+}
+
+func resolveCommit(repo *git.Repository, revision string) (*object.Commit, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve %s: %v", revision, err)
+	}
+	return repo.CommitObject(*hash)
+}
+
+// RenderChangelogMarkdown renders the changelog as Markdown release notes.
+func RenderChangelogMarkdown(changelog *Changelog) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "## %s (%s)\n", changelog.To, changelog.Date.Format("2006-01-02"))
+	if changelog.From != "" {
+		fmt.Fprintf(&sb, "\nChanges since %s.\n", changelog.From)
+	}
+
+	if len(changelog.Breaking) > 0 {
+		sb.WriteString("\n### BREAKING CHANGES\n\n")
+		for _, entry := range changelog.Breaking {
+			writeChangelogEntry(&sb, entry)
+			if entry.BreakingNote != "" {
+				fmt.Fprintf(&sb, "  %s\n", strings.ReplaceAll(entry.BreakingNote, "\n", "\n  "))
+			}
+		}
+	}
+
+	for _, section := range changelog.Sections {
+		fmt.Fprintf(&sb, "\n### %s\n\n", section.Title)
+		for _, scope := range section.Scopes {
+			for _, entry := range scope.Entries {
+				writeChangelogEntry(&sb, entry)
+			}
+		}
+	}
+
+	if len(changelog.Contributors) > 0 {
+		sb.WriteString("\n### Contributors\n\n")
+		for _, contributor := range changelog.Contributors {
+			fmt.Fprintf(&sb, "- %s (%d)\n", contributor.Nickname, contributor.Commits)
+		}
+	}
+	return sb.String()
+}
+
+func writeChangelogEntry(sb *strings.Builder, entry ChangelogEntry) {
+	sb.WriteString("- ")
+	if entry.Scope != "" {
+		fmt.Fprintf(sb, "**%s:** ", entry.Scope)
+	}
+	fmt.Fprintf(sb, "%s (%s)\n", entry.Subject, entry.Hash[:7])
+}
@@ -0,0 +1,60 @@
+package gitinsight
+
+import (
+	"strings"
+)
+
+const (
+	// AttributionPrimary credits the commit author only
+	AttributionPrimary = "primary"
+	// AttributionFull credits the author and every co-author with the whole commit
+	AttributionFull = "full"
+	// AttributionSplit splits the lines of a commit evenly between the author and the co-authors
+	AttributionSplit = "split"
+)
+
+// CoAuthor is a contributor named in a Co-authored-by trailer.
+type CoAuthor struct {
+	Name     string
+	Email    string
+	Nickname string
+}
+
+// ParseCoAuthors returns the identities of the Co-authored-by trailers of a
+// commit message, once per email.
+func ParseCoAuthors(message string) []CoAuthor {
+	coAuthors := make([]CoAuthor, 0)
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(message, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok || !strings.EqualFold(strings.TrimSpace(key), "Co-authored-by") {
+			continue
+		}
+		name, email, _, ok := cutMailmapIdentity(value)
+		if !ok || name == "" && email == "" {
+			continue
+		}
+		if seen[strings.ToLower(email)] {
+			continue
+		}
+		seen[strings.ToLower(email)] = true
+		coAuthors = append(coAuthors, CoAuthor{Name: name, Email: email})
+	}
+	return coAuthors
+}
+
+// ResolveCoAuthors sets the nicknames of the co-authors and drops the ones
+// that resolve to the commit author.
+func ResolveCoAuthors(coAuthors []CoAuthor, authorNickname string, identities *IdentityResolver) []CoAuthor {
+	resolved := make([]CoAuthor, 0, len(coAuthors))
+	seen := map[string]bool{authorNickname: true}
+	for _, coAuthor := range coAuthors {
+		coAuthor.Nickname = identities.Nickname(coAuthor.Name, coAuthor.Email)
+		if seen[coAuthor.Nickname] {
+			continue
+		}
+		seen[coAuthor.Nickname] = true
+		resolved = append(resolved, coAuthor)
+	}
+	return resolved
+}
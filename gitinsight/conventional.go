@@ -0,0 +1,106 @@
+package gitinsight
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// DefaultCommitTypes are the Conventional Commits types recognized when no
+// commit types are configured.
+var DefaultCommitTypes = []string{
+	"feat", "fix", "docs", "style", "refactor", "perf", "test", "build", "ci", "chore", "revert",
+}
+
+// ConventionalCommit is a commit message parsed as a Conventional Commit.
+// Messages without a valid header keep an empty type and their first line as
+// the subject.
+type ConventionalCommit struct {
+	Type     string
+	Scope    string
+	Breaking bool
+	Subject  string
+	Footers  []CommitFooter
+}
+
+// CommitFooter is a git trailer style footer, e.g. "Refs: #123".
+type CommitFooter struct {
+	Token string `json:"token"`
+	Value string `json:"value"`
+}
+
+var (
+	conventionalHeaderRe = regexp.MustCompile(`^([A-Za-z]+)(?:\(([^()]*)\))?(!)?:\s*(.+)$`)
+	conventionalFooterRe = regexp.MustCompile(`^(BREAKING CHANGE|[A-Za-z][\w-]*)(?::\s+(.*)| (#.*))$`)
+)
+
+// ParseConventionalCommit parses the header and footers of a commit message.
+// Only types in types, or DefaultCommitTypes when it is empty, are accepted.
+func ParseConventionalCommit(message string, types []string) ConventionalCommit {
+	if len(types) == 0 {
+		types = DefaultCommitTypes
+	}
+	message = strings.ReplaceAll(strings.TrimSpace(message), "\r\n", "\n")
+	header, body, _ := strings.Cut(message, "\n")
+	header = strings.TrimSpace(strings.ReplaceAll(header, "：", ":"))
+
+	commit := ConventionalCommit{Subject: header}
+	if m := conventionalHeaderRe.FindStringSubmatch(header); m != nil && containsFold(types, m[1]) {
+		commit.Type = strings.ToLower(m[1])
+		commit.Scope = strings.TrimSpace(m[2])
+		commit.Breaking = m[3] == "!"
+		commit.Subject = strings.TrimSpace(m[4])
+	}
+
+	commit.Footers = parseCommitFooters(body)
+	for _, footer := range commit.Footers {
+		if footer.Token == "BREAKING CHANGE" || footer.Token == "BREAKING-CHANGE" {
+			commit.Breaking = true
+		}
+	}
+	return commit
+}
+
+// parseCommitFooters parses the last paragraph of a message body when it
+// starts with a footer. Lines that are not footers continue the value of the
+// previous footer.
+func parseCommitFooters(body string) []CommitFooter {
+	footers := make([]CommitFooter, 0)
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return footers
+	}
+	paragraphs := strings.Split(body, "\n\n")
+	lines := strings.Split(strings.TrimSpace(paragraphs[len(paragraphs)-1]), "\n")
+	if !conventionalFooterRe.MatchString(lines[0]) {
+		return footers
+	}
+	for _, line := range lines {
+		if m := conventionalFooterRe.FindStringSubmatch(line); m != nil {
+			footers = append(footers, CommitFooter{Token: m[1], Value: strings.TrimSpace(m[2] + m[3])})
+			continue
+		}
+		last := &footers[len(footers)-1]
+		last.Value = strings.TrimSpace(last.Value + "\n" + line)
+	}
+	return footers
+}
+
+// MarshalCommitFooters encodes footers for storage, "[]" when there are none.
+func MarshalCommitFooters(footers []CommitFooter) string {
+	if footers == nil {
+		footers = []CommitFooter{}
+	}
+	data, _ := json.Marshal(footers)
+	return string(data)
+}
+
+// UnmarshalCommitFooters decodes stored footers, tolerating empty values of
+// commits stored before footers were parsed.
+func UnmarshalCommitFooters(data string) []CommitFooter {
+	footers := make([]CommitFooter, 0)
+	if data != "" {
+		_ = json.Unmarshal([]byte(data), &footers)
+	}
+	return footers
+}
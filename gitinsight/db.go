@@ -1,11 +1,13 @@
 package gitinsight
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"strings"
 
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
 	"github.com/uptrace/bun/dialect/mysqldialect"
 	"github.com/uptrace/bun/dialect/sqlitedialect"
 	"github.com/uptrace/bun/extra/bundebug"
@@ -18,6 +20,30 @@ func ResetDb(config *Config) error {
 	if err != nil {
 		return err
 	}
+	err = ResetCommitFile()
+	if err != nil {
+		return err
+	}
+	err = ResetCommitCoAuthor()
+	if err != nil {
+		return err
+	}
+	err = ResetCommitChurn()
+	if err != nil {
+		return err
+	}
+	err = ResetBranchState()
+	if err != nil {
+		return err
+	}
+	err = ResetTag()
+	if err != nil {
+		return err
+	}
+	err = ResetOwnership()
+	if err != nil {
+		return err
+	}
 	return nil
 }
 func InitDb() error {
@@ -25,6 +51,30 @@ func InitDb() error {
 	if err != nil {
 		return err
 	}
+	err = InitCommitFile()
+	if err != nil {
+		return err
+	}
+	err = InitCommitCoAuthor()
+	if err != nil {
+		return err
+	}
+	err = InitCommitChurn()
+	if err != nil {
+		return err
+	}
+	err = InitBranchState()
+	if err != nil {
+		return err
+	}
+	err = InitTag()
+	if err != nil {
+		return err
+	}
+	err = InitOwnership()
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -54,6 +104,30 @@ func OpenDb(typ string, dsn string) error {
 	return nil
 }
 
+// addColumnIfNotExists adds a column introduced after the table of the model
+// was created, reporting whether it was added. Existing rows get the default
+// of the definition.
+func addColumnIfNotExists(ctx context.Context, model interface{}, column string, definition string) (bool, error) {
+	_, err := gdb.NewSelect().Model(model).Column(column).Limit(1).Exec(ctx)
+	if err == nil {
+		return false, nil
+	}
+	_, err = gdb.NewAddColumn().Model(model).ColumnExpr(column + " " + definition).Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// textColumnDefinition is a NOT NULL text column for addColumnIfNotExists.
+// MySQL text columns take no default, existing rows get an empty string.
+func textColumnDefinition() string {
+	if gdb.Dialect().Name() == dialect.MySQL {
+		return "TEXT NOT NULL"
+	}
+	return "TEXT NOT NULL DEFAULT ''"
+}
+
 func CloseDb() error {
 	if gdb == nil {
 		return nil
@@ -0,0 +1,94 @@
+package gitinsight
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// BranchStateModel is the analysis watermark of a branch: the head commit
+// that was last analyzed and the since boundary and analysis config it was
+// analyzed with.
+type BranchStateModel struct {
+	bun.BaseModel `bun:"table:branch_state,alias:bs"`
+
+	ID          int64  `json:"id" bun:"id,pk,autoincrement"`
+	RepoUrl     string `json:"repoUrl" bun:",notnull"`
+	BranchName  string `json:"branchName" bun:",notnull"`
+	CommitHash  string `json:"commitHash" bun:",notnull"`
+	Since       string `json:"since" bun:",notnull"`
+	Fingerprint string `json:"fingerprint" bun:",notnull,default:''"`
+
+	UpdatedAt time.Time `json:"updatedAt" bun:",notnull"`
+}
+
+func InitBranchState() error {
+	ctx := context.Background()
+	_, err := gdb.NewCreateTable().Model((*BranchStateModel)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = addColumnIfNotExists(ctx, (*BranchStateModel)(nil), "fingerprint", "VARCHAR(255) NOT NULL DEFAULT ''")
+	if err != nil {
+		return err
+	}
+	_, err = gdb.NewCreateIndex().Model((*BranchStateModel)(nil)).Unique().Index("uk_branch_state").Column("repo_url", "branch_name").IfNotExists().Exec(ctx)
+	return err
+}
+
+func GetBranchState(repoUrl string, branchName string) (*BranchStateModel, error) {
+	if gdb == nil {
+		return nil, errors.New("database not initialized")
+	}
+	ctx := context.Background()
+	state := &BranchStateModel{}
+	err := gdb.NewSelect().Model(state).
+		Where("repo_url = ?", repoUrl).
+		Where("branch_name = ?", branchName).
+		Limit(1).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func SaveBranchState(state *BranchStateModel) error {
+	if gdb == nil {
+		return errors.New("database not initialized")
+	}
+	ctx := context.Background()
+	state.ID = 0
+	state.UpdatedAt = time.Now().UTC()
+	return gdb.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewDelete().Model((*BranchStateModel)(nil)).
+			Where("repo_url = ?", state.RepoUrl).
+			Where("branch_name = ?", state.BranchName).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewInsert().Model(state).Exec(ctx)
+		return err
+	})
+}
+
+func ResetBranchState() error {
+	if gdb == nil {
+		return errors.New("database not initialized")
+	}
+	ctx := context.Background()
+	_, err := gdb.NewDropTable().Model((*BranchStateModel)(nil)).IfExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	log.Println("Reset branch state")
+	return nil
+}
@@ -4,29 +4,39 @@ import (
 	"context"
 	"errors"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
 
 	_ "github.com/uptrace/bun/driver/sqliteshim"
 
 	_ "github.com/go-sql-driver/mysql"
 )
 
-// User model
+// CommitLogModel is one analyzed commit of a repository, stored once no
+// matter how many branches contain it.
 type CommitLogModel struct {
-	bun.BaseModel `bun:"table:commit_log,alias:cl"`
+	bun.BaseModel `bun:"table:commits,alias:cl"`
 
 	ID      int64  `json:"id" bun:"id,pk,autoincrement"`
 	RepoUrl string `json:"repoUrl" bun:",notnull"`
 
-	BranchName  string `json:"branchName" bun:",notnull"`
+	// BranchName is resolved from branch_commits when reading commit logs
+	BranchName  string `json:"branchName" bun:",scanonly"`
 	CommitHash  string `json:"commitHash" bun:",notnull"`
 	IsMerge     bool   `json:"isMerge" bun:",notnull"`
 	Message     string `json:"message" bun:",notnull,type:text"`
 	MessageType string `json:"messageType" bun:",notnull"`
+	Scope       string `json:"scope" bun:",notnull"`
+	Breaking    bool   `json:"breaking" bun:",notnull"`
+	Subject     string `json:"subject" bun:",notnull,type:text"`
+	Footers     string `json:"footers" bun:",notnull,type:text"`
+	IsRevert    bool   `json:"isRevert" bun:",notnull"`
+	RevertsHash string `json:"revertsHash" bun:",notnull"`
 
-	Date time.Time `json:"date" bun:",notnull"`
+	Date          time.Time `json:"date" bun:",notnull"`
 	CommitterDate time.Time `json:"committerDate" bun:",notnull"`
 
 	Additions     int    `json:"additions" bun:",notnull"`
@@ -34,28 +44,74 @@ type CommitLogModel struct {
 	Effectives    int    `json:"effectives" bun:",notnull"`
 	LanguageStats string `json:"languageStats" bun:",notnull,type:text"`
 
+	// Introduced are the lines of a merge that are in none of its parents,
+	// its conflict resolutions
+	Introduced int `json:"introduced" bun:",notnull,default:0"`
+
+	// Fingerprint is the AnalysisFingerprint of the config the commit was
+	// diffed with, empty for the commits stored before it was recorded
+	Fingerprint string `json:"-" bun:",notnull,default:''"`
+
 	AuthorName  string `json:"authorName" bun:",notnull"`
 	AuthorEmail string `json:"authorEmail" bun:",notnull"`
 	Nickname    string `json:"nickname" bun:",notnull"`
 }
 
+// BranchCommitModel records that a commit is reachable from a branch.
+type BranchCommitModel struct {
+	bun.BaseModel `bun:"table:branch_commits,alias:bc"`
+
+	ID         int64  `json:"id" bun:"id,pk,autoincrement"`
+	RepoUrl    string `json:"repoUrl" bun:",notnull"`
+	BranchName string `json:"branchName" bun:",notnull"`
+	CommitHash string `json:"commitHash" bun:",notnull"`
+}
+
+// legacyCommitLogModel is the pre-normalization table that stored one copy
+// of each commit per branch.
+type legacyCommitLogModel struct {
+	bun.BaseModel `bun:"table:commit_log"`
+}
+
 func InitCommit() error {
 	ctx := context.Background()
+
 	// Create table
 	_, err := gdb.NewCreateTable().Model((*CommitLogModel)(nil)).IfNotExists().Exec(ctx)
 	if err != nil {
 		return err
 	}
+	_, err = gdb.NewCreateTable().Model((*BranchCommitModel)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	err = migrateConventionalCommit(ctx)
+	if err != nil {
+		return err
+	}
+	err = migrateRevertCommit(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = addColumnIfNotExists(ctx, (*CommitLogModel)(nil), "introduced", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+	_, err = addColumnIfNotExists(ctx, (*CommitLogModel)(nil), "fingerprint", "VARCHAR(255) NOT NULL DEFAULT ''")
+	if err != nil {
+		return err
+	}
+	err = migrateLegacyCommitLog(ctx)
+	if err != nil {
+		return err
+	}
 
 	indexes := map[string]string{
-		"idx_repo_url":     "repo_url",
-		"idx_branch_name":  "branch_name",
-		"idx_commit_hash":  "commit_hash",
-		"idx_date":         "date",
+		"idx_date":           "date",
 		"idx_committer_date": "committer_date",
-		"idx_author_name":  "author_name",
-		"idx_author_email": "author_email",
-		"idx_nickname":     "nickname",
+		"idx_author_name":    "author_name",
+		"idx_author_email":   "author_email",
+		"idx_nickname":       "nickname",
 	}
 	// Create indexes
 	for indexName, columnName := range indexes {
@@ -64,80 +120,561 @@ func InitCommit() error {
 			return err
 		}
 	}
+	_, err = gdb.NewCreateIndex().Model((*CommitLogModel)(nil)).Unique().Index("uk_commits").Column("repo_url", "commit_hash").IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = gdb.NewCreateIndex().Model((*CommitLogModel)(nil)).Index("idx_reverts_hash").Column("repo_url", "reverts_hash").IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = gdb.NewCreateIndex().Model((*BranchCommitModel)(nil)).Unique().Index("uk_branch_commits").Column("repo_url", "branch_name", "commit_hash").IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = gdb.NewCreateIndex().Model((*BranchCommitModel)(nil)).Index("idx_branch_commits_hash").Column("repo_url", "commit_hash").IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
-func ReplaceCommitLogs(filter *CommitLogFilter, commitLogs []CommitLogModel) (int64, error) {
-	if gdb == nil {
-		return 0, errors.New("database not initialized")
+// migrateLegacyCommitLog moves the commits of the per-branch commit_log
+// table into commits, once per repository and hash, and their branches into
+// branch_commits before dropping it. The branch watermarks stay valid, the
+// commits are the same.
+func migrateLegacyCommitLog(ctx context.Context) error {
+	count, err := gdb.NewSelect().Model((*legacyCommitLogModel)(nil)).Count(ctx)
+	if err != nil {
+		// No legacy table
+		return nil
 	}
-	ctx := context.Background()
-	var rowsAffected int64
-	err := gdb.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
-		query := tx.NewDelete().Model(&CommitLogModel{})
-		filter.DeleteQuery(query)
-		_, err := query.Exec(ctx)
+	log.Printf("Migrating %d rows of the legacy commit_log table\n", count)
+	err = gdb.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.ExecContext(ctx, `INSERT INTO commits (repo_url, commit_hash, is_merge, message, message_type,
+			scope, breaking, subject, footers, is_revert, reverts_hash,
+			date, committer_date, additions, deletions, effectives, language_stats, introduced, author_name, author_email, nickname)
+			SELECT l.repo_url, l.commit_hash, MAX(l.is_merge), MAX(l.message), MAX(l.message_type),
+			'', FALSE, '', '', FALSE, '',
+			MAX(l.date), MAX(l.committer_date), MAX(l.additions), MAX(l.deletions), MAX(l.effectives), MAX(l.language_stats), 0,
+			MAX(l.author_name), MAX(l.author_email), MAX(l.nickname)
+			FROM commit_log AS l
+			WHERE NOT EXISTS (SELECT 1 FROM commits AS c WHERE c.repo_url = l.repo_url AND c.commit_hash = l.commit_hash)
+			GROUP BY l.repo_url, l.commit_hash`)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO branch_commits (repo_url, branch_name, commit_hash)
+			SELECT DISTINCT l.repo_url, l.branch_name, l.commit_hash
+			FROM commit_log AS l
+			WHERE NOT EXISTS (SELECT 1 FROM branch_commits AS bc
+				WHERE bc.repo_url = l.repo_url AND bc.branch_name = l.branch_name AND bc.commit_hash = l.commit_hash)`)
 		if err != nil {
 			return err
 		}
+		_, err = tx.NewDropTable().Model((*legacyCommitLogModel)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	// the legacy table has none of the columns parsed from the message, the
+	// configured commit types apply on the next sync
+	_, err = parseConventionalCommits(ctx, "", nil)
+	if err != nil {
+		return err
+	}
+	_, err = parseRevertCommits(ctx, "", nil)
+	return err
+}
 
-		const commitLogLimit = 1000
+// migrateConventionalCommit adds the Conventional Commits columns and parses
+// the messages of the commits stored before them.
+func migrateConventionalCommit(ctx context.Context) error {
+	columns := []struct {
+		name       string
+		definition string
+	}{
+		{"scope", "VARCHAR(255) NOT NULL DEFAULT ''"},
+		{"breaking", "BOOLEAN NOT NULL DEFAULT FALSE"},
+		{"subject", textColumnDefinition()},
+		{"footers", textColumnDefinition()},
+	}
+	migrated := false
+	for _, column := range columns {
+		added, err := addColumnIfNotExists(ctx, (*CommitLogModel)(nil), column.name, column.definition)
+		if err != nil {
+			return err
+		}
+		migrated = migrated || added
+	}
+	if !migrated {
+		return nil
+	}
+	_, err := parseConventionalCommits(ctx, "", nil)
+	return err
+}
 
-		var rowsAffected int64
-		for i := 0; i < len(commitLogs); i += commitLogLimit {
-			end := i + commitLogLimit
-			if end > len(commitLogs) {
-				end = len(commitLogs)
+// parseConventionalCommits parses the messages of the stored commits of a
+// repository, of every repository when repoUrl is empty, into their
+// Conventional Commits columns with the valid types, the default ones when
+// types is empty. It returns the number of commits whose columns changed.
+func parseConventionalCommits(ctx context.Context, repoUrl string, types []string) (int, error) {
+	var commitLogs []CommitLogModel
+	query := gdb.NewSelect().Model(&commitLogs).Column("id", "message", "message_type", "scope", "breaking", "subject", "footers")
+	if repoUrl != "" {
+		query.Where("repo_url = ?", repoUrl)
+	}
+	err := query.Scan(ctx)
+	if err != nil {
+		return 0, err
+	}
+	updated := 0
+	err = gdb.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for _, commitLog := range commitLogs {
+			conventional := ParseConventionalCommit(commitLog.Message, types)
+			footers := MarshalCommitFooters(conventional.Footers)
+			if conventional.Type == commitLog.MessageType && conventional.Scope == commitLog.Scope &&
+				conventional.Breaking == commitLog.Breaking && conventional.Subject == commitLog.Subject &&
+				footers == commitLog.Footers {
+				continue
 			}
-			segment := commitLogs[i:end]
-			result, err := tx.NewInsert().Model(&segment).Exec(ctx)
+			_, err := tx.NewUpdate().Model((*CommitLogModel)(nil)).
+				Set("message_type = ?", conventional.Type).
+				Set("scope = ?", conventional.Scope).
+				Set("breaking = ?", conventional.Breaking).
+				Set("subject = ?", conventional.Subject).
+				Set("footers = ?", footers).
+				Where("id = ?", commitLog.ID).
+				Exec(ctx)
 			if err != nil {
 				return err
 			}
-			rows, err := result.RowsAffected()
+			updated++
+		}
+		return nil
+	})
+	return updated, err
+}
+
+// RefreshCommitMessages parses the messages of the stored commits of a
+// repository again with the configured commit types, so the commits migrated
+// or analyzed before a change of the types are classified as the new ones,
+// and expands the abbreviated hashes of their reverts that the stored commits
+// resolve. It returns the number of updated commits.
+func RefreshCommitMessages(repoUrl string, types []string) (int, error) {
+	if gdb == nil {
+		return 0, errors.New("database not initialized")
+	}
+	ctx := context.Background()
+	updated, err := parseConventionalCommits(ctx, repoUrl, types)
+	if err != nil {
+		return updated, err
+	}
+	reverts, err := parseRevertCommits(ctx, repoUrl, types)
+	return updated + reverts, err
+}
+
+// migrateRevertCommit adds the revert columns and links the commits stored
+// before them to the commits they revert.
+func migrateRevertCommit(ctx context.Context) error {
+	added, err := addColumnIfNotExists(ctx, (*CommitLogModel)(nil), "is_revert", "BOOLEAN NOT NULL DEFAULT FALSE")
+	if err != nil {
+		return err
+	}
+	_, err = addColumnIfNotExists(ctx, (*CommitLogModel)(nil), "reverts_hash", "VARCHAR(255) NOT NULL DEFAULT ''")
+	if err != nil {
+		return err
+	}
+	if !added {
+		return nil
+	}
+	_, err = parseRevertCommits(ctx, "", nil)
+	return err
+}
+
+// parseRevertCommits links the stored commits of a repository, of every
+// repository when repoUrl is empty, that are reverts to the commits they
+// revert, recognizing Conventional Commits reverts with the valid types. It
+// returns the number of commits whose revert columns changed.
+func parseRevertCommits(ctx context.Context, repoUrl string, types []string) (int, error) {
+	var commitLogs []CommitLogModel
+	query := gdb.NewSelect().Model(&commitLogs).Column("id", "repo_url", "message", "is_revert", "reverts_hash")
+	if repoUrl != "" {
+		query.Where("repo_url = ?", repoUrl)
+	}
+	err := query.Scan(ctx)
+	if err != nil {
+		return 0, err
+	}
+	updated := 0
+	err = gdb.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for _, commitLog := range commitLogs {
+			isRevert, revertsHash := ParseRevert(commitLog.Message, types)
+			if revertsHash != "" && len(revertsHash) < 40 {
+				if strings.HasPrefix(commitLog.RevertsHash, revertsHash) {
+					// already expanded, by the analysis or a previous refresh
+					revertsHash = commitLog.RevertsHash
+				} else {
+					revertsHash, err = expandCommitHash(ctx, tx, commitLog.RepoUrl, revertsHash)
+					if err != nil {
+						return err
+					}
+				}
+			}
+			if isRevert == commitLog.IsRevert && revertsHash == commitLog.RevertsHash {
+				continue
+			}
+			_, err := tx.NewUpdate().Model((*CommitLogModel)(nil)).
+				Set("is_revert = ?", isRevert).
+				Set("reverts_hash = ?", revertsHash).
+				Where("id = ?", commitLog.ID).
+				Exec(ctx)
 			if err != nil {
 				return err
 			}
-			rowsAffected += rows
+			updated++
 		}
 		return nil
 	})
+	return updated, err
+}
+
+// expandCommitHash expands an abbreviated commit hash to the only stored
+// commit of the repository it prefixes, keeping it as it is when none or
+// several do.
+func expandCommitHash(ctx context.Context, tx bun.Tx, repoUrl string, hash string) (string, error) {
+	var hashes []string
+	err := tx.NewSelect().Model((*CommitLogModel)(nil)).
+		Column("commit_hash").
+		Where("repo_url = ?", repoUrl).
+		Where("commit_hash LIKE ?", hash+"%").
+		Limit(2).
+		Scan(ctx, &hashes)
+	if err != nil {
+		return "", err
+	}
+	if len(hashes) != 1 {
+		return hash, nil
+	}
+	return hashes[0], nil
+}
+
+// insertIgnore skips rows that collide with a unique index, so concurrent
+// branch analyses of the same repository can store a shared commit.
+func insertIgnore(query *bun.InsertQuery) *bun.InsertQuery {
+	if gdb.Dialect().Name() == dialect.MySQL {
+		return query.Ignore()
+	}
+	return query.On("CONFLICT DO NOTHING")
+}
+
+// CommitLogBatch is the output of a branch analysis ready to be stored: the
+// newly analyzed commits with their details and the branch membership.
+type CommitLogBatch struct {
+	RepoUrl    string
+	BranchName string
+
+	CommitLogs      []CommitLogModel
+	CommitFiles     []CommitFileModel
+	CommitCoAuthors []CommitCoAuthorModel
+	CommitChurn     []CommitChurnModel
+	BranchCommits   []BranchCommitModel
+}
+
+func insertSegments[T any](ctx context.Context, tx bun.Tx, rows []T) (int64, error) {
+	const commitLogLimit = 1000
+
+	var rowsAffected int64
+	for i := 0; i < len(rows); i += commitLogLimit {
+		end := i + commitLogLimit
+		if end > len(rows) {
+			end = len(rows)
+		}
+		segment := rows[i:end]
+		result, err := insertIgnore(tx.NewInsert().Model(&segment)).Exec(ctx)
+		if err != nil {
+			return 0, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		rowsAffected += affected
+	}
+	return rowsAffected, nil
+}
+
+// withoutStored drops the commits another branch stored in the meantime with
+// the same analysis, together with their details, so a shared commit is only
+// stored once. The commits stored with another analysis are removed to be
+// replaced by the batch.
+func (batch *CommitLogBatch) withoutStored(ctx context.Context, tx bun.Tx) error {
+	if len(batch.CommitLogs) == 0 {
+		return nil
+	}
+	hashes := make([]string, len(batch.CommitLogs))
+	for i, commitLog := range batch.CommitLogs {
+		hashes[i] = commitLog.CommitHash
+	}
+	var stored []CommitLogModel
+	err := tx.NewSelect().Model(&stored).
+		Column("commit_hash", "fingerprint").
+		Where("repo_url = ?", batch.RepoUrl).
+		Where("commit_hash IN (?)", bun.In(hashes)).
+		Scan(ctx)
+	if err != nil {
+		return err
+	}
+	if len(stored) == 0 {
+		return nil
+	}
+	fingerprints := make(map[string]string, len(batch.CommitLogs))
+	for _, row := range batch.CommitLogs {
+		fingerprints[row.CommitHash] = row.Fingerprint
+	}
+	isStored := make(map[string]bool, len(stored))
+	stale := make([]string, 0)
+	for _, row := range stored {
+		if row.Fingerprint == fingerprints[row.CommitHash] {
+			isStored[row.CommitHash] = true
+		} else {
+			stale = append(stale, row.CommitHash)
+		}
+	}
+	err = deleteCommits(ctx, tx, batch.RepoUrl, stale)
+	if err != nil {
+		return err
+	}
+	if len(isStored) == 0 {
+		return nil
+	}
+
+	commitLogs := make([]CommitLogModel, 0, len(batch.CommitLogs))
+	for _, row := range batch.CommitLogs {
+		if !isStored[row.CommitHash] {
+			commitLogs = append(commitLogs, row)
+		}
+	}
+	batch.CommitLogs = commitLogs
+
+	commitFiles := make([]CommitFileModel, 0, len(batch.CommitFiles))
+	for _, row := range batch.CommitFiles {
+		if !isStored[row.CommitHash] {
+			commitFiles = append(commitFiles, row)
+		}
+	}
+	batch.CommitFiles = commitFiles
+
+	commitCoAuthors := make([]CommitCoAuthorModel, 0, len(batch.CommitCoAuthors))
+	for _, row := range batch.CommitCoAuthors {
+		if !isStored[row.CommitHash] {
+			commitCoAuthors = append(commitCoAuthors, row)
+		}
+	}
+	batch.CommitCoAuthors = commitCoAuthors
+
+	commitChurn := make([]CommitChurnModel, 0, len(batch.CommitChurn))
+	for _, row := range batch.CommitChurn {
+		if !isStored[row.CommitHash] {
+			commitChurn = append(commitChurn, row)
+		}
+	}
+	batch.CommitChurn = commitChurn
+	return nil
+}
+
+// deleteCommits removes the stored commits of a repository with the given
+// hashes together with their details, keeping their branch membership.
+func deleteCommits(ctx context.Context, tx bun.Tx, repoUrl string, hashes []string) error {
+	if len(hashes) == 0 {
+		return nil
+	}
+	for _, model := range []interface{}{
+		(*CommitLogModel)(nil),
+		(*CommitFileModel)(nil),
+		(*CommitCoAuthorModel)(nil),
+		(*CommitChurnModel)(nil),
+	} {
+		_, err := tx.NewDelete().Model(model).
+			Where("repo_url = ?", repoUrl).
+			Where("commit_hash IN (?)", bun.In(hashes)).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func insertCommitLogBatch(ctx context.Context, tx bun.Tx, batch *CommitLogBatch) (int64, error) {
+	err := batch.withoutStored(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := insertSegments(ctx, tx, batch.CommitLogs)
+	if err != nil {
+		return 0, err
+	}
+	_, err = insertSegments(ctx, tx, batch.CommitFiles)
+	if err != nil {
+		return 0, err
+	}
+	_, err = insertSegments(ctx, tx, batch.CommitCoAuthors)
+	if err != nil {
+		return 0, err
+	}
+	_, err = insertSegments(ctx, tx, batch.CommitChurn)
+	if err != nil {
+		return 0, err
+	}
+	_, err = insertSegments(ctx, tx, batch.BranchCommits)
 	if err != nil {
 		return 0, err
 	}
 	return rowsAffected, nil
 }
 
-func AddCommitLogs(commitLogs []CommitLogModel) (int64, error) {
+// ReplaceBranchCommitLogs replaces the membership of a branch and stores the
+// commits that were not analyzed before. Commits no longer reachable from any
+// branch of the repository are kept until DeleteOrphanCommits, as another
+// branch analyzed at the same time may link them without storing them again.
+func ReplaceBranchCommitLogs(batch *CommitLogBatch) (int64, error) {
 	if gdb == nil {
 		return 0, errors.New("database not initialized")
 	}
-	if len(commitLogs) == 0 {
-		return 0, nil
-	}
 	ctx := context.Background()
+	var rowsAffected int64
+	err := gdb.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewDelete().Model((*BranchCommitModel)(nil)).
+			Where("repo_url = ?", batch.RepoUrl).
+			Where("branch_name = ?", batch.BranchName).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
 
-	const commitLogLimit = 1000
+		rowsAffected, err = insertCommitLogBatch(ctx, tx, batch)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected, nil
+}
 
+// DeleteStaleBranches removes the membership, the watermark and the
+// ownership snapshot of the branches of a repository other than branchNames,
+// the branches it still has, and the commits no remaining branch reaches. It
+// returns the number of removed memberships.
+func DeleteStaleBranches(repoUrl string, branchNames []string) (int64, error) {
+	if gdb == nil {
+		return 0, errors.New("database not initialized")
+	}
+	ctx := context.Background()
 	var rowsAffected int64
 	err := gdb.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
-		for i := 0; i < len(commitLogs); i += commitLogLimit {
-			end := i + commitLogLimit
-			if end > len(commitLogs) {
-				end = len(commitLogs)
-			}
-			segment := commitLogs[i:end]
-			result, err := tx.NewInsert().Model(&segment).Exec(ctx)
-			if err != nil {
-				return err
-			}
-			rows, err := result.RowsAffected()
-			if err != nil {
-				return err
-			}
-			rowsAffected += rows
+		query := tx.NewDelete().Model((*BranchCommitModel)(nil)).Where("repo_url = ?", repoUrl)
+		stateQuery := tx.NewDelete().Model((*BranchStateModel)(nil)).Where("repo_url = ?", repoUrl)
+		if len(branchNames) > 0 {
+			query.Where("branch_name NOT IN (?)", bun.In(branchNames))
+			stateQuery.Where("branch_name NOT IN (?)", bun.In(branchNames))
 		}
-		return nil
+		result, err := query.Exec(ctx)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err = result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		_, err = stateQuery.Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = deleteStaleOwnership(ctx, tx, repoUrl, branchNames)
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return nil
+		}
+		_, err = deleteOrphanCommits(ctx, tx, repoUrl)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected, nil
+}
+
+// DeleteOrphanCommits removes the commits of a repository that no branch
+// reaches any more, once none of its branches is being analyzed. It returns
+// the number of removed commits.
+func DeleteOrphanCommits(repoUrl string) (int64, error) {
+	if gdb == nil {
+		return 0, errors.New("database not initialized")
+	}
+	ctx := context.Background()
+	var rowsAffected int64
+	err := gdb.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		rowsAffected, err = deleteOrphanCommits(ctx, tx, repoUrl)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected, nil
+}
+
+// deleteOrphanCommits removes the commits of a repository that no branch
+// reaches, together with their details.
+func deleteOrphanCommits(ctx context.Context, tx bun.Tx, repoUrl string) (int64, error) {
+	result, err := tx.NewDelete().Model((*CommitLogModel)(nil)).
+		Where("repo_url = ?", repoUrl).
+		Where("NOT EXISTS (?)", tx.NewSelect().Model((*BranchCommitModel)(nil)).
+			ColumnExpr("1").
+			Where("bc.repo_url = cl.repo_url").
+			Where("bc.commit_hash = cl.commit_hash")).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	err = deleteOrphanCommitFiles(ctx, tx, repoUrl)
+	if err != nil {
+		return 0, err
+	}
+	err = deleteOrphanCommitCoAuthors(ctx, tx, repoUrl)
+	if err != nil {
+		return 0, err
+	}
+	err = deleteOrphanCommitChurn(ctx, tx, repoUrl)
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected, nil
+}
+
+// AddBranchCommitLogs appends commits and branch memberships.
+func AddBranchCommitLogs(batch *CommitLogBatch) (int64, error) {
+	if gdb == nil {
+		return 0, errors.New("database not initialized")
+	}
+	if len(batch.CommitLogs) == 0 && len(batch.BranchCommits) == 0 {
+		return 0, nil
+	}
+	ctx := context.Background()
+
+	var rowsAffected int64
+	err := gdb.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		rowsAffected, err = insertCommitLogBatch(ctx, tx, batch)
+		return err
 	})
 	return rowsAffected, err
 }
@@ -158,13 +695,149 @@ func GetCommitLogs(filter *CommitLogFilter) ([]CommitLogModel, error) {
 	}
 	ctx := context.Background()
 	var commitLogs []CommitLogModel = make([]CommitLogModel, 0)
-	query := gdb.NewSelect().Model(&CommitLogModel{})
+
+	branchQuery := gdb.NewSelect().Model((*BranchCommitModel)(nil)).
+		ColumnExpr("MIN(bc.branch_name)").
+		Where("bc.repo_url = cl.repo_url").
+		Where("bc.commit_hash = cl.commit_hash")
+	filter.BranchQuery(branchQuery)
+
+	query := gdb.NewSelect().Model(&CommitLogModel{}).
+		ColumnExpr("cl.*").
+		ColumnExpr("(?) AS branch_name", branchQuery)
 	filter.SelectQuery(query)
 	query.Order("committer_date DESC").Offset(filter.Offset).Limit(filter.Limit)
 	err := query.Scan(ctx, &commitLogs)
 	return commitLogs, err
 }
 
+// GetAnalyzedCommitHashes returns the hashes of the commits of a repository
+// that were analyzed with the fingerprint.
+func GetAnalyzedCommitHashes(repoUrl string, fingerprint string) (map[string]bool, error) {
+	if gdb == nil {
+		return nil, errors.New("database not initialized")
+	}
+	ctx := context.Background()
+	var hashes []string
+	err := gdb.NewSelect().Model((*CommitLogModel)(nil)).
+		Column("commit_hash").
+		Where("repo_url = ?", repoUrl).
+		Where("fingerprint = ?", fingerprint).
+		Scan(ctx, &hashes)
+	if err != nil {
+		return nil, err
+	}
+	analyzed := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		analyzed[hash] = true
+	}
+	return analyzed, nil
+}
+
+// AdoptCommitFingerprint records the fingerprint on the commits of a
+// repository stored before fingerprints were, taking them as analyzed with
+// the current config.
+func AdoptCommitFingerprint(repoUrl string, fingerprint string) (int64, error) {
+	if gdb == nil {
+		return 0, errors.New("database not initialized")
+	}
+	ctx := context.Background()
+	result, err := gdb.NewUpdate().Model((*CommitLogModel)(nil)).
+		Set("fingerprint = ?", fingerprint).
+		Where("repo_url = ?", repoUrl).
+		Where("fingerprint = ''").
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetCommitHashes returns the hashes of the commit logs matching the filter.
+func GetCommitHashes(filter *CommitLogFilter) (map[string]bool, error) {
+	if gdb == nil {
+		return nil, errors.New("database not initialized")
+	}
+	ctx := context.Background()
+	var hashes []string
+	query := gdb.NewSelect().Model(&CommitLogModel{}).Column("commit_hash")
+	filter.SelectQuery(query)
+	err := query.Scan(ctx, &hashes)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		known[hash] = true
+	}
+	return known, nil
+}
+
+// commitIdentity is a distinct author identity of the stored commits.
+type commitIdentity struct {
+	AuthorName  string `bun:"author_name"`
+	AuthorEmail string `bun:"author_email"`
+	Nickname    string `bun:"nickname"`
+}
+
+// RefreshNicknames recomputes the nicknames of the stored commits, co-authors
+// and owned lines of a repository from their name and email, so mailmap and
+// author changes apply without re-walking the history. It returns the number
+// of updated rows.
+func RefreshNicknames(repoUrl string, identities *IdentityResolver) (int, error) {
+	if gdb == nil {
+		return 0, errors.New("database not initialized")
+	}
+	ctx := context.Background()
+	updated := 0
+	err := gdb.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for _, model := range []interface{}{(*CommitLogModel)(nil), (*CommitCoAuthorModel)(nil), (*OwnershipModel)(nil)} {
+			affected, err := refreshIdentityNicknames(ctx, tx, model, repoUrl, identities)
+			if err != nil {
+				return err
+			}
+			updated += affected
+		}
+		return nil
+	})
+	return updated, err
+}
+
+func refreshIdentityNicknames(ctx context.Context, tx bun.Tx, model interface{}, repoUrl string, identities *IdentityResolver) (int, error) {
+	var rows []commitIdentity
+	err := tx.NewSelect().Model(model).
+		Distinct().
+		Column("author_name", "author_email", "nickname").
+		Where("repo_url = ?", repoUrl).
+		Scan(ctx, &rows)
+	if err != nil {
+		return 0, err
+	}
+	updated := 0
+	for _, row := range rows {
+		nickname := identities.Nickname(row.AuthorName, row.AuthorEmail)
+		if nickname == row.Nickname {
+			continue
+		}
+		res, err := tx.NewUpdate().Model(model).
+			Set("nickname = ?", nickname).
+			Where("repo_url = ?", repoUrl).
+			Where("author_name = ?", row.AuthorName).
+			Where("author_email = ?", row.AuthorEmail).
+			Where("nickname = ?", row.Nickname).
+			Exec(ctx)
+		if err != nil {
+			return 0, err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		updated += int(affected)
+	}
+	return updated, nil
+}
+
 func ResetCommit() error {
 	if gdb == nil {
 		return errors.New("database not initialized")
@@ -174,6 +847,14 @@ func ResetCommit() error {
 	if err != nil {
 		return err
 	}
+	_, err = gdb.NewDropTable().Model(&BranchCommitModel{}).IfExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = gdb.NewDropTable().Model((*legacyCommitLogModel)(nil)).IfExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
 	log.Println("Reset commit log")
 	return nil
 }
@@ -0,0 +1,91 @@
+package gitinsight
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/uptrace/bun"
+)
+
+// CommitChurnModel is the number of lines of an earlier commit, the origin,
+// that a commit rewrote within the churn window.
+type CommitChurnModel struct {
+	bun.BaseModel `bun:"table:commit_churn,alias:ch"`
+
+	ID         int64  `json:"id" bun:"id,pk,autoincrement"`
+	RepoUrl    string `json:"repoUrl" bun:",notnull"`
+	CommitHash string `json:"commitHash" bun:",notnull"`
+	OriginHash string `json:"originHash" bun:",notnull"`
+	Lines      int    `json:"lines" bun:",notnull"`
+}
+
+func InitCommitChurn() error {
+	ctx := context.Background()
+	_, err := gdb.NewCreateTable().Model((*CommitChurnModel)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = gdb.NewCreateIndex().Model((*CommitChurnModel)(nil)).Index("idx_commit_churn_hash").Column("repo_url", "commit_hash").IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = gdb.NewCreateIndex().Model((*CommitChurnModel)(nil)).Index("idx_commit_churn_origin").Column("repo_url", "origin_hash").IfNotExists().Exec(ctx)
+	return err
+}
+
+func ToCommitChurnModels(repoUrl string, commitLogs []CommitLog) []CommitChurnModel {
+	commitChurnModels := make([]CommitChurnModel, 0)
+	for _, commitLog := range commitLogs {
+		for _, churn := range commitLog.Churn {
+			commitChurnModels = append(commitChurnModels, CommitChurnModel{
+				RepoUrl:    repoUrl,
+				CommitHash: commitLog.Hash,
+				OriginHash: churn.OriginHash,
+				Lines:      churn.Lines,
+			})
+		}
+	}
+	return commitChurnModels
+}
+
+// deleteOrphanCommitChurn removes the churn of commits that are no longer
+// stored for the repository.
+func deleteOrphanCommitChurn(ctx context.Context, tx bun.Tx, repoUrl string) error {
+	_, err := tx.NewDelete().Model((*CommitChurnModel)(nil)).
+		Where("repo_url = ?", repoUrl).
+		Where("NOT EXISTS (?)", tx.NewSelect().Model((*CommitLogModel)(nil)).
+			ColumnExpr("1").
+			Where("cl.repo_url = ch.repo_url").
+			Where("cl.commit_hash = ch.commit_hash")).
+		Exec(ctx)
+	return err
+}
+
+// churnQuery sums the lines of the commit cl rewritten by later commits, only
+// the ones of the credited nickname when rewriter is set.
+func churnQuery(rewriter string) *bun.SelectQuery {
+	query := gdb.NewSelect().
+		TableExpr("commit_churn AS ch").
+		ColumnExpr("SUM(ch.lines)").
+		Where("ch.repo_url = cl.repo_url").
+		Where("ch.origin_hash = cl.commit_hash")
+	if rewriter != "" {
+		query.Join("JOIN commits AS rw ON rw.repo_url = ch.repo_url AND rw.commit_hash = ch.commit_hash").
+			Where("rw.nickname = " + rewriter)
+	}
+	return query
+}
+
+func ResetCommitChurn() error {
+	if gdb == nil {
+		return errors.New("database not initialized")
+	}
+	ctx := context.Background()
+	_, err := gdb.NewDropTable().Model((*CommitChurnModel)(nil)).IfExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	log.Println("Reset commit churn")
+	return nil
+}
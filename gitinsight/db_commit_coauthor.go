@@ -0,0 +1,78 @@
+package gitinsight
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/uptrace/bun"
+)
+
+// CommitCoAuthorModel is a co-author of a commit besides its author.
+type CommitCoAuthorModel struct {
+	bun.BaseModel `bun:"table:commit_coauthors,alias:ca"`
+
+	ID         int64  `json:"id" bun:"id,pk,autoincrement"`
+	RepoUrl    string `json:"repoUrl" bun:",notnull"`
+	CommitHash string `json:"commitHash" bun:",notnull"`
+
+	AuthorName  string `json:"authorName" bun:",notnull"`
+	AuthorEmail string `json:"authorEmail" bun:",notnull"`
+	Nickname    string `json:"nickname" bun:",notnull"`
+}
+
+func InitCommitCoAuthor() error {
+	ctx := context.Background()
+	_, err := gdb.NewCreateTable().Model((*CommitCoAuthorModel)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = gdb.NewCreateIndex().Model((*CommitCoAuthorModel)(nil)).Index("idx_commit_coauthors_hash").Column("repo_url", "commit_hash").IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = gdb.NewCreateIndex().Model((*CommitCoAuthorModel)(nil)).Index("idx_commit_coauthors_nickname").Column("nickname").IfNotExists().Exec(ctx)
+	return err
+}
+
+func ToCommitCoAuthorModels(repoUrl string, commitLogs []CommitLog) []CommitCoAuthorModel {
+	commitCoAuthorModels := make([]CommitCoAuthorModel, 0)
+	for _, commitLog := range commitLogs {
+		for _, coAuthor := range commitLog.CoAuthors {
+			commitCoAuthorModels = append(commitCoAuthorModels, CommitCoAuthorModel{
+				RepoUrl:     repoUrl,
+				CommitHash:  commitLog.Hash,
+				AuthorName:  coAuthor.Name,
+				AuthorEmail: coAuthor.Email,
+				Nickname:    coAuthor.Nickname,
+			})
+		}
+	}
+	return commitCoAuthorModels
+}
+
+// deleteOrphanCommitCoAuthors removes the co-authors of commits that are no
+// longer stored for the repository.
+func deleteOrphanCommitCoAuthors(ctx context.Context, tx bun.Tx, repoUrl string) error {
+	_, err := tx.NewDelete().Model((*CommitCoAuthorModel)(nil)).
+		Where("repo_url = ?", repoUrl).
+		Where("NOT EXISTS (?)", tx.NewSelect().Model((*CommitLogModel)(nil)).
+			ColumnExpr("1").
+			Where("cl.repo_url = ca.repo_url").
+			Where("cl.commit_hash = ca.commit_hash")).
+		Exec(ctx)
+	return err
+}
+
+func ResetCommitCoAuthor() error {
+	if gdb == nil {
+		return errors.New("database not initialized")
+	}
+	ctx := context.Background()
+	_, err := gdb.NewDropTable().Model((*CommitCoAuthorModel)(nil)).IfExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	log.Println("Reset commit co-authors")
+	return nil
+}
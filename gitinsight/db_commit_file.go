@@ -0,0 +1,131 @@
+package gitinsight
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/uptrace/bun"
+)
+
+// CommitFileModel is the change of one file in a commit.
+type CommitFileModel struct {
+	bun.BaseModel `bun:"table:commit_files,alias:cf"`
+
+	ID         int64  `json:"id" bun:"id,pk,autoincrement"`
+	RepoUrl    string `json:"repoUrl" bun:",notnull"`
+	CommitHash string `json:"commitHash" bun:",notnull"`
+
+	Path       string `json:"path" bun:",notnull"`
+	OldPath    string `json:"oldPath" bun:",notnull"`
+	Language   string `json:"language" bun:",notnull,default:''"`
+	Additions  int    `json:"additions" bun:",notnull"`
+	Deletions  int    `json:"deletions" bun:",notnull"`
+	ChangeType string `json:"changeType" bun:",notnull"`
+	IsBinary   bool   `json:"isBinary" bun:",notnull"`
+}
+
+func InitCommitFile() error {
+	ctx := context.Background()
+	_, err := gdb.NewCreateTable().Model((*CommitFileModel)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	added, err := addColumnIfNotExists(ctx, (*CommitFileModel)(nil), "language", "VARCHAR(255) NOT NULL DEFAULT ''")
+	if err != nil {
+		return err
+	}
+	if added {
+		err = backfillCommitFileLanguages(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = gdb.NewCreateIndex().Model((*CommitFileModel)(nil)).Index("idx_commit_files_hash").Column("repo_url", "commit_hash").IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = gdb.NewCreateIndex().Model((*CommitFileModel)(nil)).Index("idx_commit_files_path").Column("path").IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = gdb.NewCreateIndex().Model((*CommitFileModel)(nil)).Index("idx_commit_files_language").Column("language").IfNotExists().Exec(ctx)
+	return err
+}
+
+// backfillCommitFileLanguages classifies the file changes stored before the
+// language column existed. Only the path is known, so shebang scripts and
+// headers get their extension based language until they are re-analyzed.
+func backfillCommitFileLanguages(ctx context.Context) error {
+	var paths []string
+	err := gdb.NewSelect().Model((*CommitFileModel)(nil)).
+		Distinct().
+		Column("path").
+		Where("language = ''").
+		Scan(ctx, &paths)
+	if err != nil {
+		return err
+	}
+	log.Printf("Classifying languages of %d stored file paths\n", len(paths))
+	var classifier *LanguageClassifier
+	noContent := func() string { return "" }
+	return gdb.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for _, path := range paths {
+			_, err := tx.NewUpdate().Model((*CommitFileModel)(nil)).
+				Set("language = ?", classifier.Classify(path, noContent)).
+				Where("path = ?", path).
+				Where("language = ''").
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func ToCommitFileModels(repoUrl string, commitLogs []CommitLog) []CommitFileModel {
+	commitFileModels := make([]CommitFileModel, 0)
+	for _, commitLog := range commitLogs {
+		for _, file := range commitLog.Files {
+			commitFileModels = append(commitFileModels, CommitFileModel{
+				RepoUrl:    repoUrl,
+				CommitHash: commitLog.Hash,
+				Path:       file.Path,
+				OldPath:    file.OldPath,
+				Language:   file.Language,
+				Additions:  file.Additions,
+				Deletions:  file.Deletions,
+				ChangeType: file.ChangeType,
+				IsBinary:   file.IsBinary,
+			})
+		}
+	}
+	return commitFileModels
+}
+
+// deleteOrphanCommitFiles removes the file changes of commits that are no
+// longer stored for the repository.
+func deleteOrphanCommitFiles(ctx context.Context, tx bun.Tx, repoUrl string) error {
+	_, err := tx.NewDelete().Model((*CommitFileModel)(nil)).
+		Where("repo_url = ?", repoUrl).
+		Where("NOT EXISTS (?)", tx.NewSelect().Model((*CommitLogModel)(nil)).
+			ColumnExpr("1").
+			Where("cl.repo_url = cf.repo_url").
+			Where("cl.commit_hash = cf.commit_hash")).
+		Exec(ctx)
+	return err
+}
+
+func ResetCommitFile() error {
+	if gdb == nil {
+		return errors.New("database not initialized")
+	}
+	ctx := context.Background()
+	_, err := gdb.NewDropTable().Model((*CommitFileModel)(nil)).IfExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	log.Println("Reset commit files")
+	return nil
+}
@@ -18,15 +18,23 @@ type CommitLogFilter struct {
 
 	IsMerge     string
 	MessageType string
+	Scope       string
+	Breaking    string
 
 	SinceUTC  string
 	UntilUTC  string
 	SinceTime time.Time
 	UntilTime time.Time
 
-	Nickname string
+	Nickname       string
+	Attribution    string
+	ExcludeReverts bool
 
-	Period string
+	Period  string
+	GroupBy string
+
+	Path  string
+	Depth int
 
 	LeEffective string
 	GeEffective string
@@ -34,25 +42,36 @@ type CommitLogFilter struct {
 
 func (filter *CommitLogFilter) SelectQuery(query *bun.SelectQuery) {
 	if filter.RepoUrl != "" {
-		query.Where("repo_url IN (?)", bun.In(strings.Split(filter.RepoUrl, ",")))
+		query.Where("cl.repo_url IN (?)", bun.In(strings.Split(filter.RepoUrl, ",")))
 	}
 	if filter.BranchName != "" {
-		query.Where("branch_name IN (?)", bun.In(strings.Split(filter.BranchName, ",")))
+		branchQuery := gdb.NewSelect().Model((*BranchCommitModel)(nil)).
+			ColumnExpr("1").
+			Where("bc.repo_url = cl.repo_url").
+			Where("bc.commit_hash = cl.commit_hash")
+		filter.BranchQuery(branchQuery)
+		query.Where("EXISTS (?)", branchQuery)
 	}
 	if filter.CommitHash != "" {
-		query.Where("commit_hash = ?", filter.CommitHash)
+		query.Where("cl.commit_hash = ?", filter.CommitHash)
 	}
 	if !filter.SinceTime.IsZero() {
-		query.Where("committer_date >= ?", filter.SinceTime.Format("2006-01-02 15:04:05"))
+		query.Where("cl.committer_date >= ?", filter.SinceTime.Format("2006-01-02 15:04:05"))
 	}
 	if !filter.UntilTime.IsZero() {
-		query.Where("committer_date <= ?", filter.UntilTime.Format("2006-01-02 15:04:05"))
+		query.Where("cl.committer_date <= ?", filter.UntilTime.Format("2006-01-02 15:04:05"))
 	}
 	if filter.Nickname != "" {
-		query.Where("nickname IN (?)", bun.In(strings.Split(filter.Nickname, ",")))
+		query.Where("cl.nickname IN (?)", bun.In(strings.Split(filter.Nickname, ",")))
 	}
 	if filter.MessageType != "" {
-		query.Where("message_type IN (?)", bun.In(strings.Split(filter.MessageType, ",")))
+		query.Where("cl.message_type IN (?)", bun.In(strings.Split(filter.MessageType, ",")))
+	}
+	if filter.Scope != "" {
+		query.Where("cl.scope IN (?)", bun.In(strings.Split(filter.Scope, ",")))
+	}
+	if filter.Breaking != "" {
+		query.Where("cl.breaking = ?", xcast.ToBool(filter.Breaking))
 	}
 	if filter.IsMerge != "" {
 		values := strings.Split(filter.IsMerge, ",")
@@ -60,55 +79,52 @@ func (filter *CommitLogFilter) SelectQuery(query *bun.SelectQuery) {
 		for i, v := range values {
 			nums[i] = xcast.ToInt(v)
 		}
-		query.Where("is_merge IN (?)", bun.In(nums))
+		query.Where("cl.is_merge IN (?)", bun.In(nums))
 	} else {
-		query.Where("is_merge = 0")
+		query.Where("cl.is_merge = 0")
+	}
+	if filter.ExcludeReverts {
+		// both sides of a revert pair
+		query.Where("cl.is_revert = ?", false)
+		query.Where("NOT EXISTS (?)", gdb.NewSelect().
+			TableExpr("commits AS rv").
+			ColumnExpr("1").
+			Where("rv.repo_url = cl.repo_url").
+			Where("rv.reverts_hash = cl.commit_hash"))
 	}
 	if filter.LeEffective != "" {
-		query.Where("effectives <= ?", xcast.ToInt(filter.LeEffective))
+		query.Where("cl.effectives <= ?", xcast.ToInt(filter.LeEffective))
 	}
 	if filter.GeEffective != "" {
-		query.Where("effectives >= ?", xcast.ToInt(filter.GeEffective))
+		query.Where("cl.effectives >= ?", xcast.ToInt(filter.GeEffective))
 	}
 }
 
-func (filter *CommitLogFilter) DeleteQuery(query *bun.DeleteQuery) {
-	if filter.RepoUrl != "" {
-		query.Where("repo_url IN (?)", bun.In(strings.Split(filter.RepoUrl, ",")))
-	}
+// FileQuery restricts a commit_files query to the filtered path and the
+// paths under it.
+func (filter *CommitLogFilter) FileQuery(query *bun.SelectQuery) {
+	wherePathUnder(query, "cf.path", filter.Path)
+}
+
+// wherePathUnder restricts the path column to path and the paths under it,
+// so that pkg/billing matches pkg/billing/tax.go but not pkg/billingx.
+func wherePathUnder(query *bun.SelectQuery, column string, path string) {
+	path = strings.TrimSuffix(path, "/")
+	if path == "" {
+		return
+	}
+	query.Where("("+column+" = ? OR "+column+" LIKE ? ESCAPE '!')", path, escapeLike(path)+"/%")
+}
+
+// escapeLike escapes the LIKE wildcards of s with the ! escape character.
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+// BranchQuery restricts a branch_commits query to the filtered branches.
+func (filter *CommitLogFilter) BranchQuery(query *bun.SelectQuery) {
 	if filter.BranchName != "" {
-		query.Where("branch_name IN (?)", bun.In(strings.Split(filter.BranchName, ",")))
-	}
-	if filter.CommitHash != "" {
-		query.Where("commit_hash = ?", filter.CommitHash)
-	}
-	if !filter.SinceTime.IsZero() {
-		query.Where("committer_date >= ?", filter.SinceTime.Format("2006-01-02 15:04:05"))
-	}
-	if !filter.UntilTime.IsZero() {
-		query.Where("committer_date <= ?", filter.UntilTime.Format("2006-01-02 15:04:05"))
-	}
-	if filter.Nickname != "" {
-		query.Where("nickname IN (?)", bun.In(strings.Split(filter.Nickname, ",")))
-	}
-	if filter.MessageType != "" {
-		query.Where("message_type IN (?)", bun.In(strings.Split(filter.MessageType, ",")))
-	}
-	if filter.IsMerge != "" {
-		values := strings.Split(filter.IsMerge, ",")
-		nums := make([]int, len(values))
-		for i, v := range values {
-			nums[i] = xcast.ToInt(v)
-		}
-		query.Where("is_merge IN (?)", bun.In(nums))
-	} else {
-		query.Where("is_merge = 0")
-	}
-	if filter.LeEffective != "" {
-		query.Where("effectives <= ?", xcast.ToInt(filter.LeEffective))
-	}
-	if filter.GeEffective != "" {
-		query.Where("effectives >= ?", xcast.ToInt(filter.GeEffective))
+		query.Where("bc.branch_name IN (?)", bun.In(strings.Split(filter.BranchName, ",")))
 	}
 }
 
@@ -120,6 +136,9 @@ type CheckUpTodateFilter struct {
 
 	SinceUTC  string
 	SinceTime time.Time
+
+	// Fingerprint is the AnalysisFingerprint the commits are analyzed with
+	Fingerprint string
 }
 
 func (filter *CheckUpTodateFilter) ToCommitLogFilter() *CommitLogFilter {
@@ -0,0 +1,98 @@
+package gitinsight
+
+import (
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// selectCredits aggregates the commits matching the filter per credited
+// nickname: the commit author only, or with the filter's attribution mode
+// also every co-author, with the whole commit or an even share of its lines.
+func selectCredits(filter *CommitLogFilter) (*bun.SelectQuery, error) {
+	switch filter.Attribution {
+	case "", AttributionPrimary:
+		query := gdb.NewSelect().
+			Model((*CommitLogModel)(nil)).
+			ColumnExpr("cl.nickname").
+			ColumnExpr("GROUP_CONCAT(DISTINCT cl.author_name) AS name").
+			ColumnExpr("GROUP_CONCAT(DISTINCT cl.author_email) AS email").
+			ColumnExpr("SUM(cl.additions) AS additions").
+			ColumnExpr("SUM(cl.deletions) AS deletions").
+			ColumnExpr("SUM(cl.effectives) AS effectives").
+			ColumnExpr("COALESCE(SUM((?)), 0) AS churned", churnQuery("")).
+			ColumnExpr("COALESCE(SUM((?)), 0) AS reworked", churnQuery("cl.nickname")).
+			ColumnExpr("COUNT(DISTINCT cl.repo_url) AS projects").
+			ColumnExpr("COUNT(DISTINCT cl.commit_hash) AS commits").
+			Group("cl.nickname")
+		filter.SelectQuery(query)
+		return query, nil
+	case AttributionFull, AttributionSplit:
+	default:
+		return nil, errors.New("invalid attribution, must be one of: primary, full, split")
+	}
+
+	// credits has a row per author and co-author of a commit with the number
+	// of people sharing it
+	sharesQuery := gdb.NewSelect().Model((*CommitCoAuthorModel)(nil)).
+		ColumnExpr("1 + COUNT(*)").
+		Where("ca.repo_url = credit.repo_url").
+		Where("ca.commit_hash = credit.commit_hash")
+	authorsQuery := gdb.NewSelect().
+		TableExpr("commits AS credit").
+		ColumnExpr("credit.repo_url, credit.commit_hash, credit.nickname, credit.author_name, credit.author_email").
+		ColumnExpr("(?) AS shares", sharesQuery)
+	coAuthorsQuery := gdb.NewSelect().
+		TableExpr("commit_coauthors AS credit").
+		ColumnExpr("credit.repo_url, credit.commit_hash, credit.nickname, credit.author_name, credit.author_email").
+		ColumnExpr("(?) AS shares", sharesQuery)
+
+	lines := func(column string) string {
+		if filter.Attribution == AttributionFull {
+			return "SUM(" + column + ")"
+		}
+		return castInt("ROUND(SUM(" + column + " * 1.0 / cr.shares))")
+	}
+	query := gdb.NewSelect().
+		Model((*CommitLogModel)(nil)).
+		Join("JOIN (? UNION ALL ?) AS cr ON cr.repo_url = cl.repo_url AND cr.commit_hash = cl.commit_hash", authorsQuery, coAuthorsQuery).
+		ColumnExpr("cr.nickname").
+		ColumnExpr("GROUP_CONCAT(DISTINCT cr.author_name) AS name").
+		ColumnExpr("GROUP_CONCAT(DISTINCT cr.author_email) AS email").
+		ColumnExpr(lines("cl.additions")+" AS additions").
+		ColumnExpr(lines("cl.deletions")+" AS deletions").
+		ColumnExpr(lines("cl.effectives")+" AS effectives").
+		ColumnExpr("COALESCE("+lines("COALESCE((?), 0)")+", 0) AS churned", churnQuery("")).
+		ColumnExpr("COALESCE("+lines("COALESCE((?), 0)")+", 0) AS reworked", churnQuery("cr.nickname")).
+		ColumnExpr("COUNT(DISTINCT cl.repo_url) AS projects").
+		ColumnExpr("COUNT(DISTINCT cl.commit_hash) AS commits").
+		Group("cr.nickname")
+
+	// The author filter selects the credited people, not the commit authors
+	creditFilter := *filter
+	creditFilter.Nickname = ""
+	creditFilter.SelectQuery(query)
+	if filter.Nickname != "" {
+		query.Where("cr.nickname IN (?)", bun.In(strings.Split(filter.Nickname, ",")))
+	}
+	return query, nil
+}
+
+// churnRate is the percentage of the added lines that were rewritten.
+func churnRate(lines int, additions int) float64 {
+	if additions <= 0 {
+		return 0
+	}
+	return math.Round(float64(lines)*10000/float64(additions)) / 100
+}
+
+// castInt casts a numeric expression to an integer column.
+func castInt(expr string) string {
+	if gdb.Dialect().Name() == dialect.MySQL {
+		return "CAST(" + expr + " AS SIGNED)"
+	}
+	return "CAST(" + expr + " AS INTEGER)"
+}
@@ -8,7 +8,7 @@ import (
 )
 
 type AuthorDTO struct {
-	bun.BaseModel `bun:"table:commit_log,alias:cl"`
+	bun.BaseModel `bun:"table:commits,alias:cl"`
 
 	Name     string `json:"name" bun:",notnull"`
 	Email    string `json:"email" bun:",notnull"`
@@ -18,6 +18,13 @@ type AuthorDTO struct {
 	Deletions  int `json:"deletions" bun:",notnull"`
 	Effectives int `json:"effectives" bun:",notnull"`
 
+	// Churned are the added lines rewritten within the churn window,
+	// Reworked the ones rewritten by the same person
+	Churned    int     `json:"churned" bun:",notnull"`
+	Reworked   int     `json:"reworked" bun:",notnull"`
+	ChurnRate  float64 `json:"churnRate" bun:"-"`
+	ReworkRate float64 `json:"reworkRate" bun:"-"`
+
 	Projects int `json:"projects" bun:",notnull"`
 	Commits  int `json:"commits" bun:",notnull"`
 }
@@ -30,26 +37,15 @@ func GetAuthors(filter *CommitLogFilter) ([]AuthorDTO, error) {
 	ctx := context.Background()
 	var authors []AuthorDTO
 
-	// 先构建子查询，去重 commit_hash
-	subQuery := gdb.NewSelect().
-		Model((*CommitLogModel)(nil)).
-		ColumnExpr("DISTINCT commit_hash, nickname, author_name, author_email, additions, deletions, effectives, repo_url, date")
-
-	filter.SelectQuery(subQuery)
-
-	// 外层统计
-	query := gdb.NewSelect().
-		TableExpr("(?) AS t", subQuery).
-		ColumnExpr("nickname").
-		ColumnExpr("GROUP_CONCAT(DISTINCT author_name) AS name").
-		ColumnExpr("GROUP_CONCAT(DISTINCT author_email) AS email").
-		ColumnExpr("SUM(additions) AS additions").
-		ColumnExpr("SUM(deletions) AS deletions").
-		ColumnExpr("SUM(effectives) AS effectives").
-		ColumnExpr("COUNT(DISTINCT repo_url) AS projects").
-		ColumnExpr("COUNT(DISTINCT commit_hash) AS commits").
-		Group("nickname")
-
-	err := query.Scan(ctx, &authors)
+	query, err := selectCredits(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	err = query.Scan(ctx, &authors)
+	for i := range authors {
+		authors[i].ChurnRate = churnRate(authors[i].Churned, authors[i].Additions)
+		authors[i].ReworkRate = churnRate(authors[i].Reworked, authors[i].Additions)
+	}
 	return authors, err
 }
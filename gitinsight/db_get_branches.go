@@ -8,7 +8,7 @@ import (
 )
 
 type BranchDTO struct {
-	bun.BaseModel `bun:"table:commit_log,alias:cl"`
+	bun.BaseModel `bun:"table:branch_commits,alias:bc"`
 
 	RepoUrl    string `json:"repoUrl" bun:",notnull"`
 	BranchName string `json:"branchName" bun:",notnull"`
@@ -27,27 +27,23 @@ func GetRepoBranches(filter *CommitLogFilter) ([]BranchDTO, error) {
 	ctx := context.Background()
 	var branches []BranchDTO
 
-	subq := gdb.NewSelect().
-		Model((*CommitLogModel)(nil)).
-		ColumnExpr("DISTINCT repo_url, branch_name, commit_hash").
-		Column("nickname").
-		Column("additions").
-		Column("deletions").
-		Column("effectives")
-
-	filter.SelectQuery(subq)
-
-	// === 外层统计 ===
 	query := gdb.NewSelect().
-		TableExpr("(?) AS t", subq).
-		ColumnExpr("repo_url").
-		ColumnExpr("branch_name").
-		ColumnExpr("GROUP_CONCAT(DISTINCT nickname) AS nicknames").
-		ColumnExpr("SUM(additions) AS additions").
-		ColumnExpr("SUM(deletions) AS deletions").
-		ColumnExpr("SUM(effectives) AS effectives").
-		ColumnExpr("COUNT(commit_hash) AS commits").
-		Group("repo_url", "branch_name")
+		Model((*BranchCommitModel)(nil)).
+		Join("JOIN commits AS cl ON cl.repo_url = bc.repo_url AND cl.commit_hash = bc.commit_hash").
+		ColumnExpr("bc.repo_url").
+		ColumnExpr("bc.branch_name").
+		ColumnExpr("GROUP_CONCAT(DISTINCT cl.nickname) AS nicknames").
+		ColumnExpr("SUM(cl.additions) AS additions").
+		ColumnExpr("SUM(cl.deletions) AS deletions").
+		ColumnExpr("SUM(cl.effectives) AS effectives").
+		ColumnExpr("COUNT(cl.commit_hash) AS commits").
+		Group("bc.repo_url", "bc.branch_name")
+
+	// branches are grouped on the membership itself, not filtered through it
+	commitFilter := *filter
+	commitFilter.BranchName = ""
+	commitFilter.SelectQuery(query)
+	filter.BranchQuery(query)
 
 	err := query.Scan(ctx, &branches)
 	return branches, err
@@ -0,0 +1,216 @@
+package gitinsight
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BusFactor configures the knowledge concentration report.
+type BusFactor struct {
+	Threshold    float64 `yaml:"threshold" json:"threshold" mapstructure:"threshold" description:"share of the changed lines the key authors of a directory account for" default:"0.8"`
+	InactiveDays int     `yaml:"inactive_days" json:"inactive_days" mapstructure:"inactive_days" description:"authors without commits for this many days are inactive" default:"90"`
+}
+
+func (busFactor *BusFactor) threshold() float64 {
+	if busFactor.Threshold <= 0 || busFactor.Threshold > 1 {
+		return 0.8
+	}
+	return busFactor.Threshold
+}
+
+func (busFactor *BusFactor) inactiveAfter() time.Duration {
+	days := busFactor.InactiveDays
+	if days <= 0 {
+		days = 90
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// BusFactorDTO is the knowledge concentration of the files under a directory.
+type BusFactorDTO struct {
+	RepoUrl string `json:"repoUrl"`
+	Path    string `json:"path"`
+	Files   int    `json:"files"`
+	Commits int    `json:"commits"`
+	Changes int    `json:"changes"`
+	Authors int    `json:"authors"`
+
+	// BusFactor is the number of authors that account for the threshold
+	// share of the changes, KeyAuthors are those authors
+	BusFactor  int            `json:"busFactor"`
+	KeyAuthors []KeyAuthorDTO `json:"keyAuthors"`
+
+	// InactiveShare is the percentage of the changes made by authors that
+	// have gone inactive
+	InactiveShare float64 `json:"inactiveShare"`
+	SingleOwner   bool    `json:"singleOwner"`
+	AtRisk        bool    `json:"atRisk"`
+}
+
+// KeyAuthorDTO is an author holding a large share of the knowledge of a
+// directory.
+type KeyAuthorDTO struct {
+	Nickname string    `json:"nickname"`
+	Changes  int       `json:"changes"`
+	Share    float64   `json:"share"`
+	LastDate time.Time `json:"lastDate"`
+	Inactive bool      `json:"inactive"`
+}
+
+type authorActivityItem struct {
+	Nickname string    `bun:"nickname"`
+	LastDate time.Time `bun:"last_date"`
+}
+
+// GetBusFactor rolls the file changes of the window up to the directories
+// Depth levels below the filtered path prefix, the top level directories by
+// default, and counts the authors accounting for most of the changed lines
+// of each. Authors are inactive when their last commit in any repository is
+// older than the inactive days before the end of the window. Directories
+// with a single key author, or mostly changed by inactive authors, are at
+// risk and come first.
+func GetBusFactor(filter *CommitLogFilter, busFactor *BusFactor) ([]BusFactorDTO, error) {
+	if gdb == nil {
+		return nil, errors.New("database not initialized")
+	}
+	ctx := context.Background()
+
+	var activity []authorActivityItem
+	err := gdb.NewSelect().
+		Model((*CommitLogModel)(nil)).
+		ColumnExpr("cl.nickname").
+		ColumnExpr("MAX(cl.committer_date) AS last_date").
+		Group("cl.nickname").
+		Scan(ctx, &activity)
+	if err != nil {
+		return nil, err
+	}
+	lastDates := make(map[string]time.Time, len(activity))
+	for _, a := range activity {
+		lastDates[a.Nickname] = a.LastDate
+	}
+	end := filter.UntilTime
+	if end.IsZero() {
+		end = time.Now()
+	}
+	inactiveBefore := end.Add(-busFactor.inactiveAfter())
+
+	// binary changes have no lines but still are knowledge
+	const changedLines = "CASE WHEN d.additions + d.deletions > 0 THEN d.additions + d.deletions ELSE 1 END"
+	base := filter.Path[:strings.LastIndex(filter.Path, "/")+1]
+	directory := directoryExpr(base)
+
+	var directories []struct {
+		RepoUrl   string `bun:"repo_url"`
+		Directory string `bun:"directory"`
+		Files     int    `bun:"files"`
+		Commits   int    `bun:"commits"`
+		Changes   int    `bun:"changes"`
+	}
+	err = gdb.NewSelect().
+		TableExpr("(?) AS d", directoryChanges(filter, base)).
+		ColumnExpr("d.repo_url").
+		ColumnExpr("? AS directory", directory).
+		ColumnExpr("COUNT(DISTINCT d.path) AS files, COUNT(DISTINCT d.commit_hash) AS commits").
+		ColumnExpr("SUM("+changedLines+") AS changes").
+		GroupExpr("d.repo_url, directory").
+		OrderExpr("d.repo_url ASC, directory ASC").
+		Scan(ctx, &directories)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		RepoUrl   string `bun:"repo_url"`
+		Directory string `bun:"directory"`
+		Nickname  string `bun:"nickname"`
+		Changes   int    `bun:"changes"`
+	}
+	err = gdb.NewSelect().
+		TableExpr("(?) AS d", directoryChanges(filter, base)).
+		ColumnExpr("d.repo_url").
+		ColumnExpr("? AS directory", directory).
+		ColumnExpr("d.nickname").
+		ColumnExpr("SUM("+changedLines+") AS changes").
+		GroupExpr("d.repo_url, directory, d.nickname").
+		Scan(ctx, &rows)
+	if err != nil {
+		return nil, err
+	}
+	authors := make(map[string]map[string]int)
+	for _, row := range rows {
+		key := row.RepoUrl + "\x00" + row.Directory
+		if authors[key] == nil {
+			authors[key] = make(map[string]int)
+		}
+		authors[key][row.Nickname] = row.Changes
+	}
+
+	threshold := busFactor.threshold()
+	results := make([]BusFactorDTO, len(directories))
+	for i, row := range directories {
+		key := row.RepoUrl + "\x00" + row.Directory
+		stat := &BusFactorDTO{
+			RepoUrl: row.RepoUrl,
+			Path:    row.Directory,
+			Files:   row.Files,
+			Commits: row.Commits,
+			Changes: row.Changes,
+			Authors: len(authors[key]),
+		}
+
+		nicknames := sortedKeys(authors[key])
+		sort.SliceStable(nicknames, func(i, j int) bool {
+			return authors[key][nicknames[i]] > authors[key][nicknames[j]]
+		})
+		inactive := 0
+		covered := 0
+		stat.KeyAuthors = make([]KeyAuthorDTO, 0)
+		for _, nickname := range nicknames {
+			lines := authors[key][nickname]
+			lastDate := lastDates[nickname]
+			isInactive := lastDate.Before(inactiveBefore)
+			if isInactive {
+				inactive += lines
+			}
+			if float64(covered) >= threshold*float64(stat.Changes) {
+				continue
+			}
+			covered += lines
+			stat.KeyAuthors = append(stat.KeyAuthors, KeyAuthorDTO{
+				Nickname: nickname,
+				Changes:  lines,
+				Share:    percentOf(lines, stat.Changes),
+				LastDate: lastDate,
+				Inactive: isInactive,
+			})
+		}
+		stat.BusFactor = len(stat.KeyAuthors)
+		stat.InactiveShare = percentOf(inactive, stat.Changes)
+		stat.SingleOwner = stat.BusFactor == 1
+		stat.AtRisk = stat.SingleOwner || stat.InactiveShare >= 50
+		results[i] = *stat
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].AtRisk != results[j].AtRisk {
+			return results[i].AtRisk
+		}
+		if results[i].BusFactor != results[j].BusFactor {
+			return results[i].BusFactor < results[j].BusFactor
+		}
+		return results[i].Changes > results[j].Changes
+	})
+	return results, nil
+}
+
+// percentOf is part of total as a percentage rounded to two decimals.
+func percentOf(part int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(part)*10000/float64(total)) / 100
+}
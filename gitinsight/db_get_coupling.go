@@ -0,0 +1,165 @@
+package gitinsight
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// Coupling configures the temporal coupling analysis.
+type Coupling struct {
+	MinSupport    int     `yaml:"min_support" json:"min_support" mapstructure:"min_support" description:"pairs changed together in fewer commits are left out" default:"3"`
+	MinConfidence float64 `yaml:"min_confidence" json:"min_confidence" mapstructure:"min_confidence" description:"pairs whose co-changes are a lower percentage of the commits of either side are left out" default:"50"`
+	MaxFiles      int     `yaml:"max_files" json:"max_files" mapstructure:"max_files" description:"commits changing more files, like mass renames or formatting, are left out" default:"30"`
+}
+
+func (coupling *Coupling) minSupport() int {
+	return max(coupling.MinSupport, 1)
+}
+
+func (coupling *Coupling) maxFiles() int {
+	if coupling.MaxFiles <= 0 {
+		return 30
+	}
+	return coupling.MaxFiles
+}
+
+// CouplingDTO is a pair of files, or directories, that change together.
+type CouplingDTO struct {
+	RepoUrl string `json:"repoUrl"`
+	Path    string `json:"path"`
+	Coupled string `json:"coupled"`
+
+	// Commits and CoupledCommits are the commits changing either side,
+	// CoChanges the ones changing both
+	Commits        int `json:"commits"`
+	CoupledCommits int `json:"coupledCommits"`
+	CoChanges      int `json:"coChanges"`
+
+	// Confidence is the percentage of the commits of the side changed less
+	// often that also change the other side, Degree the percentage of the
+	// average commits of both sides
+	Confidence float64 `json:"confidence"`
+	Degree     float64 `json:"degree"`
+}
+
+// GetCoupling finds the pairs of files of a repository changed together by
+// at least the minimum support of the commits matching the filter, with at
+// least the minimum confidence, most co-changes first. With GroupBy set to
+// directory, the pairs are of the directories Depth levels below the
+// filtered path prefix. It returns the requested page and the total number
+// of pairs.
+func GetCoupling(filter *CommitLogFilter, coupling *Coupling) ([]CouplingDTO, int, error) {
+	byDirectory := false
+	switch filter.GroupBy {
+	case "", "file":
+	case "directory":
+		byDirectory = true
+	default:
+		return nil, 0, errors.New("invalid group, must be one of: file, directory")
+	}
+
+	if gdb == nil {
+		return nil, 0, errors.New("database not initialized")
+	}
+
+	// the distinct entities changed by each commit
+	base := filter.Path[:strings.LastIndex(filter.Path, "/")+1]
+	var entities *bun.SelectQuery
+	if byDirectory {
+		entities = gdb.NewSelect().
+			TableExpr("(?) AS d", directoryChanges(filter, base)).
+			Distinct().
+			ColumnExpr("d.repo_url, d.commit_hash").
+			ColumnExpr("? AS entity", directoryExpr(base))
+	} else {
+		entities = gdb.NewSelect().
+			Model((*CommitFileModel)(nil)).
+			Join("JOIN commits AS cl ON cl.repo_url = cf.repo_url AND cl.commit_hash = cf.commit_hash").
+			Distinct().
+			ColumnExpr("cf.repo_url, cf.commit_hash, cf.path AS entity")
+		filter.SelectQuery(entities)
+		filter.FileQuery(entities)
+	}
+	// commits changing more than the max files are left out
+	kept := gdb.NewSelect().
+		TableExpr("entities AS en").
+		ColumnExpr("en.repo_url, en.commit_hash, en.entity").
+		Join("JOIN (?) AS c ON c.repo_url = en.repo_url AND c.commit_hash = en.commit_hash", gdb.NewSelect().
+			TableExpr("entities").
+			ColumnExpr("repo_url, commit_hash").
+			GroupExpr("repo_url, commit_hash").
+			Having("COUNT(*) <= ?", coupling.maxFiles()))
+	revisions := gdb.NewSelect().
+		TableExpr("kept").
+		ColumnExpr("repo_url, entity, COUNT(*) AS commits").
+		GroupExpr("repo_url, entity")
+	pairs := gdb.NewSelect().
+		TableExpr("kept AS a").
+		Join("JOIN kept AS b ON b.repo_url = a.repo_url AND b.commit_hash = a.commit_hash AND b.entity > a.entity").
+		ColumnExpr("a.repo_url, a.entity AS path, b.entity AS coupled, COUNT(*) AS co_changes").
+		GroupExpr("a.repo_url, a.entity, b.entity").
+		Having("COUNT(*) >= ?", coupling.minSupport())
+
+	var rows []struct {
+		RepoUrl        string `bun:"repo_url"`
+		Path           string `bun:"path"`
+		Coupled        string `bun:"coupled"`
+		CoChanges      int    `bun:"co_changes"`
+		Commits        int    `bun:"commits"`
+		CoupledCommits int    `bun:"coupled_commits"`
+	}
+	err := gdb.NewSelect().
+		With("entities", entities).
+		With("kept", kept).
+		With("revisions", revisions).
+		With("pairs", pairs).
+		TableExpr("pairs AS p").
+		Join("JOIN revisions AS rp ON rp.repo_url = p.repo_url AND rp.entity = p.path").
+		Join("JOIN revisions AS rc ON rc.repo_url = p.repo_url AND rc.entity = p.coupled").
+		ColumnExpr("p.repo_url, p.path, p.coupled, p.co_changes").
+		ColumnExpr("rp.commits AS commits, rc.commits AS coupled_commits").
+		Scan(context.Background(), &rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	results := make([]CouplingDTO, 0)
+	for _, row := range rows {
+		item := CouplingDTO{
+			RepoUrl:        row.RepoUrl,
+			Path:           row.Path,
+			Coupled:        row.Coupled,
+			Commits:        row.Commits,
+			CoupledCommits: row.CoupledCommits,
+			CoChanges:      row.CoChanges,
+		}
+		item.Confidence = percentOf(item.CoChanges, min(item.Commits, item.CoupledCommits))
+		item.Degree = percentOf(item.CoChanges*2, item.Commits+item.CoupledCommits)
+		if item.Confidence < coupling.MinConfidence {
+			continue
+		}
+		results = append(results, item)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].CoChanges != results[j].CoChanges {
+			return results[i].CoChanges > results[j].CoChanges
+		}
+		if results[i].Degree != results[j].Degree {
+			return results[i].Degree > results[j].Degree
+		}
+		if results[i].RepoUrl != results[j].RepoUrl {
+			return results[i].RepoUrl < results[j].RepoUrl
+		}
+		if results[i].Path != results[j].Path {
+			return results[i].Path < results[j].Path
+		}
+		return results[i].Coupled < results[j].Coupled
+	})
+
+	total := len(results)
+	return paginate(results, filter.Offset, filter.Limit), total, nil
+}
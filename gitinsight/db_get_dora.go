@@ -0,0 +1,229 @@
+package gitinsight
+
+import (
+	"context"
+	"errors"
+	"math"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Dora configures the delivery metrics.
+type Dora struct {
+	DeployTags []string `yaml:"deploy_tags,omitempty" json:"deploy_tags,omitempty" mapstructure:"deploy_tags" description:"globs of the tag names that are production deployments, defaults to every tag"`
+}
+
+// IsDeployTag reports whether a tag is a production deployment.
+func (dora *Dora) IsDeployTag(tagName string) bool {
+	if len(dora.DeployTags) == 0 {
+		return true
+	}
+	for _, pattern := range dora.DeployTags {
+		if ok, _ := path.Match(pattern, tagName); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// remediationExpr is true for commits that fix a failed deployment: reverts
+// and hotfixes, including the merges of hotfix branches.
+const remediationExpr = "cl.is_revert = 1 OR LOWER(cl.scope) = 'hotfix' OR LOWER(cl.subject) LIKE '%hotfix%'"
+
+// DoraItem is the delivery performance of a repository in a period:
+// deployment frequency, lead time for changes and change failure rate.
+type DoraItem struct {
+	RepoUrl string `json:"repoUrl"`
+	Period  string `json:"period"`
+
+	Deployments int `json:"deployments"`
+	Changes     int `json:"changes"`
+	// LeadTimeHours is the median time from the first commit of a change to
+	// the deployment that shipped it
+	LeadTimeHours     float64 `json:"leadTimeHours"`
+	MeanLeadTimeHours float64 `json:"meanLeadTimeHours"`
+	// FailedDeployments were followed by a deployment shipping a revert or a
+	// hotfix
+	FailedDeployments int     `json:"failedDeployments"`
+	ChangeFailureRate float64 `json:"changeFailureRate"`
+}
+
+type doraTag struct {
+	RepoUrl string    `bun:"repo_url"`
+	TagName string    `bun:"tag_name"`
+	Date    time.Time `bun:"date"`
+	Period  string    `bun:"period"`
+}
+
+type doraChange struct {
+	RepoUrl     string    `bun:"repo_url"`
+	TagName     string    `bun:"tag_name"`
+	ChangeHash  string    `bun:"change_hash"`
+	FirstDate   time.Time `bun:"first_date"`
+	Remediation int       `bun:"remediation"`
+}
+
+type doraDeployment struct {
+	tag         doraTag
+	leadTimes   []float64
+	remediation bool
+	failed      bool
+}
+
+// GetDora computes the delivery metrics of the deployments dated within the
+// since and until of the filter, per repository and period. The period
+// granularity defaults to month. A change is a first-parent commit of a
+// deployment tag, a merge with all the commits it merged; releases of tags
+// that are not deployments ship with the next deployment.
+func GetDora(filter *CommitLogFilter, dora *Dora) ([]DoraItem, error) {
+	if gdb == nil {
+		return nil, errors.New("database not initialized")
+	}
+	ctx := context.Background()
+
+	period := filter.Period
+	if period == "" {
+		period = "month"
+	}
+	periodExpr, err := getPeriodExpr(period, "tg.date")
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []doraTag
+	tagQuery := gdb.NewSelect().
+		Model((*TagModel)(nil)).
+		ColumnExpr("tg.repo_url").
+		ColumnExpr("tg.tag_name").
+		ColumnExpr("tg.date").
+		ColumnExpr(periodExpr+" AS period").
+		Order("tg.repo_url", "tg.date", "tg.tag_name")
+	if filter.RepoUrl != "" {
+		tagQuery.Where("tg.repo_url IN (?)", bun.In(strings.Split(filter.RepoUrl, ",")))
+	}
+	if err := tagQuery.Scan(ctx, &tags); err != nil {
+		return nil, err
+	}
+
+	// every tag ships with the first deployment at or after it
+	deployments := make([]*doraDeployment, 0)
+	shippedBy := make(map[string]*doraDeployment, len(tags))
+	previousOf := make(map[*doraDeployment]*doraDeployment)
+	var next *doraDeployment
+	for i := len(tags) - 1; i >= 0; i-- {
+		tag := tags[i]
+		if i == len(tags)-1 || tags[i+1].RepoUrl != tag.RepoUrl {
+			next = nil
+		}
+		if dora.IsDeployTag(tag.TagName) {
+			deployment := &doraDeployment{tag: tag}
+			if next != nil {
+				previousOf[next] = deployment
+			}
+			deployments = append(deployments, deployment)
+			next = deployment
+		}
+		if next != nil {
+			shippedBy[tag.RepoUrl+"\x00"+tag.TagName] = next
+		}
+	}
+
+	var changes []doraChange
+	changeQuery := gdb.NewSelect().
+		Model((*TagCommitModel)(nil)).
+		Join("JOIN commits AS cl ON cl.repo_url = tc.repo_url AND cl.commit_hash = tc.commit_hash").
+		ColumnExpr("tc.repo_url").
+		ColumnExpr("tc.tag_name").
+		ColumnExpr("tc.change_hash").
+		ColumnExpr("MIN(cl.date) AS first_date").
+		ColumnExpr("MAX(CASE WHEN "+remediationExpr+" THEN 1 ELSE 0 END) AS remediation").
+		Group("tc.repo_url", "tc.tag_name", "tc.change_hash")
+	// since and until select the deployments, merges are part of the changes
+	commitFilter := *filter
+	commitFilter.SinceTime = time.Time{}
+	commitFilter.UntilTime = time.Time{}
+	if commitFilter.IsMerge == "" {
+		commitFilter.IsMerge = "0,1"
+	}
+	commitFilter.SelectQuery(changeQuery)
+	if err := changeQuery.Scan(ctx, &changes); err != nil {
+		return nil, err
+	}
+	for _, change := range changes {
+		deployment, ok := shippedBy[change.RepoUrl+"\x00"+change.TagName]
+		if !ok {
+			continue
+		}
+		leadTime := deployment.tag.Date.Sub(change.FirstDate).Hours()
+		deployment.leadTimes = append(deployment.leadTimes, math.Max(leadTime, 0))
+		if change.Remediation > 0 {
+			deployment.remediation = true
+		}
+	}
+	for _, deployment := range deployments {
+		if previous, ok := previousOf[deployment]; ok && deployment.remediation {
+			previous.failed = true
+		}
+	}
+
+	items := make(map[string]*DoraItem)
+	leadTimes := make(map[string][]float64)
+	for _, deployment := range deployments {
+		tag := deployment.tag
+		if !filter.SinceTime.IsZero() && tag.Date.Before(filter.SinceTime) {
+			continue
+		}
+		if !filter.UntilTime.IsZero() && tag.Date.After(filter.UntilTime) {
+			continue
+		}
+		key := tag.RepoUrl + "\x00" + tag.Period
+		item, ok := items[key]
+		if !ok {
+			item = &DoraItem{RepoUrl: tag.RepoUrl, Period: tag.Period}
+			items[key] = item
+		}
+		item.Deployments++
+		item.Changes += len(deployment.leadTimes)
+		if deployment.failed {
+			item.FailedDeployments++
+		}
+		leadTimes[key] = append(leadTimes[key], deployment.leadTimes...)
+	}
+
+	results := make([]DoraItem, 0, len(items))
+	for key, item := range items {
+		item.LeadTimeHours, item.MeanLeadTimeHours = medianAndMean(leadTimes[key])
+		item.ChangeFailureRate = math.Round(float64(item.FailedDeployments)/float64(item.Deployments)*10000) / 10000
+		results = append(results, *item)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].RepoUrl != results[j].RepoUrl {
+			return results[i].RepoUrl < results[j].RepoUrl
+		}
+		return results[i].Period < results[j].Period
+	})
+	return results, nil
+}
+
+// medianAndMean returns the median and the mean of the values rounded to
+// two decimals, zeros for no values.
+func medianAndMean(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+	if len(sorted)%2 == 0 {
+		median = (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	}
+	sum := 0.0
+	for _, value := range sorted {
+		sum += value
+	}
+	return math.Round(median*100) / 100, math.Round(sum/float64(len(sorted))*100) / 100
+}
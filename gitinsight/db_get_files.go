@@ -0,0 +1,355 @@
+package gitinsight
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// FileChangeItem is one file change joined with the commit it belongs to.
+type FileChangeItem struct {
+	RepoUrl       string    `bun:"repo_url" json:"repoUrl"`
+	CommitHash    string    `bun:"commit_hash" json:"commitHash"`
+	Path          string    `bun:"path" json:"path"`
+	OldPath       string    `bun:"old_path" json:"oldPath"`
+	Language      string    `bun:"language" json:"language"`
+	Additions     int       `bun:"additions" json:"additions"`
+	Deletions     int       `bun:"deletions" json:"deletions"`
+	ChangeType    string    `bun:"change_type" json:"changeType"`
+	IsBinary      bool      `bun:"is_binary" json:"isBinary"`
+	Nickname      string    `bun:"nickname" json:"nickname"`
+	CommitterDate time.Time `bun:"committer_date" json:"committerDate"`
+}
+
+// FileStatDTO aggregates the changes of one file, including the ones made
+// under the OldPaths it was renamed from.
+type FileStatDTO struct {
+	RepoUrl   string    `json:"repoUrl"`
+	Path      string    `json:"path"`
+	OldPaths  []string  `json:"oldPaths,omitempty"`
+	Commits   int       `json:"commits"`
+	Additions int       `json:"additions"`
+	Deletions int       `json:"deletions"`
+	Authors   int       `json:"authors"`
+	Nicknames []string  `json:"nicknames"`
+	LastDate  time.Time `json:"lastDate"`
+}
+
+// DirectoryStatDTO aggregates the changes of the files under a directory.
+type DirectoryStatDTO struct {
+	RepoUrl   string    `json:"repoUrl"`
+	Path      string    `json:"path"`
+	Files     int       `json:"files"`
+	Commits   int       `json:"commits"`
+	Additions int       `json:"additions"`
+	Deletions int       `json:"deletions"`
+	Authors   int       `json:"authors"`
+	Nicknames []string  `json:"nicknames"`
+	LastDate  time.Time `json:"lastDate"`
+}
+
+// GetFileChanges returns the file changes of the commits matching the filter,
+// newest first.
+func GetFileChanges(filter *CommitLogFilter) ([]FileChangeItem, error) {
+	if gdb == nil {
+		return nil, errors.New("database not initialized")
+	}
+
+	ctx := context.Background()
+	var changes []FileChangeItem
+
+	query := gdb.NewSelect().
+		Model((*CommitFileModel)(nil)).
+		Join("JOIN commits AS cl ON cl.repo_url = cf.repo_url AND cl.commit_hash = cf.commit_hash").
+		ColumnExpr("cf.repo_url, cf.commit_hash, cf.path, cf.old_path, cf.language").
+		ColumnExpr("cf.additions, cf.deletions, cf.change_type, cf.is_binary").
+		ColumnExpr("cl.nickname, cl.committer_date").
+		OrderExpr("cl.committer_date DESC")
+
+	filter.SelectQuery(query)
+	filter.FileQuery(query)
+
+	err := query.Scan(ctx, &changes)
+	return changes, err
+}
+
+// fileStatRow is a file aggregated by the database, its nicknames and old
+// paths comma separated.
+type fileStatRow struct {
+	RepoUrl   string    `bun:"repo_url"`
+	File      string    `bun:"file"`
+	OldPaths  string    `bun:"old_paths"`
+	Commits   int       `bun:"commits"`
+	Additions int       `bun:"additions"`
+	Deletions int       `bun:"deletions"`
+	Authors   int       `bun:"authors"`
+	Nicknames string    `bun:"nicknames"`
+	LastDate  time.Time `bun:"last_date"`
+}
+
+func (row *fileStatRow) stat() FileStatDTO {
+	stat := FileStatDTO{
+		RepoUrl:   row.RepoUrl,
+		Path:      row.File,
+		Commits:   row.Commits,
+		Additions: row.Additions,
+		Deletions: row.Deletions,
+		Authors:   row.Authors,
+		Nicknames: splitGroupConcat(row.Nicknames),
+		LastDate:  row.LastDate,
+	}
+	if row.OldPaths != "" {
+		stat.OldPaths = splitGroupConcat(row.OldPaths)
+	}
+	return stat
+}
+
+// maxRenameHops bounds the renames followed from a path to its current name.
+const maxRenameHops = 32
+
+// fileStatsQuery groups the file changes matching the filter per file. The
+// changes made to a path before it was renamed count for the name it was
+// last renamed to: the renames CTE links each old path to every later name,
+// with the date of its first rename.
+func fileStatsQuery(filter *CommitLogFilter) *bun.SelectQuery {
+	renames := gdb.NewSelect().
+		Model((*CommitFileModel)(nil)).
+		Join("JOIN commits AS cl ON cl.repo_url = cf.repo_url AND cl.commit_hash = cf.commit_hash").
+		ColumnExpr("cf.repo_url, cf.old_path AS path, cf.path AS renamed").
+		ColumnExpr("cl.committer_date AS first_at, cl.committer_date AS renamed_at, 0 AS hops").
+		Where("cf.change_type = ?", ChangeTypeRename).
+		Where("cf.old_path <> ''").
+		Where("cf.old_path <> cf.path")
+	if filter.RepoUrl != "" {
+		renames.Where("cf.repo_url IN (?)", bun.In(strings.Split(filter.RepoUrl, ",")))
+	}
+	renamesCte := gdb.NewRaw(`? UNION
+		SELECT r.repo_url, r.path, cf.path, r.first_at, cl.committer_date, r.hops + 1
+		FROM renames AS r
+		JOIN commit_files AS cf ON cf.repo_url = r.repo_url AND cf.old_path = r.renamed
+		JOIN commits AS cl ON cl.repo_url = cf.repo_url AND cl.commit_hash = cf.commit_hash
+		WHERE cf.change_type = ? AND cf.old_path <> cf.path AND cl.committer_date >= r.renamed_at AND r.hops < ?`,
+		renames, ChangeTypeRename, maxRenameHops)
+
+	renamed := gdb.NewSelect().
+		TableExpr("renames AS r").
+		ColumnExpr("r.renamed").
+		Where("r.repo_url = cf.repo_url").
+		Where("r.path = cf.path").
+		Where("r.first_at >= cl.committer_date").
+		OrderExpr("r.first_at ASC, r.hops DESC").
+		Limit(1)
+	changes := gdb.NewSelect().
+		Model((*CommitFileModel)(nil)).
+		Join("JOIN commits AS cl ON cl.repo_url = cf.repo_url AND cl.commit_hash = cf.commit_hash").
+		ColumnExpr("cf.repo_url, cf.commit_hash, cf.path, cf.old_path, cf.change_type").
+		ColumnExpr("cf.additions, cf.deletions, cl.nickname, cl.committer_date").
+		ColumnExpr("COALESCE((?), cf.path) AS file", renamed)
+	filter.SelectQuery(changes)
+	filter.FileQuery(changes)
+
+	return gdb.NewSelect().
+		WithRecursive("renames", renamesCte).
+		TableExpr("(?) AS fc", changes).
+		ColumnExpr("fc.repo_url, fc.file").
+		ColumnExpr("GROUP_CONCAT(DISTINCT CASE WHEN fc.change_type = ? THEN fc.old_path END) AS old_paths", ChangeTypeRename).
+		ColumnExpr("COUNT(DISTINCT fc.commit_hash) AS commits").
+		ColumnExpr("SUM(fc.additions) AS additions, SUM(fc.deletions) AS deletions").
+		ColumnExpr("COUNT(DISTINCT fc.nickname) AS authors, GROUP_CONCAT(DISTINCT fc.nickname) AS nicknames").
+		ColumnExpr("MAX(fc.committer_date) AS last_date").
+		GroupExpr("fc.repo_url, fc.file")
+}
+
+// scanFileStats returns the page of the grouped files query and the total
+// number of files.
+func scanFileStats(ctx context.Context, query *bun.SelectQuery, filter *CommitLogFilter) ([]fileStatRow, int, error) {
+	total, err := gdb.NewSelect().TableExpr("(?) AS files", query).Count(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	if filter.Limit > 0 {
+		query.Limit(filter.Limit).Offset(filter.Offset)
+	}
+	rows := make([]fileStatRow, 0)
+	err = query.Scan(ctx, &rows)
+	return rows, total, err
+}
+
+// GetFileStats aggregates the file changes per file, most changed first. It
+// returns the requested page and the total number of files.
+func GetFileStats(filter *CommitLogFilter) ([]FileStatDTO, int, error) {
+	if gdb == nil {
+		return nil, 0, errors.New("database not initialized")
+	}
+
+	ctx := context.Background()
+	query := fileStatsQuery(filter).
+		OrderExpr("COUNT(DISTINCT fc.commit_hash) DESC").
+		OrderExpr("SUM(fc.additions) + SUM(fc.deletions) DESC").
+		OrderExpr("fc.file ASC")
+	rows, total, err := scanFileStats(ctx, query, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	results := make([]FileStatDTO, len(rows))
+	for i := range rows {
+		results[i] = rows[i].stat()
+	}
+	return results, total, nil
+}
+
+// splitGroupConcat splits a comma separated GROUP_CONCAT into its sorted
+// values.
+func splitGroupConcat(values string) []string {
+	if values == "" {
+		return []string{}
+	}
+	items := strings.Split(values, ",")
+	sort.Strings(items)
+	return items
+}
+
+// maxDirectoryDepth bounds the levels GetDirectoryStats rolls files up to.
+const maxDirectoryDepth = 16
+
+// directoryChanges selects the file changes matching the filter with e, the
+// position of the slash ending their directory Depth levels below base.
+func directoryChanges(filter *CommitLogFilter, base string) *bun.SelectQuery {
+	depth := min(max(filter.Depth, 1), maxDirectoryDepth)
+
+	// each level moves e to the next slash of the path if there is one
+	columns := "d.repo_url, d.commit_hash, d.path, d.additions, d.deletions, d.nickname, d.committer_date"
+	level := gdb.NewSelect().
+		Model((*CommitFileModel)(nil)).
+		Join("JOIN commits AS cl ON cl.repo_url = cf.repo_url AND cl.commit_hash = cf.commit_hash").
+		ColumnExpr("cf.repo_url, cf.commit_hash, cf.path, cf.additions, cf.deletions").
+		ColumnExpr("cl.nickname, cl.committer_date").
+		ColumnExpr("? AS e", utf8.RuneCountInString(base))
+	filter.SelectQuery(level)
+	filter.FileQuery(level)
+	for i := 0; i < depth; i++ {
+		level = gdb.NewSelect().
+			TableExpr("(?) AS d", level).
+			ColumnExpr(columns).
+			ColumnExpr("d.e + INSTR(SUBSTR(d.path, d.e + 1), '/') AS e")
+	}
+	return level
+}
+
+// directoryExpr is the directory of a directoryChanges row, DirectoryOf in
+// SQL.
+func directoryExpr(base string) schema.QueryWithArgs {
+	root := strings.TrimSuffix(base, "/")
+	if root == "" {
+		root = "."
+	}
+	return bun.SafeQuery("CASE WHEN d.e = ? THEN ? ELSE SUBSTR(d.path, 1, d.e - 1) END", utf8.RuneCountInString(base), root)
+}
+
+// GetDirectoryStats rolls the file changes up to the directories Depth levels
+// below the filtered path prefix, most changed first. It returns the
+// requested page and the total number of directories.
+func GetDirectoryStats(filter *CommitLogFilter) ([]DirectoryStatDTO, int, error) {
+	if gdb == nil {
+		return nil, 0, errors.New("database not initialized")
+	}
+
+	base := filter.Path[:strings.LastIndex(filter.Path, "/")+1]
+	ctx := context.Background()
+	query := gdb.NewSelect().
+		TableExpr("(?) AS d", directoryChanges(filter, base)).
+		ColumnExpr("d.repo_url").
+		ColumnExpr("? AS directory", directoryExpr(base)).
+		ColumnExpr("COUNT(DISTINCT d.path) AS files, COUNT(DISTINCT d.commit_hash) AS commits").
+		ColumnExpr("SUM(d.additions) AS additions, SUM(d.deletions) AS deletions").
+		ColumnExpr("COUNT(DISTINCT d.nickname) AS authors, GROUP_CONCAT(DISTINCT d.nickname) AS nicknames").
+		ColumnExpr("MAX(d.committer_date) AS last_date").
+		GroupExpr("d.repo_url, directory")
+	total, err := gdb.NewSelect().TableExpr("(?) AS directories", query).Count(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	query.OrderExpr("COUNT(DISTINCT d.commit_hash) DESC").OrderExpr("directory ASC")
+	if filter.Limit > 0 {
+		query.Limit(filter.Limit).Offset(filter.Offset)
+	}
+
+	var rows []struct {
+		RepoUrl   string    `bun:"repo_url"`
+		Directory string    `bun:"directory"`
+		Files     int       `bun:"files"`
+		Commits   int       `bun:"commits"`
+		Additions int       `bun:"additions"`
+		Deletions int       `bun:"deletions"`
+		Authors   int       `bun:"authors"`
+		Nicknames string    `bun:"nicknames"`
+		LastDate  time.Time `bun:"last_date"`
+	}
+	err = query.Scan(ctx, &rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	results := make([]DirectoryStatDTO, len(rows))
+	for i, row := range rows {
+		results[i] = DirectoryStatDTO{
+			RepoUrl:   row.RepoUrl,
+			Path:      row.Directory,
+			Files:     row.Files,
+			Commits:   row.Commits,
+			Additions: row.Additions,
+			Deletions: row.Deletions,
+			Authors:   row.Authors,
+			Nicknames: splitGroupConcat(row.Nicknames),
+			LastDate:  row.LastDate,
+		}
+	}
+	return results, total, nil
+}
+
+// DirectoryOf returns the directory of path that is depth levels below base,
+// or base itself for the files directly in it. The root directory is ".".
+func DirectoryOf(base string, path string, depth int) string {
+	parts := strings.Split(strings.TrimPrefix(path, base), "/")
+	parts = parts[:len(parts)-1]
+	if len(parts) > depth {
+		parts = parts[:depth]
+	}
+	if len(parts) == 0 {
+		if base == "" {
+			return "."
+		}
+		return strings.TrimSuffix(base, "/")
+	}
+	return base + strings.Join(parts, "/")
+}
+
+func sortedKeys[V any](set map[string]V) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func paginate[T any](items []T, offset int, limit int) []T {
+	if offset >= len(items) {
+		return []T{}
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	end := len(items)
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+	return items[offset:end]
+}
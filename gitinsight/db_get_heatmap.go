@@ -23,16 +23,16 @@ func GetCommitHeatmapData(filter *CommitLogFilter) ([]CommitHeatmapItem, error)
 
 	query := gdb.NewSelect().
 		Model((*CommitLogModel)(nil)).
-		ColumnExpr("DATE(date) AS date").
-		ColumnExpr("COUNT(DISTINCT commit_hash) AS commits").
-		ColumnExpr("SUM(additions) AS additions").
-		ColumnExpr("SUM(deletions) AS deletions").
-		ColumnExpr("SUM(effectives) AS effectives")
+		ColumnExpr("DATE(cl.date) AS date").
+		ColumnExpr("COUNT(DISTINCT cl.commit_hash) AS commits").
+		ColumnExpr("SUM(cl.additions) AS additions").
+		ColumnExpr("SUM(cl.deletions) AS deletions").
+		ColumnExpr("SUM(cl.effectives) AS effectives")
 
 	filter.SelectQuery(query)
 
 	// ✅ 正确分组与排序（使用 Expr）
-	query.GroupExpr("DATE(date)").OrderExpr("DATE(date) ASC")
+	query.GroupExpr("DATE(cl.date)").OrderExpr("DATE(cl.date) ASC")
 
 	// 执行查询
 	err := query.Scan(ctx, &results)
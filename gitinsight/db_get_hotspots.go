@@ -0,0 +1,49 @@
+package gitinsight
+
+import (
+	"context"
+	"errors"
+)
+
+// HotspotDTO is a file that changes often and by many lines.
+type HotspotDTO struct {
+	FileStatDTO
+
+	// Churn is the changed lines, Score the commits times the churn
+	Churn int `json:"churn"`
+	Score int `json:"score"`
+}
+
+// GetHotspots ranks the files changed by the commits matching the filter by
+// their number of commits times their changed lines, highest first. Files
+// whose last change in the window deleted them are left out. It returns the
+// requested page and the total number of hotspots.
+func GetHotspots(filter *CommitLogFilter) ([]HotspotDTO, int, error) {
+	if gdb == nil {
+		return nil, 0, errors.New("database not initialized")
+	}
+
+	ctx := context.Background()
+	query := fileStatsQuery(filter).
+		Having("MAX(CASE WHEN fc.change_type = ? THEN fc.committer_date END) IS NULL OR MAX(CASE WHEN fc.change_type = ? THEN fc.committer_date END) < MAX(fc.committer_date)",
+			ChangeTypeDelete, ChangeTypeDelete).
+		OrderExpr("COUNT(DISTINCT fc.commit_hash) * (SUM(fc.additions) + SUM(fc.deletions)) DESC").
+		OrderExpr("COUNT(DISTINCT fc.commit_hash) DESC").
+		OrderExpr("fc.file ASC")
+	rows, total, err := scanFileStats(ctx, query, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	results := make([]HotspotDTO, len(rows))
+	for i := range rows {
+		stat := rows[i].stat()
+		churn := stat.Additions + stat.Deletions
+		results[i] = HotspotDTO{
+			FileStatDTO: stat,
+			Churn:       churn,
+			Score:       stat.Commits * churn,
+		}
+	}
+	return results, total, nil
+}
@@ -0,0 +1,72 @@
+package gitinsight
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// LanguageStatItem is the line change of a language, optionally per author,
+// repository and period.
+type LanguageStatItem struct {
+	Language  string `bun:"language" json:"language"`
+	Nickname  string `bun:"nickname" json:"nickname,omitempty"`
+	RepoUrl   string `bun:"repo_url" json:"repoUrl,omitempty"`
+	Period    string `bun:"period" json:"period,omitempty"`
+	Commits   int    `bun:"commits" json:"commits"`
+	Files     int    `bun:"files" json:"files"`
+	Additions int    `bun:"additions" json:"additions"`
+	Deletions int    `bun:"deletions" json:"deletions"`
+}
+
+// GetLanguageStats aggregates the file changes per language. GroupBy is a
+// comma separated list of author, repo and period to break the languages
+// down further, the period granularity defaults to month.
+func GetLanguageStats(filter *CommitLogFilter) ([]LanguageStatItem, error) {
+	if gdb == nil {
+		return nil, errors.New("database not initialized")
+	}
+	ctx := context.Background()
+	var results []LanguageStatItem
+
+	query := gdb.NewSelect().
+		Model((*CommitFileModel)(nil)).
+		Join("JOIN commits AS cl ON cl.repo_url = cf.repo_url AND cl.commit_hash = cf.commit_hash").
+		ColumnExpr("cf.language").
+		ColumnExpr("COUNT(DISTINCT cl.commit_hash) AS commits").
+		ColumnExpr("COUNT(DISTINCT cf.path) AS files").
+		ColumnExpr("SUM(cf.additions) AS additions").
+		ColumnExpr("SUM(cf.deletions) AS deletions").
+		GroupExpr("cf.language")
+
+	for _, group := range strings.Split(filter.GroupBy, ",") {
+		switch strings.TrimSpace(group) {
+		case "":
+		case "author":
+			query.ColumnExpr("cl.nickname").GroupExpr("cl.nickname").OrderExpr("cl.nickname ASC")
+		case "repo":
+			query.ColumnExpr("cl.repo_url").GroupExpr("cl.repo_url").OrderExpr("cl.repo_url ASC")
+		case "period":
+			period := filter.Period
+			if period == "" {
+				period = "month"
+			}
+			periodExpr, err := getPeriodExpr(period, "cl.date")
+			if err != nil {
+				return nil, err
+			}
+			query.ColumnExpr(periodExpr + " AS period").GroupExpr("period").OrderExpr("period ASC")
+		default:
+			return nil, errors.New("invalid group, must be any of: author, repo, period")
+		}
+	}
+	query.OrderExpr("additions DESC")
+
+	filter.SelectQuery(query)
+	filter.FileQuery(query)
+
+	if err := query.Scan(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
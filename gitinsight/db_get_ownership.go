@@ -0,0 +1,151 @@
+package gitinsight
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// OwnershipDTO is who owns the lines of the files under a directory on the
+// head of a branch.
+type OwnershipDTO struct {
+	RepoUrl    string     `json:"repoUrl"`
+	BranchName string     `json:"branchName"`
+	Path       string     `json:"path"`
+	Files      int        `json:"files"`
+	Lines      int        `json:"lines"`
+	Owners     []OwnerDTO `json:"owners"`
+}
+
+// OwnerDTO is the share of a person, or of a team, in a directory.
+type OwnerDTO struct {
+	Nickname string  `json:"nickname,omitempty"`
+	Team     string  `json:"team"`
+	Files    int     `json:"files"`
+	Lines    int     `json:"lines"`
+	Share    float64 `json:"share"`
+}
+
+type ownedFileItem struct {
+	RepoUrl    string `bun:"repo_url"`
+	BranchName string `bun:"branch_name"`
+	Path       string `bun:"path"`
+	Nickname   string `bun:"nickname"`
+	Lines      int    `bun:"lines"`
+}
+
+// GetOwnership rolls the latest ownership snapshots up to the directories
+// Depth levels below the filtered path prefix, most lines first. Owners are
+// people, or their teams when GroupBy is team; people without a team share
+// the empty team.
+func GetOwnership(filter *CommitLogFilter, teams map[string]string) ([]OwnershipDTO, error) {
+	if gdb == nil {
+		return nil, errors.New("database not initialized")
+	}
+	ctx := context.Background()
+
+	byTeam := false
+	switch filter.GroupBy {
+	case "", "author":
+	case "team":
+		byTeam = true
+	default:
+		return nil, errors.New("invalid group, must be one of: author, team")
+	}
+
+	var files []ownedFileItem
+	query := gdb.NewSelect().
+		Model((*OwnershipModel)(nil)).
+		ColumnExpr("ow.repo_url, ow.branch_name, ow.path, ow.nickname").
+		ColumnExpr("SUM(ow.lines) AS lines").
+		Group("ow.repo_url", "ow.branch_name", "ow.path", "ow.nickname")
+	if filter.RepoUrl != "" {
+		query.Where("ow.repo_url IN (?)", bun.In(strings.Split(filter.RepoUrl, ",")))
+	}
+	if filter.BranchName != "" {
+		query.Where("ow.branch_name IN (?)", bun.In(strings.Split(filter.BranchName, ",")))
+	}
+	wherePathUnder(query, "ow.path", filter.Path)
+	if err := query.Scan(ctx, &files); err != nil {
+		return nil, err
+	}
+
+	depth := filter.Depth
+	if depth <= 0 {
+		depth = 1
+	}
+	base := filter.Path[:strings.LastIndex(filter.Path, "/")+1]
+	var nicknames map[string]bool
+	if filter.Nickname != "" {
+		nicknames = make(map[string]bool)
+		for _, nickname := range strings.Split(filter.Nickname, ",") {
+			nicknames[nickname] = true
+		}
+	}
+
+	stats := make([]*OwnershipDTO, 0)
+	index := make(map[string]*OwnershipDTO)
+	paths := make(map[string]map[string]bool)
+	owners := make(map[string]map[string]*OwnerDTO)
+	ownerPaths := make(map[string]map[string]map[string]bool)
+	for _, file := range files {
+		directory := DirectoryOf(base, file.Path, depth)
+		key := file.RepoUrl + "\x00" + file.BranchName + "\x00" + directory
+		stat, ok := index[key]
+		if !ok {
+			stat = &OwnershipDTO{RepoUrl: file.RepoUrl, BranchName: file.BranchName, Path: directory}
+			index[key] = stat
+			stats = append(stats, stat)
+			paths[key] = make(map[string]bool)
+			owners[key] = make(map[string]*OwnerDTO)
+			ownerPaths[key] = make(map[string]map[string]bool)
+		}
+		stat.Lines += file.Lines
+		paths[key][file.Path] = true
+
+		// the nickname filter selects owners, shares stay relative to all lines
+		if nicknames != nil && !nicknames[file.Nickname] {
+			continue
+		}
+		owner := &OwnerDTO{Nickname: file.Nickname, Team: teams[file.Nickname]}
+		ownerKey := file.Nickname
+		if byTeam {
+			owner.Nickname = ""
+			ownerKey = owner.Team
+		}
+		if existing, ok := owners[key][ownerKey]; ok {
+			owner = existing
+		} else {
+			owners[key][ownerKey] = owner
+			ownerPaths[key][ownerKey] = make(map[string]bool)
+		}
+		owner.Lines += file.Lines
+		ownerPaths[key][ownerKey][file.Path] = true
+	}
+
+	results := make([]OwnershipDTO, len(stats))
+	for i, stat := range stats {
+		key := stat.RepoUrl + "\x00" + stat.BranchName + "\x00" + stat.Path
+		stat.Files = len(paths[key])
+		stat.Owners = make([]OwnerDTO, 0, len(owners[key]))
+		for ownerKey, owner := range owners[key] {
+			owner.Files = len(ownerPaths[key][ownerKey])
+			owner.Share = percentOf(owner.Lines, stat.Lines)
+			stat.Owners = append(stat.Owners, *owner)
+		}
+		sort.Slice(stat.Owners, func(i, j int) bool {
+			if stat.Owners[i].Lines != stat.Owners[j].Lines {
+				return stat.Owners[i].Lines > stat.Owners[j].Lines
+			}
+			return stat.Owners[i].Nickname+stat.Owners[i].Team < stat.Owners[j].Nickname+stat.Owners[j].Team
+		})
+		results[i] = *stat
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Lines > results[j].Lines
+	})
+	return results, nil
+}
@@ -26,76 +26,69 @@ func GetCommitStatsByPeriodAndUser(filter *CommitLogFilter) ([]CommitPeriodStatI
 	ctx := context.Background()
 	var results []CommitPeriodStatItem
 
-	// === 根据数据库类型生成 period 表达式 ===
-	var periodExpr string
-	dbType := gdb.Dialect().Name()
+	periodExpr, err := getPeriodExpr(filter.Period, "cl.date")
+	if err != nil {
+		return nil, err
+	}
+
+	query := gdb.NewSelect().
+		Model((*CommitLogModel)(nil)).
+		ColumnExpr("cl.nickname").
+		ColumnExpr("COUNT(DISTINCT cl.commit_hash) AS commits").
+		ColumnExpr("SUM(cl.additions) AS additions").
+		ColumnExpr("SUM(cl.deletions) AS deletions").
+		ColumnExpr("SUM(cl.effectives) AS effectives").
+		ColumnExpr(periodExpr + " AS period").
+		GroupExpr("period, cl.nickname").
+		OrderExpr("period ASC")
+
+	filter.SelectQuery(query)
+
+	// === 执行查询 ===
+	if err := query.Scan(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
 
-	period := filter.Period
+// getPeriodExpr 根据数据库类型生成按日/周/月分组的 period 表达式
+func getPeriodExpr(period string, column string) (string, error) {
+	dbType := gdb.Dialect().Name()
 	switch strings.ToLower(period) {
 	case "day", "daily":
 		switch dbType {
 		case dialect.MySQL:
-			periodExpr = "DATE(date)"
+			return "DATE(" + column + ")", nil
 		case dialect.SQLite:
-			periodExpr = "DATE(date)"
+			return "DATE(" + column + ")", nil
 		case dialect.PG:
-			periodExpr = "TO_CHAR(date::date, 'YYYY-MM-DD')"
+			return "TO_CHAR(" + column + "::date, 'YYYY-MM-DD')", nil
 		default:
-			return nil, errors.New("unsupported db dialect for daily stats")
+			return "", errors.New("unsupported db dialect for daily stats")
 		}
 	case "week", "weekly":
 		switch dbType {
 		case dialect.MySQL:
-			periodExpr = "DATE_FORMAT(DATE_ADD(date, INTERVAL (6 - WEEKDAY(date)) DAY), '%Y-%m-%d')" // 周日
+			return "DATE_FORMAT(DATE_ADD(" + column + ", INTERVAL (6 - WEEKDAY(" + column + ")) DAY), '%Y-%m-%d')", nil // 周日
 		case dialect.SQLite:
-			periodExpr = "DATE(date, 'weekday 0')" // SQLite: weekday 0 = Sunday
+			return "DATE(" + column + ", 'weekday 0')", nil // SQLite: weekday 0 = Sunday
 		case dialect.PG:
-			periodExpr = "TO_CHAR(date_trunc('week', date + interval '6 days')::date, 'YYYY-MM-DD')" // 周日
+			return "TO_CHAR(date_trunc('week', " + column + " + interval '6 days')::date, 'YYYY-MM-DD')", nil // 周日
 		default:
-			return nil, errors.New("unsupported db dialect for weekly stats")
+			return "", errors.New("unsupported db dialect for weekly stats")
 		}
 	case "month", "monthly":
 		switch dbType {
 		case dialect.MySQL:
-			periodExpr = "DATE_FORMAT(date, '%Y-%m')"
+			return "DATE_FORMAT(" + column + ", '%Y-%m')", nil
 		case dialect.SQLite:
-			periodExpr = "strftime('%Y-%m', date)"
+			return "strftime('%Y-%m', " + column + ")", nil
 		case dialect.PG:
-			periodExpr = "to_char(date, 'YYYY-MM')"
+			return "to_char(" + column + ", 'YYYY-MM')", nil
 		default:
-			return nil, errors.New("unsupported db dialect for monthly stats")
+			return "", errors.New("unsupported db dialect for monthly stats")
 		}
 	default:
-		return nil, errors.New("invalid period, must be one of: day, week, month")
+		return "", errors.New("invalid period, must be one of: day, week, month")
 	}
-
-	// === 构建子查询，按 commit_hash 去重 ===
-	subq := gdb.NewSelect().
-		Model((*CommitLogModel)(nil)).
-		ColumnExpr("DISTINCT commit_hash").
-		Column("nickname").
-		Column("date").
-		Column("additions").
-		Column("deletions").
-		Column("effectives")
-
-	filter.SelectQuery(subq)
-
-	// === 外层统计 ===
-	query := gdb.NewSelect().
-		TableExpr("(?) AS t", subq).
-		ColumnExpr("nickname").
-		ColumnExpr("COUNT(commit_hash) AS commits"). // 子查询已 distinct，不需要再 DISTINCT
-		ColumnExpr("SUM(additions) AS additions").
-		ColumnExpr("SUM(deletions) AS deletions").
-		ColumnExpr("SUM(effectives) AS effectives").
-		ColumnExpr(periodExpr + " AS period").
-		GroupExpr("period, nickname").
-		OrderExpr("period ASC")
-
-	// === 执行查询 ===
-	if err := query.Scan(ctx, &results); err != nil {
-		return nil, err
-	}
-	return results, nil
 }
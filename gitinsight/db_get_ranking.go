@@ -8,7 +8,7 @@ import (
 )
 
 type Ranking struct {
-	bun.BaseModel `bun:"table:commit_log,alias:cl"`
+	bun.BaseModel `bun:"table:commits,alias:cl"`
 
 	Name     string `json:"name" bun:",notnull"`
 	Email    string `json:"email" bun:",notnull"`
@@ -18,6 +18,13 @@ type Ranking struct {
 	Deletions  int `json:"deletions" bun:",notnull"`
 	Effectives int `json:"effectives" bun:",notnull"`
 
+	// Churned are the added lines rewritten within the churn window,
+	// Reworked the ones rewritten by the same person
+	Churned    int     `json:"churned" bun:",notnull"`
+	Reworked   int     `json:"reworked" bun:",notnull"`
+	ChurnRate  float64 `json:"churnRate" bun:"-"`
+	ReworkRate float64 `json:"reworkRate" bun:"-"`
+
 	Projects int `json:"projects" bun:",notnull"`
 	Commits  int `json:"commits" bun:",notnull"`
 }
@@ -28,28 +35,17 @@ func GetRanking(filter *CommitLogFilter) ([]Ranking, error) {
 	}
 
 	ctx := context.Background()
-	subQuery := gdb.NewSelect().
-		Model((*CommitLogModel)(nil)).
-		ColumnExpr("DISTINCT commit_hash, nickname, author_name, author_email, additions, deletions, effectives, repo_url, date").
-		Where("is_merge = 0")
-
-	filter.SelectQuery(subQuery)
-
-	// 外层再统计
-	query := gdb.NewSelect().
-		TableExpr("(?) AS t", subQuery).
-		ColumnExpr("nickname").
-		ColumnExpr("GROUP_CONCAT(DISTINCT author_name) AS name").
-		ColumnExpr("GROUP_CONCAT(DISTINCT author_email) AS email").
-		ColumnExpr("SUM(additions) AS additions").
-		ColumnExpr("SUM(deletions) AS deletions").
-		ColumnExpr("SUM(effectives) AS effectives").
-		ColumnExpr("COUNT(DISTINCT repo_url) AS projects").
-		ColumnExpr("COUNT(DISTINCT commit_hash) AS commits").
-		Group("nickname")
+	query, err := selectCredits(filter)
+	if err != nil {
+		return nil, err
+	}
 
 	var ranking []Ranking
-	err := query.Scan(ctx, &ranking)
+	err = query.Scan(ctx, &ranking)
+	for i := range ranking {
+		ranking[i].ChurnRate = churnRate(ranking[i].Churned, ranking[i].Additions)
+		ranking[i].ReworkRate = churnRate(ranking[i].Reworked, ranking[i].Additions)
+	}
 	return ranking, err
 
 }
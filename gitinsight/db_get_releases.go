@@ -0,0 +1,152 @@
+package gitinsight
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReleaseItem is a tag with the statistics of the commits since the tag
+// before it in the same repository.
+type ReleaseItem struct {
+	RepoUrl     string    `json:"repoUrl"`
+	TagName     string    `json:"tagName"`
+	CommitHash  string    `json:"commitHash"`
+	Annotated   bool      `json:"annotated"`
+	TaggerName  string    `json:"taggerName"`
+	TaggerEmail string    `json:"taggerEmail"`
+	Message     string    `json:"message"`
+	Date        time.Time `json:"date"`
+
+	PreviousTag  string     `json:"previousTag"`
+	PreviousDate *time.Time `json:"previousDate"`
+	Days         float64    `json:"days"`
+
+	Commits      int      `json:"commits"`
+	Contributors int      `json:"contributors"`
+	Nicknames    []string `json:"nicknames"`
+	Additions    int      `json:"additions"`
+	Deletions    int      `json:"deletions"`
+	Effectives   int      `json:"effectives"`
+}
+
+type releaseStat struct {
+	RepoUrl      string `bun:"repo_url"`
+	TagName      string `bun:"tag_name"`
+	Commits      int    `bun:"commits"`
+	Contributors int    `bun:"contributors"`
+	Additions    int    `bun:"additions"`
+	Deletions    int    `bun:"deletions"`
+	Effectives   int    `bun:"effectives"`
+}
+
+// GetReleases returns the tags dated within the since and until of the
+// filter, newest first. The statistics cover the analyzed commits matching
+// the rest of the filter.
+func GetReleases(filter *CommitLogFilter) ([]ReleaseItem, error) {
+	if gdb == nil {
+		return nil, errors.New("database not initialized")
+	}
+	ctx := context.Background()
+
+	var repoUrls []string
+	if filter.RepoUrl != "" {
+		repoUrls = strings.Split(filter.RepoUrl, ",")
+	}
+	tags, err := GetRepoTags(repoUrls)
+	if err != nil {
+		return nil, err
+	}
+
+	var stats []releaseStat
+	query := gdb.NewSelect().
+		Model((*TagCommitModel)(nil)).
+		Join("JOIN commits AS cl ON cl.repo_url = tc.repo_url AND cl.commit_hash = tc.commit_hash").
+		ColumnExpr("tc.repo_url").
+		ColumnExpr("tc.tag_name").
+		ColumnExpr("COUNT(DISTINCT cl.commit_hash) AS commits").
+		ColumnExpr("COUNT(DISTINCT cl.nickname) AS contributors").
+		ColumnExpr("SUM(cl.additions) AS additions").
+		ColumnExpr("SUM(cl.deletions) AS deletions").
+		ColumnExpr("SUM(cl.effectives) AS effectives").
+		Group("tc.repo_url", "tc.tag_name")
+	// since and until select the releases, not the commits they contain
+	commitFilter := *filter
+	commitFilter.SinceTime = time.Time{}
+	commitFilter.UntilTime = time.Time{}
+	commitFilter.SelectQuery(query)
+	if err := query.Scan(ctx, &stats); err != nil {
+		return nil, err
+	}
+	statOf := make(map[string]releaseStat, len(stats))
+	for _, stat := range stats {
+		statOf[stat.RepoUrl+"\x00"+stat.TagName] = stat
+	}
+
+	// the nicknames are listed row by row, they may contain commas
+	var contributors []struct {
+		RepoUrl  string `bun:"repo_url"`
+		TagName  string `bun:"tag_name"`
+		Nickname string `bun:"nickname"`
+	}
+	query = gdb.NewSelect().
+		Model((*TagCommitModel)(nil)).
+		Join("JOIN commits AS cl ON cl.repo_url = tc.repo_url AND cl.commit_hash = tc.commit_hash").
+		Distinct().
+		ColumnExpr("tc.repo_url, tc.tag_name, cl.nickname").
+		OrderExpr("cl.nickname ASC")
+	commitFilter.SelectQuery(query)
+	if err := query.Scan(ctx, &contributors); err != nil {
+		return nil, err
+	}
+	nicknamesOf := make(map[string][]string)
+	for _, c := range contributors {
+		key := c.RepoUrl + "\x00" + c.TagName
+		nicknamesOf[key] = append(nicknamesOf[key], c.Nickname)
+	}
+
+	releases := make([]ReleaseItem, 0)
+	for i, tag := range tags {
+		stat := statOf[tag.RepoUrl+"\x00"+tag.TagName]
+		nicknames := nicknamesOf[tag.RepoUrl+"\x00"+tag.TagName]
+		if nicknames == nil {
+			nicknames = []string{}
+		}
+		release := ReleaseItem{
+			RepoUrl:      tag.RepoUrl,
+			TagName:      tag.TagName,
+			CommitHash:   tag.CommitHash,
+			Annotated:    tag.Annotated,
+			TaggerName:   tag.TaggerName,
+			TaggerEmail:  tag.TaggerEmail,
+			Message:      tag.Message,
+			Date:         tag.Date,
+			Commits:      stat.Commits,
+			Contributors: stat.Contributors,
+			Nicknames:    nicknames,
+			Additions:    stat.Additions,
+			Deletions:    stat.Deletions,
+			Effectives:   stat.Effectives,
+		}
+		if i > 0 && tags[i-1].RepoUrl == tag.RepoUrl {
+			previous := tags[i-1]
+			release.PreviousTag = previous.TagName
+			release.PreviousDate = &previous.Date
+			release.Days = math.Round(tag.Date.Sub(previous.Date).Hours()/24*100) / 100
+		}
+		if !filter.SinceTime.IsZero() && tag.Date.Before(filter.SinceTime) {
+			continue
+		}
+		if !filter.UntilTime.IsZero() && tag.Date.After(filter.UntilTime) {
+			continue
+		}
+		releases = append(releases, release)
+	}
+	sort.SliceStable(releases, func(i, j int) bool {
+		return releases[i].Date.After(releases[j].Date)
+	})
+	return releases, nil
+}
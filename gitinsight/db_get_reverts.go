@@ -0,0 +1,91 @@
+package gitinsight
+
+import (
+	"context"
+	"errors"
+	"sort"
+)
+
+// RevertStatItem counts the reverts of an author in a repository: the
+// commits they reverted and their commits that were reverted.
+type RevertStatItem struct {
+	RepoUrl  string `bun:"repo_url" json:"repoUrl"`
+	Nickname string `bun:"nickname" json:"nickname"`
+	Reverts  int    `bun:"reverts" json:"reverts"`
+	Reverted int    `bun:"reverted" json:"reverted"`
+}
+
+// GetRevertStats reports the reverts per author and repository, most reverts
+// first.
+func GetRevertStats(filter *CommitLogFilter) ([]RevertStatItem, error) {
+	if gdb == nil {
+		return nil, errors.New("database not initialized")
+	}
+	ctx := context.Background()
+
+	revertFilter := *filter
+	revertFilter.ExcludeReverts = false
+
+	var reverts []RevertStatItem
+	query := gdb.NewSelect().
+		Model((*CommitLogModel)(nil)).
+		ColumnExpr("cl.repo_url").
+		ColumnExpr("cl.nickname").
+		ColumnExpr("COUNT(DISTINCT cl.commit_hash) AS reverts").
+		Where("cl.is_revert = ?", true).
+		Group("cl.repo_url", "cl.nickname")
+	revertFilter.SelectQuery(query)
+	if err := query.Scan(ctx, &reverts); err != nil {
+		return nil, err
+	}
+
+	var reverted []RevertStatItem
+	query = gdb.NewSelect().
+		Model((*CommitLogModel)(nil)).
+		ColumnExpr("cl.repo_url").
+		ColumnExpr("cl.nickname").
+		ColumnExpr("COUNT(DISTINCT cl.commit_hash) AS reverted").
+		Where("EXISTS (?)", gdb.NewSelect().
+			TableExpr("commits AS rv").
+			ColumnExpr("1").
+			Where("rv.repo_url = cl.repo_url").
+			Where("rv.reverts_hash = cl.commit_hash")).
+		Group("cl.repo_url", "cl.nickname")
+	revertFilter.SelectQuery(query)
+	if err := query.Scan(ctx, &reverted); err != nil {
+		return nil, err
+	}
+
+	items := make(map[string]*RevertStatItem)
+	item := func(repoUrl string, nickname string) *RevertStatItem {
+		key := repoUrl + "\x00" + nickname
+		if items[key] == nil {
+			items[key] = &RevertStatItem{RepoUrl: repoUrl, Nickname: nickname}
+		}
+		return items[key]
+	}
+	for _, row := range reverts {
+		item(row.RepoUrl, row.Nickname).Reverts = row.Reverts
+	}
+	for _, row := range reverted {
+		item(row.RepoUrl, row.Nickname).Reverted = row.Reverted
+	}
+
+	results := make([]RevertStatItem, 0, len(items))
+	for _, item := range items {
+		results = append(results, *item)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Reverts != results[j].Reverts {
+			return results[i].Reverts > results[j].Reverts
+		}
+		if results[i].Reverted != results[j].Reverted {
+			return results[i].Reverted > results[j].Reverted
+		}
+		if results[i].RepoUrl != results[j].RepoUrl {
+			return results[i].RepoUrl < results[j].RepoUrl
+		}
+		return results[i].Nickname < results[j].Nickname
+	})
+	return results, nil
+}
@@ -0,0 +1,193 @@
+package gitinsight
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// OwnershipModel is the number of lines of a file on the head of a branch
+// that an author last changed.
+type OwnershipModel struct {
+	bun.BaseModel `bun:"table:ownership,alias:ow"`
+
+	ID         int64  `json:"id" bun:"id,pk,autoincrement"`
+	RepoUrl    string `json:"repoUrl" bun:",notnull"`
+	BranchName string `json:"branchName" bun:",notnull"`
+	// CommitHash is the branch head the snapshot was taken on
+	CommitHash string `json:"commitHash" bun:",notnull"`
+	Path       string `json:"path" bun:",notnull"`
+
+	AuthorName  string `json:"authorName" bun:",notnull"`
+	AuthorEmail string `json:"authorEmail" bun:",notnull"`
+	Nickname    string `json:"nickname" bun:",notnull"`
+	Lines       int    `json:"lines" bun:",notnull"`
+}
+
+// OwnershipStateModel is the branch head the ownership snapshot of a branch
+// was last taken on, also when none of its files were counted.
+type OwnershipStateModel struct {
+	bun.BaseModel `bun:"table:ownership_state,alias:os"`
+
+	ID         int64  `json:"id" bun:"id,pk,autoincrement"`
+	RepoUrl    string `json:"repoUrl" bun:",notnull"`
+	BranchName string `json:"branchName" bun:",notnull"`
+	CommitHash string `json:"commitHash" bun:",notnull"`
+
+	UpdatedAt time.Time `json:"updatedAt" bun:",notnull"`
+}
+
+func InitOwnership() error {
+	ctx := context.Background()
+	_, err := gdb.NewCreateTable().Model((*OwnershipModel)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = gdb.NewCreateTable().Model((*OwnershipStateModel)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = gdb.NewCreateIndex().Model((*OwnershipStateModel)(nil)).Unique().Index("uk_ownership_state").Column("repo_url", "branch_name").IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = gdb.NewCreateIndex().Model((*OwnershipModel)(nil)).Index("idx_ownership_branch").Column("repo_url", "branch_name").IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = gdb.NewCreateIndex().Model((*OwnershipModel)(nil)).Index("idx_ownership_nickname").Column("nickname").IfNotExists().Exec(ctx)
+	return err
+}
+
+func ToOwnershipModels(repoUrl string, branchName string, commitHash string, owned []OwnedLines, identities *IdentityResolver) []OwnershipModel {
+	ownershipModels := make([]OwnershipModel, len(owned))
+	for i, o := range owned {
+		ownershipModels[i] = OwnershipModel{
+			RepoUrl:     repoUrl,
+			BranchName:  branchName,
+			CommitHash:  commitHash,
+			Path:        o.Path,
+			AuthorName:  o.AuthorName,
+			AuthorEmail: o.AuthorEmail,
+			Nickname:    identities.Nickname(o.AuthorName, o.AuthorEmail),
+			Lines:       o.Lines,
+		}
+	}
+	return ownershipModels
+}
+
+// GetOwnershipHead returns the branch head of the stored snapshot, empty when
+// there is none.
+func GetOwnershipHead(repoUrl string, branchName string) (string, error) {
+	if gdb == nil {
+		return "", errors.New("database not initialized")
+	}
+	ctx := context.Background()
+	var commitHash string
+	err := gdb.NewSelect().Model((*OwnershipStateModel)(nil)).
+		Column("commit_hash").
+		Where("repo_url = ?", repoUrl).
+		Where("branch_name = ?", branchName).
+		Limit(1).
+		Scan(ctx, &commitHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return commitHash, err
+}
+
+// ReplaceOwnership replaces the snapshot of a branch taken on commitHash.
+func ReplaceOwnership(repoUrl string, branchName string, commitHash string, rows []OwnershipModel) error {
+	if gdb == nil {
+		return errors.New("database not initialized")
+	}
+	ctx := context.Background()
+	return gdb.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for _, model := range []interface{}{(*OwnershipModel)(nil), (*OwnershipStateModel)(nil)} {
+			_, err := tx.NewDelete().Model(model).
+				Where("repo_url = ?", repoUrl).
+				Where("branch_name = ?", branchName).
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		_, err := insertSegments(ctx, tx, rows)
+		if err != nil {
+			return err
+		}
+		state := &OwnershipStateModel{
+			RepoUrl:    repoUrl,
+			BranchName: branchName,
+			CommitHash: commitHash,
+			UpdatedAt:  time.Now().UTC(),
+		}
+		_, err = tx.NewInsert().Model(state).Exec(ctx)
+		return err
+	})
+}
+
+// DeleteStaleOwnership removes the snapshots of the branches of a repository
+// other than branchNames, the branches it snapshots now. It returns the
+// number of removed rows.
+func DeleteStaleOwnership(repoUrl string, branchNames []string) (int64, error) {
+	if gdb == nil {
+		return 0, errors.New("database not initialized")
+	}
+	ctx := context.Background()
+	var rowsAffected int64
+	err := gdb.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		rowsAffected, err = deleteStaleOwnership(ctx, tx, repoUrl, branchNames)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected, nil
+}
+
+// deleteStaleOwnership removes the snapshots and their heads of the branches
+// of a repository other than branchNames.
+func deleteStaleOwnership(ctx context.Context, tx bun.Tx, repoUrl string, branchNames []string) (int64, error) {
+	query := tx.NewDelete().Model((*OwnershipModel)(nil)).Where("repo_url = ?", repoUrl)
+	stateQuery := tx.NewDelete().Model((*OwnershipStateModel)(nil)).Where("repo_url = ?", repoUrl)
+	if len(branchNames) > 0 {
+		query.Where("branch_name NOT IN (?)", bun.In(branchNames))
+		stateQuery.Where("branch_name NOT IN (?)", bun.In(branchNames))
+	}
+	result, err := query.Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	_, err = stateQuery.Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected, nil
+}
+
+func ResetOwnership() error {
+	if gdb == nil {
+		return errors.New("database not initialized")
+	}
+	ctx := context.Background()
+	_, err := gdb.NewDropTable().Model((*OwnershipModel)(nil)).IfExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = gdb.NewDropTable().Model((*OwnershipStateModel)(nil)).IfExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	log.Println("Reset ownership")
+	return nil
+}
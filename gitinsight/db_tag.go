@@ -0,0 +1,169 @@
+package gitinsight
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// TagModel is a tag of a repository with the commit it points at.
+type TagModel struct {
+	bun.BaseModel `bun:"table:tags,alias:tg"`
+
+	ID         int64  `json:"id" bun:"id,pk,autoincrement"`
+	RepoUrl    string `json:"repoUrl" bun:",notnull"`
+	TagName    string `json:"tagName" bun:",notnull"`
+	CommitHash string `json:"commitHash" bun:",notnull"`
+
+	Annotated   bool      `json:"annotated" bun:",notnull"`
+	TaggerName  string    `json:"taggerName" bun:",notnull"`
+	TaggerEmail string    `json:"taggerEmail" bun:",notnull"`
+	Message     string    `json:"message" bun:",notnull,type:text"`
+	Date        time.Time `json:"date" bun:",notnull"`
+
+	// Since is the boundary the commits of the tag were walked back to
+	Since string `json:"since" bun:",notnull"`
+}
+
+// TagCommitModel records that a commit is released by a tag, i.e. it is
+// reachable from the tag but not from the tag before it.
+type TagCommitModel struct {
+	bun.BaseModel `bun:"table:tag_commits,alias:tc"`
+
+	ID         int64  `json:"id" bun:"id,pk,autoincrement"`
+	RepoUrl    string `json:"repoUrl" bun:",notnull"`
+	TagName    string `json:"tagName" bun:",notnull"`
+	CommitHash string `json:"commitHash" bun:",notnull"`
+	ChangeHash string `json:"changeHash" bun:",notnull"`
+}
+
+func InitTag() error {
+	ctx := context.Background()
+	_, err := gdb.NewCreateTable().Model((*TagModel)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = gdb.NewCreateTable().Model((*TagCommitModel)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	added, err := addColumnIfNotExists(ctx, (*TagCommitModel)(nil), "change_hash", "VARCHAR(255) NOT NULL DEFAULT ''")
+	if err != nil {
+		return err
+	}
+	if added {
+		// drop the tags so their commits are walked again with their changes
+		_, err = gdb.NewDelete().Model((*TagModel)(nil)).Where("1 = 1").Exec(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = gdb.NewCreateIndex().Model((*TagModel)(nil)).Unique().Index("uk_tags").Column("repo_url", "tag_name").IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = gdb.NewCreateIndex().Model((*TagCommitModel)(nil)).Unique().Index("uk_tag_commits").Column("repo_url", "tag_name", "commit_hash").IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = gdb.NewCreateIndex().Model((*TagCommitModel)(nil)).Index("idx_tag_commits_hash").Column("repo_url", "commit_hash").IfNotExists().Exec(ctx)
+	return err
+}
+
+func ToTagModels(repoUrl string, since string, tags []Tag) []TagModel {
+	tagModels := make([]TagModel, len(tags))
+	for i, tag := range tags {
+		tagModels[i] = TagModel{
+			RepoUrl:     repoUrl,
+			TagName:     tag.Name,
+			CommitHash:  tag.CommitHash,
+			Annotated:   tag.Annotated,
+			TaggerName:  tag.TaggerName,
+			TaggerEmail: tag.TaggerEmail,
+			Message:     tag.Message,
+			Date:        tag.Date,
+			Since:       since,
+		}
+	}
+	return tagModels
+}
+
+func ToTagCommitModels(repoUrl string, tagCommits map[string][]TagCommit) []TagCommitModel {
+	tagCommitModels := make([]TagCommitModel, 0)
+	for _, tagName := range sortedKeys(tagCommits) {
+		for _, tagCommit := range tagCommits[tagName] {
+			tagCommitModels = append(tagCommitModels, TagCommitModel{
+				RepoUrl:    repoUrl,
+				TagName:    tagName,
+				CommitHash: tagCommit.CommitHash,
+				ChangeHash: tagCommit.ChangeHash,
+			})
+		}
+	}
+	return tagCommitModels
+}
+
+// GetRepoTags returns the stored tags of the repositories, oldest first.
+func GetRepoTags(repoUrls []string) ([]TagModel, error) {
+	if gdb == nil {
+		return nil, errors.New("database not initialized")
+	}
+	ctx := context.Background()
+	tags := make([]TagModel, 0)
+	query := gdb.NewSelect().Model(&tags).Order("tg.repo_url", "tg.date", "tg.tag_name")
+	if len(repoUrls) > 0 {
+		query.Where("tg.repo_url IN (?)", bun.In(repoUrls))
+	}
+	err := query.Scan(ctx)
+	return tags, err
+}
+
+// UpdateRepoTags replaces the tags of a repository and the commits released
+// by the replaced tags, the commits of the other tags are kept.
+func UpdateRepoTags(repoUrl string, tags []TagModel, replaced []string, tagCommits []TagCommitModel) error {
+	if gdb == nil {
+		return errors.New("database not initialized")
+	}
+	ctx := context.Background()
+	return gdb.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewDelete().Model((*TagModel)(nil)).Where("repo_url = ?", repoUrl).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if len(replaced) > 0 {
+			_, err = tx.NewDelete().Model((*TagCommitModel)(nil)).
+				Where("repo_url = ?", repoUrl).
+				Where("tag_name IN (?)", bun.In(replaced)).
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = insertSegments(ctx, tx, tags)
+		if err != nil {
+			return err
+		}
+		_, err = insertSegments(ctx, tx, tagCommits)
+		return err
+	})
+}
+
+func ResetTag() error {
+	if gdb == nil {
+		return errors.New("database not initialized")
+	}
+	ctx := context.Background()
+	_, err := gdb.NewDropTable().Model((*TagModel)(nil)).IfExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = gdb.NewDropTable().Model((*TagCommitModel)(nil)).IfExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	log.Println("Reset tags")
+	return nil
+}
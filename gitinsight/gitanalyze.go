@@ -1,8 +1,8 @@
 package gitinsight
 
 import (
-	"bufio"
-	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -21,6 +21,12 @@ type CommitLog struct {
 	Hash          string
 	Message       string
 	MessageType   string
+	Scope         string
+	Breaking      bool
+	Subject       string
+	Footers       []CommitFooter
+	IsRevert      bool
+	RevertsHash   string
 	IsMerge       bool
 	Date          time.Time
 	CommitterDate time.Time
@@ -28,11 +34,15 @@ type CommitLog struct {
 	Additions     int
 	Deletions     int
 	Effectives    int
+	Introduced    int
 	LanguageStats string
+	Files         []CommitFile
+	Churn         []CommitChurn
 
 	AuthorName  string
 	AuthorEmail string
 	Nickname    string
+	CoAuthors   []CoAuthor
 }
 
 type BranchState struct {
@@ -46,14 +56,9 @@ func GetLocalCommitState(repoPath string, filter CheckUpTodateFilter) (*BranchSt
 		return nil, err
 	}
 
-	// Try local branch first
-	branchRef, err := repo.Reference(plumbing.ReferenceName("refs/heads/"+filter.BranchName), true)
+	branchRef, err := GetBranchRef(repo, filter.BranchName)
 	if err != nil {
-		// If local branch does not exist, try remote branch
-		branchRef, err = repo.Reference(plumbing.ReferenceName("refs/remotes/origin/"+filter.BranchName), true)
-		if err != nil {
-			return nil, fmt.Errorf("could not get branch reference: %v", err)
-		}
+		return nil, err
 	}
 	// Get the commit history
 	cIter, err := repo.Log(&git.LogOptions{
@@ -97,125 +102,228 @@ func GetLocalCommitState(repoPath string, filter CheckUpTodateFilter) (*BranchSt
 	}, nil
 }
 
-func AnalyzeRepoCommitLogs(config *Config, repoPath string, filter CheckUpTodateFilter) ([]CommitLog, error) {
+func AnalyzeRepoCommitLogs(config *Config, repoPath string, filter CheckUpTodateFilter, known map[string]bool, analyzed map[string]bool) ([]CommitLog, []string, error) {
 	repo, err := git.PlainOpen(repoPath)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	// Get branch stats
 	log.Printf("🚀  Analyzing branch commit logs: %s %s\n", repoPath, filter.BranchName)
-	commitLogs, err := AnalyzeBranchCommitLogs(config, repo, filter)
+	commitLogs, hashes, err := AnalyzeBranchCommitLogs(config, repo, filter, known, analyzed)
 	if err != nil {
 		log.Printf("  ⚠️ Error analyzing branch commit logs %s: %v\n", filter.BranchName, err)
-		return nil, err
+		return nil, nil, err
 	}
-	log.Printf("    Found %s %s %d commits\n", repoPath, filter.BranchName, len(commitLogs))
-	return commitLogs, nil
+	log.Printf("    Found %s %s %d commits, %d analyzed\n", repoPath, filter.BranchName, len(hashes), len(commitLogs))
+	return commitLogs, hashes, nil
 }
 
-func AnalyzeBranchCommitLogs(config *Config, repo *git.Repository, filter CheckUpTodateFilter) ([]CommitLog, error) {
-	// Get the branch reference (try local first, then remote)
-	var branchRef *plumbing.Reference
-	var err error
-
-	// Try local branch first
-	branchRef, err = repo.Reference(plumbing.ReferenceName("refs/heads/"+filter.BranchName), true)
+// AnalyzeBranchCommitLogs walks the branch back to the since boundary and
+// returns the hashes of the visited commits together with the analysis of the
+// ones not in analyzed yet. The walk does not go past commits in known, which
+// are the commits already linked to the branch.
+func AnalyzeBranchCommitLogs(config *Config, repo *git.Repository, filter CheckUpTodateFilter, known map[string]bool, analyzed map[string]bool) ([]CommitLog, []string, error) {
+	branchRef, err := GetBranchRef(repo, filter.BranchName)
 	if err != nil {
-		// If local branch does not exist, try remote branch
-		branchRef, err = repo.Reference(plumbing.ReferenceName("refs/remotes/origin/"+filter.BranchName), true)
-		if err != nil {
-			return nil, fmt.Errorf("could not get branch reference: %v", err)
-		}
+		return nil, nil, err
 	}
-
-	// Get the commit history
-	cIter, err := repo.Log(&git.LogOptions{
-		From: branchRef.Hash(),
-	})
-
+	head, err := repo.CommitObject(branchRef.Hash())
 	if err != nil {
-		return nil, fmt.Errorf("could not get commit history: %v", err)
+		return nil, nil, fmt.Errorf("could not get head commit: %v", err)
 	}
 
-	commitLogs := make([]CommitLog, 0)
+	isLimit := object.CommitFilter(func(c *object.Commit) bool {
+		return known[c.Hash.String()] || IsBeforeSince(c, filter)
+	})
+	isValid := object.CommitFilter(func(c *object.Commit) bool {
+		return !isLimit(c)
+	})
+	cIter := object.NewFilterCommitIter(head, &isValid, &isLimit)
+	defer cIter.Close()
 
+	analyzer := NewCommitAnalyzer(config, repo, filter.RepoUrl)
+	commitLogs := make([]CommitLog, 0)
+	hashes := make([]string, 0)
 	for {
 		c, err := cIter.Next()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-
-		if IsBeforeSince(c, filter) {
-			break
+		hashes = append(hashes, c.Hash.String())
+		if analyzed[c.Hash.String()] {
+			continue
 		}
+		commitLogs = append(commitLogs, AnalyzeCommit(analyzer, c, filter))
+	}
+	return commitLogs, hashes, nil
+}
 
-		nickname := FindNickname(config, c.Author.Name, c.Author.Email)
-		var additions, deletions int
-		if len(c.ParentHashes) == 0 {
-			// 初始提交
-			additions, deletions, _ = CountLinesInCommit(c)
-		} else {
-			additions, deletions = GetCommitDiff(c)
-		}
+// CommitAnalyzer is the configuration of a repository that applies to the
+// analysis of each of its commits.
+type CommitAnalyzer struct {
+	Repo        *git.Repository
+	Diff        *DiffOptions
+	Identities  *IdentityResolver
+	CommitTypes []string
+	ChurnWindow time.Duration
+}
 
-		committerDate := c.Committer.When.UTC()
-		if committerDate.IsZero() {
-			committerDate = c.Author.When.UTC()
-		}
+func NewCommitAnalyzer(config *Config, repo *git.Repository, repoUrl string) *CommitAnalyzer {
+	return &CommitAnalyzer{
+		Repo:        repo,
+		Diff:        NewDiffOptions(config, repoUrl),
+		Identities:  NewRepoIdentityResolver(config, repo),
+		CommitTypes: config.CommitTypes,
+		ChurnWindow: config.Churn.Window(),
+	}
+}
+
+// AnalysisFingerprint identifies the configuration the diffs of the commits
+// of a repository depend on: its path rules, the languages, the rename
+// detection, the merge diff and the churn window. Stored commits analyzed
+// with another fingerprint are diffed again. Commit types and identities are
+// not part of it, they are applied to the stored commits on each sync.
+func AnalysisFingerprint(config *Config, repoUrl string) string {
+	diff := NewDiffOptions(config, repoUrl)
+	data, err := json.Marshal(struct {
+		Include     []string
+		Exclude     []string
+		Languages   []LanguageMapping
+		Renames     Renames
+		MergeDiff   string
+		ChurnWindow time.Duration
+	}{
+		Include:     diff.Paths.include,
+		Exclude:     diff.Paths.exclude,
+		Languages:   config.Languages,
+		Renames:     diff.Renames,
+		MergeDiff:   diff.mergeDiff(),
+		ChurnWindow: config.Churn.Window(),
+	})
+	if err != nil {
+		log.Printf("  ⚠️ Error fingerprinting the analysis of %s: %v\n", repoUrl, err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
 
-		languageStats := GetLanguageStatPatch(c)
-		languageStatsJson, _ := json.MarshalIndent(languageStats, "", "  ")
-		commitLog := CommitLog{
-			Hash:          c.Hash.String(),
-			Message:       strings.TrimSpace(c.Message),
-			MessageType:   GetMessageType(c.Message),
-			IsMerge:       len(c.ParentHashes) > 1,
-			Date:          c.Author.When.UTC(),
-			CommitterDate: committerDate,
-			Additions:     additions,
-			Deletions:     deletions,
-			Effectives:    int(math.Max(float64(additions-deletions), 0)),
-			AuthorName:    c.Author.Name,
-			AuthorEmail:   c.Author.Email,
-			Nickname:      nickname,
-			LanguageStats: string(languageStatsJson),
+func AnalyzeCommit(analyzer *CommitAnalyzer, c *object.Commit, filter CheckUpTodateFilter) CommitLog {
+	nickname := analyzer.Identities.Nickname(c.Author.Name, c.Author.Email)
+	files, err := GetCommitFiles(c, analyzer.Diff)
+	if err != nil {
+		log.Printf("  ⚠️ Error diffing commit %s: %v\n", c.Hash.String(), err)
+	}
+	additions, deletions := SumCommitFiles(files)
+	// merges skipped in skip mode introduce no lines either
+	introduced := 0
+	if c.NumParents() > 1 && analyzer.Diff.mergeDiff() == MergeDiffCombined {
+		introduced = additions
+	} else if c.NumParents() > 1 && analyzer.Diff.mergeDiff() != MergeDiffSkip {
+		combined, err := GetCombinedDiffFiles(c, analyzer.Diff)
+		if err != nil {
+			log.Printf("  ⚠️ Error diffing merge %s: %v\n", c.Hash.String(), err)
 		}
-		commitLogs = append(commitLogs, commitLog)
-		log.Printf("    🏷️  Analyzed commit logs: %s %s %s %s %s %s %s %s\n",
-			filter.RepoUrl, filter.BranchName, c.Hash.String(), nickname, c.Author.Name, c.Author.Email, c.Author.When, c.Message)
+		introduced, _ = SumCommitFiles(combined)
+	}
+	churn, err := GetCommitChurn(c, analyzer.ChurnWindow, analyzer.Diff)
+	if err != nil {
+		log.Printf("  ⚠️ Error measuring churn of commit %s: %v\n", c.Hash.String(), err)
 	}
 
-	return commitLogs, nil
-}
+	committerDate := c.Committer.When.UTC()
+	if committerDate.IsZero() {
+		committerDate = c.Author.When.UTC()
+	}
 
-// CountLinesInCommit 统计提交中所有文件的行数
-func CountLinesInCommit(commit *object.Commit) (additions int, deletions int, err error) {
-	additions = 0
-	deletions = 0
+	conventional := ParseConventionalCommit(c.Message, analyzer.CommitTypes)
+	isRevert, revertsHash := ParseRevert(c.Message, analyzer.CommitTypes)
+	languageStats := GetCommitLanguageStats(files)
+	languageStatsJson, _ := json.MarshalIndent(languageStats, "", "  ")
+	commitLog := CommitLog{
+		Hash:          c.Hash.String(),
+		Message:       strings.TrimSpace(c.Message),
+		MessageType:   conventional.Type,
+		Scope:         conventional.Scope,
+		Breaking:      conventional.Breaking,
+		Subject:       conventional.Subject,
+		Footers:       conventional.Footers,
+		IsRevert:      isRevert,
+		RevertsHash:   analyzer.resolveHash(revertsHash),
+		IsMerge:       len(c.ParentHashes) > 1,
+		Date:          c.Author.When.UTC(),
+		CommitterDate: committerDate,
+		Additions:     additions,
+		Deletions:     deletions,
+		Effectives:    int(math.Max(float64(additions-deletions), 0)),
+		Introduced:    introduced,
+		AuthorName:    c.Author.Name,
+		AuthorEmail:   c.Author.Email,
+		Nickname:      nickname,
+		CoAuthors:     ResolveCoAuthors(ParseCoAuthors(c.Message), nickname, analyzer.Identities),
+		LanguageStats: string(languageStatsJson),
+		Files:         files,
+		Churn:         churn,
+	}
+	log.Printf("    🏷️  Analyzed commit logs: %s %s %s %s %s %s %s %s\n",
+		filter.RepoUrl, filter.BranchName, c.Hash.String(), nickname, c.Author.Name, c.Author.Email, c.Author.When, c.Message)
+	return commitLog
+}
 
-	tree, err := commit.Tree()
+// resolveHash expands an abbreviated commit hash of the repository, keeping
+// hashes that do not resolve as they are.
+func (analyzer *CommitAnalyzer) resolveHash(hash string) string {
+	if hash == "" || len(hash) == 40 || analyzer.Repo == nil {
+		return hash
+	}
+	resolved, err := analyzer.Repo.ResolveRevision(plumbing.Revision(hash))
 	if err != nil {
-		return 0, 0, err
+		return hash
 	}
+	return resolved.String()
+}
 
-	err = tree.Files().ForEach(func(f *object.File) error {
-		content, err := f.Contents()
+// GetBranchRef resolves a branch name, trying the local branch first and the
+// origin remote branch second.
+func GetBranchRef(repo *git.Repository, branchName string) (*plumbing.Reference, error) {
+	branchRef, err := repo.Reference(plumbing.ReferenceName("refs/heads/"+branchName), true)
+	if err != nil {
+		// If local branch does not exist, try remote branch
+		branchRef, err = repo.Reference(plumbing.ReferenceName("refs/remotes/origin/"+branchName), true)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("could not get branch reference: %v", err)
 		}
+	}
+	return branchRef, nil
+}
 
-		// 用 bufio.Scanner 逐行统计
-		scanner := bufio.NewScanner(bytes.NewReader([]byte(content)))
-		count := 0
-		for scanner.Scan() {
-			count++
-		}
-		additions += count // 初始提交全部算作新增
-		return scanner.Err()
-	})
+// IsAncestorCommit reports whether ancestor is reachable from head. A missing
+// ancestor object, e.g. after a force-push and gc, is not an ancestor.
+func IsAncestorCommit(repo *git.Repository, ancestor string, head plumbing.Hash) bool {
+	ancestorCommit, err := repo.CommitObject(plumbing.NewHash(ancestor))
+	if err != nil {
+		return false
+	}
+	headCommit, err := repo.CommitObject(head)
+	if err != nil {
+		return false
+	}
+	isAncestor, err := ancestorCommit.IsAncestor(headCommit)
+	if err != nil {
+		return false
+	}
+	return isAncestor
+}
 
-	return additions, deletions, err
+// CountLinesInCommit 统计提交中所有文件的行数
+func CountLinesInCommit(commit *object.Commit, opts *DiffOptions) (additions int, deletions int, err error) {
+	files, err := CountFilesInCommit(commit, opts)
+	if err != nil {
+		return 0, 0, err
+	}
+	// 初始提交全部算作新增
+	additions, deletions = SumCommitFiles(files)
+	return additions, deletions, nil
 }
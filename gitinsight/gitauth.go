@@ -0,0 +1,142 @@
+package gitinsight
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/plumbing/transport/http"
+	"github.com/go-git/go-git/v6/plumbing/transport/ssh"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+const defaultSSHUser = "git"
+
+// IsSSHUrl reports whether the repository url is served over ssh,
+// either as ssh://host/path or as scp-like user@host:path.
+func IsSSHUrl(url string) bool {
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		return false
+	}
+	return endpoint.Protocol == "ssh"
+}
+
+// GetAuthMethod builds the go-git auth method matching the url scheme:
+// ssh urls authenticate with a private key or an ssh agent, http(s) urls
+// with basic auth.
+func GetAuthMethod(repoInfo *Repo) (transport.AuthMethod, error) {
+	endpoint, err := transport.NewEndpoint(repoInfo.Url)
+	if err != nil {
+		return nil, err
+	}
+
+	switch endpoint.Protocol {
+	case "ssh":
+		return getSSHAuthMethod(repoInfo, endpoint)
+	case "http", "https":
+		if repoInfo.User != "" && repoInfo.Password != "" {
+			return &http.BasicAuth{
+				Username: repoInfo.User,
+				Password: repoInfo.Password,
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+// sshAgents are the clients of the ssh agents dialed so far by socket, kept
+// open for the signers they hand out and shared by every sync.
+var (
+	sshAgentsMu sync.Mutex
+	sshAgents   = make(map[string]agent.ExtendedAgent)
+)
+
+// getSSHAgentSigners returns the signers of the ssh agent at socket, dialing
+// it once. A failing client is dropped so that the next call dials again,
+// e.g. after the agent restarted.
+func getSSHAgentSigners(socket string) ([]gossh.Signer, error) {
+	sshAgentsMu.Lock()
+	defer sshAgentsMu.Unlock()
+	client, ok := sshAgents[socket]
+	if !ok {
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			return nil, fmt.Errorf("error connecting to ssh agent %s: %v", socket, err)
+		}
+		client = agent.NewClient(conn)
+		sshAgents[socket] = client
+	}
+	signers, err := client.Signers()
+	if err != nil {
+		delete(sshAgents, socket)
+		if closer, ok := client.(io.Closer); ok {
+			closer.Close()
+		}
+		return nil, fmt.Errorf("error listing the keys of ssh agent %s: %v", socket, err)
+	}
+	return signers, nil
+}
+
+// getSSHAuthMethod authenticates as the user of the url, or git. The user of
+// the repository is left out, as it may be the one of http urls.
+func getSSHAuthMethod(repoInfo *Repo, endpoint *transport.Endpoint) (transport.AuthMethod, error) {
+	user := endpoint.User
+	if user == "" {
+		user = defaultSSHUser
+	}
+
+	var hostKeyCallback gossh.HostKeyCallback
+	if repoInfo.KnownHosts != "" {
+		callback, err := ssh.NewKnownHostsCallback(ExpandHome(repoInfo.KnownHosts))
+		if err != nil {
+			return nil, fmt.Errorf("could not load known_hosts %s: %v", repoInfo.KnownHosts, err)
+		}
+		hostKeyCallback = callback
+	}
+
+	// Priority: 1. Private key, 2. Configured agent socket, 3. SSH_AUTH_SOCK
+	if repoInfo.PrivateKey != "" {
+		auth, err := ssh.NewPublicKeysFromFile(user, ExpandHome(repoInfo.PrivateKey), repoInfo.Passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("could not load private key %s: %v", repoInfo.PrivateKey, err)
+		}
+		auth.HostKeyCallback = hostKeyCallback
+		return auth, nil
+	}
+
+	socket := ExpandHome(repoInfo.SSHAgent)
+	if socket == "" {
+		socket = os.Getenv("SSH_AUTH_SOCK")
+	}
+	if socket == "" {
+		return nil, fmt.Errorf("no private key or ssh agent configured for %s", repoInfo.Url)
+	}
+	return &ssh.PublicKeysCallback{
+		User: user,
+		Callback: func() ([]gossh.Signer, error) {
+			return getSSHAgentSigners(socket)
+		},
+		HostKeyCallbackHelper: ssh.HostKeyCallbackHelper{
+			HostKeyCallback: hostKeyCallback,
+		},
+	}, nil
+}
+
+// ExpandHome resolves a leading ~ to the current user's home directory.
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
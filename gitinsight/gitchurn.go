@@ -0,0 +1,137 @@
+package gitinsight
+
+import (
+	"log"
+	"time"
+
+	"github.com/go-git/go-git/v6"
+	fdiff "github.com/go-git/go-git/v6/plumbing/format/diff"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/utils/merkletrie"
+)
+
+// Churn configures the rework analysis, which blames the lines every commit
+// modifies or deletes to find how recently they were added.
+type Churn struct {
+	Enabled bool `yaml:"enabled" json:"enabled" mapstructure:"enabled" description:"blame modified lines to measure churn and rework, slows the analysis down" default:"false"`
+	Days    int  `yaml:"days" json:"days" mapstructure:"days" description:"lines rewritten within this many days of being added are churn" default:"21"`
+}
+
+// Window is the time within which rewritten lines are churn, zero when the
+// analysis is disabled.
+func (churn *Churn) Window() time.Duration {
+	if !churn.Enabled {
+		return 0
+	}
+	days := churn.Days
+	if days <= 0 {
+		days = 21
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// CommitChurn is the number of lines of an earlier commit that a commit
+// modified or deleted within the churn window.
+type CommitChurn struct {
+	OriginHash string
+	Lines      int
+}
+
+// GetCommitChurn blames the lines the commit modifies or deletes in the
+// counted files and returns, per commit that added them, the lines added no
+// longer than window before the commit. Merges rewrite nothing themselves.
+func GetCommitChurn(c *object.Commit, window time.Duration, opts *DiffOptions) ([]CommitChurn, error) {
+	if window <= 0 || c.NumParents() != 1 {
+		return nil, nil
+	}
+	parent, err := c.Parent(0)
+	if err != nil {
+		return nil, err
+	}
+	parentTree, err := parent.Tree()
+	if err != nil {
+		return nil, err
+	}
+	commitTree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	changes, err := opts.diffTree(parentTree, commitTree)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make(map[string]int)
+	origins := make([]string, 0)
+	for _, change := range changes {
+		action, err := change.Action()
+		if err != nil {
+			return nil, err
+		}
+		if action == merkletrie.Insert || !opts.match(change.From.Name) {
+			continue
+		}
+		rewritten, err := rewrittenLines(change)
+		if err != nil {
+			return nil, err
+		}
+		if len(rewritten) == 0 {
+			continue
+		}
+		blame, err := git.Blame(parent, change.From.Name)
+		if err != nil {
+			log.Printf("  ⚠️ Error blaming %s at %s: %v\n", change.From.Name, parent.Hash.String(), err)
+			continue
+		}
+		for _, i := range rewritten {
+			if i >= len(blame.Lines) {
+				break
+			}
+			line := blame.Lines[i]
+			age := c.Author.When.Sub(line.Date)
+			if age < 0 || age > window {
+				continue
+			}
+			origin := line.Hash.String()
+			if _, ok := lines[origin]; !ok {
+				origins = append(origins, origin)
+			}
+			lines[origin]++
+		}
+	}
+
+	churn := make([]CommitChurn, len(origins))
+	for i, origin := range origins {
+		churn[i] = CommitChurn{OriginHash: origin, Lines: lines[origin]}
+	}
+	return churn, nil
+}
+
+// rewrittenLines returns the zero based numbers of the lines of the old file
+// that the change modifies or deletes.
+func rewrittenLines(change *object.Change) ([]int, error) {
+	patch, err := change.Patch()
+	if err != nil {
+		return nil, err
+	}
+	rewritten := make([]int, 0)
+	for _, filePatch := range patch.FilePatches() {
+		if filePatch.IsBinary() {
+			continue
+		}
+		line := 0
+		for _, chunk := range filePatch.Chunks() {
+			n := countLines(chunk.Content())
+			switch chunk.Type() {
+			case fdiff.Equal:
+				line += n
+			case fdiff.Delete:
+				for i := 0; i < n; i++ {
+					rewritten = append(rewritten, line+i)
+				}
+				line += n
+			}
+		}
+	}
+	return rewritten, nil
+}
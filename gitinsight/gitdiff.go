@@ -0,0 +1,498 @@
+package gitinsight
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	fdiff "github.com/go-git/go-git/v6/plumbing/format/diff"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/utils/merkletrie"
+)
+
+const (
+	ChangeTypeAdd    = "add"
+	ChangeTypeDelete = "delete"
+	ChangeTypeModify = "modify"
+	ChangeTypeRename = "rename"
+	ChangeTypeCopy   = "copy"
+)
+
+// How merge commits count their lines.
+const (
+	// MergeDiffFirstParent diffs a merge against its first parent, counting
+	// the changes it brings into the mainline once
+	MergeDiffFirstParent = "first-parent"
+	// MergeDiffCombined counts only the lines of a merge that match none of
+	// its parents, the conflict resolutions
+	MergeDiffCombined = "combined"
+	// MergeDiffSkip counts no lines for merges
+	MergeDiffSkip = "skip"
+)
+
+// Renames configures how moved and copied files are detected, so that they
+// count only the lines their content changed.
+type Renames struct {
+	Disabled   bool `yaml:"disabled" json:"disabled" mapstructure:"disabled" description:"count renamed files as deleted and added" default:"false"`
+	Similarity uint `yaml:"similarity" json:"similarity" mapstructure:"similarity" description:"percentage of similar content for a deleted and an added file to be a rename, 100 for exact renames only" default:"60"`
+	Copies     bool `yaml:"copies" json:"copies" mapstructure:"copies" description:"count added files identical or similar to a file of the parent as copies" default:"false"`
+}
+
+// DiffTreeOptions are the go-git rename detection options of the config.
+func (renames *Renames) DiffTreeOptions() *object.DiffTreeOptions {
+	if renames.Disabled {
+		return nil
+	}
+	similarity := renames.Similarity
+	if similarity == 0 || similarity > 100 {
+		similarity = object.DefaultDiffTreeOptions.RenameScore
+	}
+	return &object.DiffTreeOptions{
+		DetectRenames:    true,
+		RenameScore:      similarity,
+		OnlyExactRenames: similarity == 100,
+	}
+}
+
+// CommitFile is the line change of one file in a commit.
+type CommitFile struct {
+	Path       string
+	OldPath    string
+	Language   string
+	Additions  int
+	Deletions  int
+	ChangeType string
+	IsBinary   bool
+}
+
+// DiffOptions controls which files of a commit are counted, how they are
+// classified, how renames are detected and how merges are diffed. A nil
+// DiffOptions counts every file with the built-in language tables, the
+// default rename detection and merges against their first parent.
+type DiffOptions struct {
+	Paths     *PathMatcher
+	Languages *LanguageClassifier
+	Renames   Renames
+	MergeDiff string
+}
+
+// NewDiffOptions builds the diff options of a configured repository.
+func NewDiffOptions(config *Config, repoUrl string) *DiffOptions {
+	return &DiffOptions{
+		Paths:     NewPathMatcher(config, repoUrl),
+		Languages: NewLanguageClassifier(config),
+		Renames:   config.Renames,
+		MergeDiff: config.MergeDiff,
+	}
+}
+
+// diffTree diffs two trees with the rename detection of the options.
+func (opts *DiffOptions) diffTree(from *object.Tree, to *object.Tree) (object.Changes, error) {
+	var renames Renames
+	if opts != nil {
+		renames = opts.Renames
+	}
+	return object.DiffTreeWithOptions(context.Background(), from, to, renames.DiffTreeOptions())
+}
+
+func (opts *DiffOptions) detectCopies() bool {
+	return opts != nil && opts.Renames.Copies && !opts.Renames.Disabled
+}
+
+func (opts *DiffOptions) mergeDiff() string {
+	if opts == nil || opts.MergeDiff == "" {
+		return MergeDiffFirstParent
+	}
+	return opts.MergeDiff
+}
+
+func (opts *DiffOptions) match(filePath string) bool {
+	if opts == nil {
+		return true
+	}
+	return opts.Paths.Match(filePath)
+}
+
+func (opts *DiffOptions) language(f *object.File) string {
+	var languages *LanguageClassifier
+	if opts != nil {
+		languages = opts.Languages
+	}
+	return languages.Classify(f.Name, func() string {
+		return readFileHead(f)
+	})
+}
+
+// readFileHead reads the start of a file, enough for a shebang or a header
+// sniff without loading large blobs.
+func readFileHead(f *object.File) string {
+	reader, err := f.Reader()
+	if err != nil {
+		return ""
+	}
+	defer reader.Close()
+	buf := make([]byte, 4096)
+	n, _ := io.ReadFull(reader, buf)
+	return string(buf[:n])
+}
+
+// GetCommitFiles returns the per-file changes of a commit, skipping the paths
+// the options do not count. The initial commit counts every line of every
+// file as added.
+func GetCommitFiles(c *object.Commit, opts *DiffOptions) ([]CommitFile, error) {
+	if c.NumParents() == 0 {
+		return CountFilesInCommit(c, opts)
+	}
+	return GetCommitDiffFiles(c, opts)
+}
+
+// CountFilesInCommit lists every counted file of the commit tree as added.
+func CountFilesInCommit(commit *object.Commit, opts *DiffOptions) ([]CommitFile, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]CommitFile, 0)
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if !opts.match(f.Name) {
+			return nil
+		}
+		file := CommitFile{
+			Path:       f.Name,
+			Language:   opts.language(f),
+			ChangeType: ChangeTypeAdd,
+		}
+		isBinary, err := f.IsBinary()
+		if err != nil {
+			return err
+		}
+		file.IsBinary = isBinary
+		if !isBinary {
+			content, err := f.Contents()
+			if err != nil {
+				return err
+			}
+			file.Additions = countLines(content)
+		}
+		files = append(files, file)
+		return nil
+	})
+	return files, err
+}
+
+// GetCommitDiffFiles diffs the commit against its first parent. Merges are
+// diffed as the merge diff option says.
+func GetCommitDiffFiles(c *object.Commit, opts *DiffOptions) ([]CommitFile, error) {
+	if c.NumParents() > 1 {
+		switch opts.mergeDiff() {
+		case MergeDiffSkip:
+			return []CommitFile{}, nil
+		case MergeDiffCombined:
+			return GetCombinedDiffFiles(c, opts)
+		}
+	}
+	parent, err := c.Parent(0)
+	if err != nil {
+		return nil, err
+	}
+	files, _, err := diffParent(c, parent, opts, false)
+	return files, err
+}
+
+// GetCombinedDiffFiles returns the files of a merge that differ from every
+// parent, counting as added the lines that are in none of the parents and
+// as deleted the fewest lines deleted from a parent. These are the conflict
+// resolutions and other edits made in the merge itself.
+func GetCombinedDiffFiles(c *object.Commit, opts *DiffOptions) ([]CommitFile, error) {
+	var files []CommitFile
+	var added []map[int]bool
+	parentIter := c.Parents()
+	defer parentIter.Close()
+	for first := true; ; first = false {
+		parent, err := parentIter.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		parentFiles, parentAdded, err := diffParent(c, parent, opts, true)
+		if err != nil {
+			return nil, err
+		}
+		if first {
+			files, added = parentFiles, parentAdded
+			continue
+		}
+		index := make(map[string]int, len(parentFiles))
+		for i, file := range parentFiles {
+			index[file.Path] = i
+		}
+		kept := 0
+		for i, file := range files {
+			j, ok := index[file.Path]
+			if !ok {
+				// the merge took the file of this parent as it is
+				continue
+			}
+			for line := range added[i] {
+				if !parentAdded[j][line] {
+					delete(added[i], line)
+				}
+			}
+			file.Deletions = min(file.Deletions, parentFiles[j].Deletions)
+			files[kept], added[kept] = file, added[i]
+			kept++
+		}
+		files, added = files[:kept], added[:kept]
+	}
+	for i := range files {
+		files[i].Additions = len(added[i])
+	}
+	return files, nil
+}
+
+// diffParent diffs the commit against one of its parents, with the numbers
+// of the lines of each file that the parent does not have when lines is set.
+func diffParent(c *object.Commit, parent *object.Commit, opts *DiffOptions, lines bool) ([]CommitFile, []map[int]bool, error) {
+	commitTree, err := c.Tree()
+	if err != nil {
+		return nil, nil, err
+	}
+	parentTree, err := parent.Tree()
+	if err != nil {
+		return nil, nil, err
+	}
+	changes, err := opts.diffTree(parentTree, commitTree)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var copies map[string]*object.Change
+	if opts.detectCopies() {
+		copies, err = copySources(parentTree, changes, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	files := make([]CommitFile, 0)
+	var added []map[int]bool
+	for _, change := range changes {
+		if !opts.match(change.To.Name) && !opts.match(change.From.Name) {
+			continue
+		}
+		copied, isCopy := copies[change.To.Name]
+		if isCopy && change.From.Name == "" {
+			// a copy counts the lines it changed from its source
+			change = copied
+		}
+		var addedLines map[int]bool
+		if lines {
+			addedLines = make(map[int]bool)
+		}
+		file, err := getChangeFile(change, opts, addedLines)
+		if err != nil {
+			return nil, nil, err
+		}
+		if file == nil {
+			continue
+		}
+		if change == copied {
+			file.ChangeType = ChangeTypeCopy
+			file.Deletions = 0
+		}
+		files = append(files, *file)
+		if lines {
+			added = append(added, addedLines)
+		}
+	}
+	return files, added, nil
+}
+
+// copySources pairs the files the changes add with the file of the parent
+// they were copied from. Any file of the parent with the same content is a
+// source, similar ones are looked for among the files the commit modified,
+// renamed or deleted, with the similarity of the rename detection, as git -C
+// does.
+func copySources(parentTree *object.Tree, changes object.Changes, opts *DiffOptions) (map[string]*object.Change, error) {
+	added := make([]*object.Change, 0)
+	candidates := make([]*object.Change, 0)
+	for _, change := range changes {
+		if change.From.Name == "" {
+			if opts.match(change.To.Name) {
+				added = append(added, change)
+			}
+			continue
+		}
+		candidates = append(candidates, &object.Change{From: change.From})
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	sources, err := blobEntries(parentTree)
+	if err != nil {
+		return nil, err
+	}
+	copies := make(map[string]*object.Change)
+	remaining := make(object.Changes, 0, len(added))
+	for _, change := range added {
+		if source, ok := sources[change.To.TreeEntry.Hash]; ok {
+			copies[change.To.Name] = &object.Change{From: source, To: change.To}
+			continue
+		}
+		remaining = append(remaining, change)
+	}
+
+	diffOptions := opts.Renames.DiffTreeOptions()
+	if diffOptions.OnlyExactRenames {
+		return copies, nil
+	}
+	// each round pairs a source with one copy at most, the next round looks
+	// for further copies of it
+	for len(remaining) > 0 && len(candidates) > 0 {
+		paired, err := object.DetectRenames(append(remaining, candidates...), diffOptions)
+		if err != nil {
+			return nil, err
+		}
+		matched := false
+		for _, change := range paired {
+			if change.From.Name != "" && change.To.Name != "" {
+				copies[change.To.Name] = change
+				matched = true
+			}
+		}
+		if !matched {
+			break
+		}
+		unpaired := make(object.Changes, 0, len(remaining))
+		for _, change := range remaining {
+			if _, ok := copies[change.To.Name]; !ok {
+				unpaired = append(unpaired, change)
+			}
+		}
+		remaining = unpaired
+	}
+	return copies, nil
+}
+
+// getChangeFile counts the lines of a change, adding the numbers of the lines
+// of the new file that the change added to added when it is not nil.
+func getChangeFile(change *object.Change, opts *DiffOptions, added map[int]bool) (*CommitFile, error) {
+	action, err := change.Action()
+	if err != nil {
+		return nil, err
+	}
+	from, to, err := change.Files()
+	if err != nil {
+		return nil, err
+	}
+	patch, err := change.Patch()
+	if err != nil {
+		return nil, err
+	}
+	filePatches := patch.FilePatches()
+	if len(filePatches) == 0 {
+		// Submodule or other non file entries
+		return nil, nil
+	}
+
+	file := &CommitFile{}
+	switch action {
+	case merkletrie.Insert:
+		file.ChangeType = ChangeTypeAdd
+		file.Path = change.To.Name
+	case merkletrie.Delete:
+		file.ChangeType = ChangeTypeDelete
+		file.Path = change.From.Name
+	default:
+		file.ChangeType = ChangeTypeModify
+		file.Path = change.To.Name
+		if change.From.Name != change.To.Name {
+			// the patch of a rename is its content delta only
+			file.ChangeType = ChangeTypeRename
+			file.OldPath = change.From.Name
+		}
+	}
+	if to != nil {
+		file.Language = opts.language(to)
+	} else if from != nil {
+		file.Language = opts.language(from)
+	}
+
+	for _, filePatch := range filePatches {
+		if filePatch.IsBinary() {
+			file.IsBinary = true
+			continue
+		}
+		additions, deletions := countChunkLines(filePatch, added)
+		file.Additions += additions
+		file.Deletions += deletions
+	}
+	return file, nil
+}
+
+// blobEntries maps the blobs of a tree to the first file holding them.
+func blobEntries(tree *object.Tree) (map[plumbing.Hash]object.ChangeEntry, error) {
+	entries := make(map[plumbing.Hash]object.ChangeEntry)
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !entry.Mode.IsFile() {
+			continue
+		}
+		if _, ok := entries[entry.Hash]; !ok {
+			entries[entry.Hash] = object.ChangeEntry{Name: name, Tree: tree, TreeEntry: entry}
+		}
+	}
+}
+
+func countChunkLines(filePatch fdiff.FilePatch, added map[int]bool) (additions int, deletions int) {
+	line := 0
+	for _, chunk := range filePatch.Chunks() {
+		n := countLines(chunk.Content())
+		switch chunk.Type() {
+		case fdiff.Equal:
+			line += n
+		case fdiff.Add:
+			for i := 0; added != nil && i < n; i++ {
+				added[line+i] = true
+			}
+			additions += n
+			line += n
+		case fdiff.Delete:
+			deletions += n
+		}
+	}
+	return additions, deletions
+}
+
+func countLines(content string) int {
+	if content == "" {
+		return 0
+	}
+	count := strings.Count(content, "\n")
+	if !strings.HasSuffix(content, "\n") {
+		count++
+	}
+	return count
+}
+
+// SumCommitFiles totals the line changes of the files.
+func SumCommitFiles(files []CommitFile) (additions int, deletions int) {
+	for _, file := range files {
+		additions += file.Additions
+		deletions += file.Deletions
+	}
+	return additions, deletions
+}
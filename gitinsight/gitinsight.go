@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -14,19 +16,33 @@ import (
 	"github.com/go-git/go-git/v6"
 	"github.com/go-git/go-git/v6/config"
 	"github.com/go-git/go-git/v6/plumbing"
-	"github.com/go-git/go-git/v6/plumbing/transport/http"
+	"github.com/go-git/go-git/v6/plumbing/transport"
 )
 
 type Config struct {
-	Reset    bool     `yaml:"reset" json:"reset" mapstructure:"reset" description:"clear cache and database" default:"false"`
-	Parallel bool     `yaml:"parallel" json:"parallel" mapstructure:"parallel" description:"parallel analysis" default:"true"`
-	Readonly bool     `yaml:"readonly" json:"readonly" mapstructure:"readonly" description:"readonly" default:"false"`
-	Interval string   `yaml:"interval" json:"interval" mapstructure:"interval" description:"cron interval" default:"60m"`
-	Since    string   `yaml:"since" json:"since" mapstructure:"since" description:"since time of analysis" default:""`
-	Auths    []Auth   `yaml:"auths" json:"auths" mapstructure:"auths" description:"auths"`
-	Authors  []Author `yaml:"authors" json:"authors" mapstructure:"authors" description:"authors"`
-	Repos    []Repo   `yaml:"repos" json:"repos" mapstructure:"repos" description:"repos"`
-	Cache    Cache    `yaml:"cache" json:"cache" mapstructure:"cache" description:"cache"`
+	Reset          bool              `yaml:"reset" json:"reset" mapstructure:"reset" description:"clear cache and database" default:"false"`
+	Parallel       bool              `yaml:"parallel" json:"parallel" mapstructure:"parallel" description:"parallel analysis" default:"true"`
+	Readonly       bool              `yaml:"readonly" json:"readonly" mapstructure:"readonly" description:"readonly" default:"false"`
+	Interval       string            `yaml:"interval" json:"interval" mapstructure:"interval" description:"cron interval" default:"60m"`
+	Since          string            `yaml:"since" json:"since" mapstructure:"since" description:"since time of analysis" default:""`
+	Auths          []Auth            `yaml:"auths" json:"auths" mapstructure:"auths" description:"auths"`
+	Authors        []Author          `yaml:"authors" json:"authors" mapstructure:"authors" description:"authors"`
+	Repos          []Repo            `yaml:"repos" json:"repos" mapstructure:"repos" description:"repos"`
+	Sources        []Source          `yaml:"sources,omitempty" json:"sources,omitempty" mapstructure:"sources" description:"organizations whose repos are discovered on each sync"`
+	Cache          Cache             `yaml:"cache" json:"cache" mapstructure:"cache" description:"cache"`
+	Paths          PathRules         `yaml:"paths" json:"paths" mapstructure:"paths" description:"path include/exclude rules"`
+	Languages      []LanguageMapping `yaml:"languages,omitempty" json:"languages,omitempty" mapstructure:"languages" description:"language detection overrides"`
+	Attribution    string            `yaml:"attribution" json:"attribution" mapstructure:"attribution" description:"co-author credit in ranking and contributors: primary, full or split" default:"primary"`
+	ExcludeReverts bool              `yaml:"exclude_reverts" json:"exclude_reverts" mapstructure:"exclude_reverts" description:"leave reverts and the commits they revert out of ranking and contributors" default:"false"`
+	CommitTypes    []string          `yaml:"commit_types,omitempty" json:"commit_types,omitempty" mapstructure:"commit_types" description:"valid conventional commit types, defaults to feat, fix, docs, style, refactor, perf, test, build, ci, chore and revert"`
+	Mailmap        string            `yaml:"mailmap,omitempty" json:"mailmap,omitempty" mapstructure:"mailmap" description:"global mailmap file, applied over each repository's .mailmap"`
+	MergeDiff      string            `yaml:"merge_diff" json:"merge_diff" mapstructure:"merge_diff" description:"how merges count lines: first-parent, combined for conflict resolutions only, or skip" default:"first-parent"`
+	Renames        Renames           `yaml:"renames" json:"renames" mapstructure:"renames" description:"rename and copy detection"`
+	Churn          Churn             `yaml:"churn" json:"churn" mapstructure:"churn" description:"churn and rework analysis"`
+	Ownership      Ownership         `yaml:"ownership" json:"ownership" mapstructure:"ownership" description:"ownership snapshots"`
+	BusFactor      BusFactor         `yaml:"bus_factor" json:"bus_factor" mapstructure:"bus_factor" description:"knowledge concentration report"`
+	Coupling       Coupling          `yaml:"coupling" json:"coupling" mapstructure:"coupling" description:"temporal coupling analysis"`
+	Dora           Dora              `yaml:"dora" json:"dora" mapstructure:"dora" description:"delivery metrics"`
 }
 
 func (config *Config) SinceTime() time.Time {
@@ -41,6 +57,10 @@ type Auth struct {
 	Domain        string `yaml:"domain" json:"domain" mapstructure:"domain" description:"domain"`
 	Username      string `yaml:"username,omitempty" json:"username,omitempty" mapstructure:"username" description:"username"`
 	Password      string `yaml:"password,omitempty" json:"password,omitempty" mapstructure:"password" description:"password"`
+	PrivateKey    string `yaml:"private_key,omitempty" json:"private_key,omitempty" mapstructure:"private_key" description:"ssh private key file"`
+	Passphrase    string `yaml:"passphrase,omitempty" json:"passphrase,omitempty" mapstructure:"passphrase" description:"ssh private key passphrase"`
+	SSHAgent      string `yaml:"ssh_agent,omitempty" json:"ssh_agent,omitempty" mapstructure:"ssh_agent" description:"ssh agent socket, defaults to SSH_AUTH_SOCK"`
+	KnownHosts    string `yaml:"known_hosts,omitempty" json:"known_hosts,omitempty" mapstructure:"known_hosts" description:"ssh known_hosts file"`
 	CommitUrlTmpl string `yaml:"commit_url_tmpl,omitempty" json:"commit_url_tmpl,omitempty" mapstructure:"commit_url_tmpl" description:"commit_url_tmpl"`
 }
 
@@ -48,16 +68,63 @@ type Repo struct {
 	Url      string `yaml:"url" json:"url" mapstructure:"url" description:"url"`
 	User     string `yaml:"user" json:"user" mapstructure:"user" description:"user"`
 	Password string `yaml:"password" json:"password" mapstructure:"password" description:"password"`
+
+	PrivateKey string `yaml:"private_key,omitempty" json:"private_key,omitempty" mapstructure:"private_key" description:"ssh private key file"`
+	Passphrase string `yaml:"passphrase,omitempty" json:"passphrase,omitempty" mapstructure:"passphrase" description:"ssh private key passphrase"`
+	SSHAgent   string `yaml:"ssh_agent,omitempty" json:"ssh_agent,omitempty" mapstructure:"ssh_agent" description:"ssh agent socket, defaults to SSH_AUTH_SOCK"`
+	KnownHosts string `yaml:"known_hosts,omitempty" json:"known_hosts,omitempty" mapstructure:"known_hosts" description:"ssh known_hosts file"`
+
+	Include []string `yaml:"include,omitempty" json:"include,omitempty" mapstructure:"include" description:"only count paths matching these globs"`
+	Exclude []string `yaml:"exclude,omitempty" json:"exclude,omitempty" mapstructure:"exclude" description:"do not count paths matching these globs"`
+
+	Mirror bool `yaml:"mirror,omitempty" json:"mirror,omitempty" mapstructure:"mirror" description:"clone a local repository into the cache instead of analyzing it in place"`
 }
 
 type Cache struct {
-	Path string `yaml:"path" json:"path" mapstructure:"path" description:"path" default:"./.repos"`
+	Path    string `yaml:"path" json:"path" mapstructure:"path" description:"path" default:"./.repos"`
+	Shallow bool   `yaml:"shallow" json:"shallow" mapstructure:"shallow" description:"fetch only the history after since" default:"false"`
+	Depth   int    `yaml:"depth" json:"depth" mapstructure:"depth" description:"commits of the first shallow fetch, doubled until the history reaches since" default:"200"`
+}
+
+// ShallowClone bounds the history fetched into a mirror.
+type ShallowClone struct {
+	Since time.Time
+	Depth int
+}
+
+// GetShallowClone returns the history bound of the configured shallow
+// mirrors, nil when the full history is fetched.
+func GetShallowClone(config *Config) *ShallowClone {
+	since := ParseTime(config.Since)
+	if !config.Cache.Shallow || since.IsZero() {
+		return nil
+	}
+	depth := config.Cache.Depth
+	if depth <= 0 {
+		depth = 200
+	}
+	return &ShallowClone{Since: since, Depth: depth}
 }
 
 type Author struct {
-	Name     string `yaml:"name" json:"name" mapstructure:"name" description:"name"`
-	Email    string `yaml:"email" json:"email" mapstructure:"email" description:"email"`
-	Nickname string `yaml:"nickname" json:"nickname" mapstructure:"nickname" description:"nickname"`
+	Name     string   `yaml:"name" json:"name" mapstructure:"name" description:"name"`
+	Email    string   `yaml:"email" json:"email" mapstructure:"email" description:"email"`
+	Nickname string   `yaml:"nickname" json:"nickname" mapstructure:"nickname" description:"nickname"`
+	Team     string   `yaml:"team,omitempty" json:"team,omitempty" mapstructure:"team" description:"team of the author"`
+	Names    []string `yaml:"names,omitempty" json:"names,omitempty" mapstructure:"names" description:"more names of the author"`
+	Emails   []string `yaml:"emails,omitempty" json:"emails,omitempty" mapstructure:"emails" description:"more emails of the author"`
+	Patterns []string `yaml:"patterns,omitempty" json:"patterns,omitempty" mapstructure:"patterns" description:"regexps matched against \"Name <email>\""`
+}
+
+// GetTeams maps the nicknames of the configured authors to their team.
+func GetTeams(config *Config) map[string]string {
+	teams := make(map[string]string)
+	for _, author := range config.Authors {
+		if author.Team != "" {
+			teams[author.Nickname] = author.Team
+		}
+	}
+	return teams
 }
 
 func ResetRepo(config *Config) error {
@@ -72,23 +139,179 @@ func ResetRepo(config *Config) error {
 	return nil
 }
 
+// IsConfiguredRepo reports whether the url is one of the configured or
+// discovered repos.
+func IsConfiguredRepo(config *Config, repoUrl string) bool {
+	for _, repo := range GetRepos(config) {
+		if repoUrl != "" && repo.Url == repoUrl {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRepoPath returns where the repository of a url is cached, in the
+// directories of its host and path so that repositories of the same name in
+// different hosts or groups do not collide. Local repositories are analyzed
+// in place, their path is the one of the url.
+func GetRepoPath(config *Config, repoUrl string) string {
+	if path, ok := getLocalRepoPath(config, repoUrl); ok {
+		return path
+	}
+	return filepath.Join(getCachePath(config), RepoCacheName(repoUrl))
+}
+
+// LocalRepoPath returns the absolute path of the repository of a file:// url
+// or a local path, which is absolute, relative to the working directory with
+// a leading ./ or ../, or relative to the home directory with a leading ~/.
+func LocalRepoPath(repoUrl string) (string, bool) {
+	path := strings.TrimSpace(repoUrl)
+	if strings.HasPrefix(path, "file://") {
+		u, err := url.Parse(path)
+		if err != nil || u.Path == "" {
+			return "", false
+		}
+		path = filepath.FromSlash(u.Path)
+	} else if !filepath.IsAbs(path) && path != "." && path != ".." && path != "~" &&
+		!strings.HasPrefix(path, "./") && !strings.HasPrefix(path, "../") && !strings.HasPrefix(path, "~/") {
+		return "", false
+	}
+	path, err := filepath.Abs(ExpandHome(path))
+	if err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// getLocalRepoPath returns the path of a local repository analyzed in place,
+// that is not configured to be mirrored into the cache.
+func getLocalRepoPath(config *Config, repoUrl string) (string, bool) {
+	path, ok := LocalRepoPath(repoUrl)
+	if !ok {
+		return "", false
+	}
+	for _, repo := range GetRepos(config) {
+		if repo.Url == repoUrl && repo.Mirror {
+			return "", false
+		}
+	}
+	return path, true
+}
+
+// GetRepoUrl returns the configured url of the repository cached at repoPath,
+// or the url of the origin remote of other checkouts.
+func GetRepoUrl(config *Config, repoPath string) string {
+	for _, repo := range GetRepos(config) {
+		if filepath.Clean(GetRepoPath(config, repo.Url)) == filepath.Clean(repoPath) {
+			return repo.Url
+		}
+	}
+	return GetRepoRemoteUrl(repoPath)
+}
+
+// RepoCacheName is the cache directory of a repository url relative to the
+// cache path: the host, without credentials and with the port after an
+// underscore, followed by the path without the .git suffix.
+func RepoCacheName(repoUrl string) string {
+	name := strings.TrimSpace(repoUrl)
+	if i := strings.Index(name, "://"); i >= 0 {
+		name = name[i+3:]
+	} else if i := strings.Index(name, ":"); i > 0 && !strings.Contains(name[:i], "/") {
+		// scp-like user@host:path
+		name = name[:i] + "/" + name[i+1:]
+	}
+	host, path, _ := strings.Cut(name, "/")
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	host = strings.ReplaceAll(host, ":", "_")
+
+	segments := make([]string, 0)
+	for _, segment := range strings.Split(host+"/"+path, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			continue
+		}
+		segments = append(segments, segment)
+	}
+	if len(segments) == 0 {
+		return "_"
+	}
+	last := len(segments) - 1
+	segments[last] = strings.TrimSuffix(segments[last], ".git")
+	return filepath.Join(segments...)
+}
+
+func getCachePath(config *Config) string {
+	if config.Cache.Path == "" {
+		return ".repos"
+	}
+	return config.Cache.Path
+}
+
+// migrateRepoCache moves the clone of a repository from where it was cached
+// before the host and path layout, the cache directory named after the last
+// segment of its url, unless that clone is of another repository.
+func migrateRepoCache(config *Config, repoUrl string) error {
+	repoPath := GetRepoPath(config, repoUrl)
+	legacyPath := filepath.Join(getCachePath(config), strings.TrimSuffix(filepath.Base(repoUrl), ".git"))
+	if legacyPath == repoPath || strings.HasPrefix(repoPath, legacyPath+string(filepath.Separator)) {
+		return nil
+	}
+	if _, err := os.Stat(repoPath); err == nil {
+		return nil
+	}
+	if _, err := os.Stat(legacyPath); err != nil {
+		return nil
+	}
+	if GetRepoRemoteUrl(legacyPath) != repoUrl {
+		return nil
+	}
+	log.Printf("Moving cached %s from %s to %s\n", repoUrl, legacyPath, repoPath)
+	err := os.MkdirAll(filepath.Dir(repoPath), 0755)
+	if err != nil {
+		return err
+	}
+	return os.Rename(legacyPath, repoPath)
+}
+
 func SyncRepo(config *Config) (map[string][]string, error) {
 
 	if config.Cache.Path == "" {
 		config.Cache.Path = ".repos"
 	}
 
+	RefreshSourceRepos(config)
+	repos := GetRepos(config)
+
 	repoStats := make(map[string][]string)
 
-	for i, repoInfo := range config.Repos {
-		log.Printf("\n[%d/%d] Processing repository: %s\n", i+1, len(config.Repos), repoInfo.Url)
+	for i, repoInfo := range repos {
+		log.Printf("\n[%d/%d] Processing repository: %s\n", i+1, len(repos), repoInfo.Url)
 
-		repoName := strings.TrimSuffix(filepath.Base(repoInfo.Url), ".git")
-		repoPath := filepath.Join(config.Cache.Path, repoName)
+		repoPath := GetRepoPath(config, repoInfo.Url)
+		if _, ok := getLocalRepoPath(config, repoInfo.Url); ok {
+			// Local repositories are analyzed in place, as they are
+			branches, err := getLocalBranches(repoPath)
+			if err != nil {
+				log.Printf("❌ Error opening %s: %v\n", repoInfo.Url, err)
+				continue
+			}
+			log.Printf("    Found %d branches\n", len(branches))
+			repoStats[repoPath] = branches
+			continue
+		}
+		err := migrateRepoCache(config, repoInfo.Url)
+		if err != nil {
+			log.Printf("❌ Error moving the cache of %s: %v\n", repoInfo.Url, err)
+			continue
+		}
 
+		// a repository that cannot be authenticated is skipped, the others
+		// are still synced
 		auth, err := FindAuth(config, &repoInfo)
 		if err != nil {
-			return nil, err
+			log.Printf("❌ Error finding the auth of %s: %v\n", repoInfo.Url, err)
+			continue
 		}
 		if auth != nil {
 			if repoInfo.User == "" {
@@ -97,14 +320,27 @@ func SyncRepo(config *Config) (map[string][]string, error) {
 			if repoInfo.Password == "" {
 				repoInfo.Password = auth.Password
 			}
+			if repoInfo.PrivateKey == "" {
+				repoInfo.PrivateKey = auth.PrivateKey
+				repoInfo.Passphrase = auth.Passphrase
+			}
+			if repoInfo.SSHAgent == "" {
+				repoInfo.SSHAgent = auth.SSHAgent
+			}
+			if repoInfo.KnownHosts == "" {
+				repoInfo.KnownHosts = auth.KnownHosts
+			}
 		}
 		// Determine which credentials to use
-		username := repoInfo.User
-		password := repoInfo.Password
+		gitAuth, err := GetAuthMethod(&repoInfo)
+		if err != nil {
+			log.Printf("❌ Error preparing auth for %s: %v\n", repoInfo.Url, err)
+			continue
+		}
 
 		h := func() error {
 			// Clone or update repository
-			repo, err := CloneOrUpdateRepo(repoInfo.Url, repoPath, username, password)
+			repo, err := CloneOrUpdateRepo(repoInfo.Url, repoPath, gitAuth, GetShallowClone(config))
 			if err != nil {
 				return fmt.Errorf("error processing %s: %v", repoInfo.Url, err)
 			}
@@ -136,79 +372,226 @@ func SyncRepo(config *Config) (map[string][]string, error) {
 	return repoStats, nil
 }
 
-func CloneOrUpdateRepo(url, path, username, password string) (*git.Repository, error) {
-	// Get authentication
-	var auth *http.BasicAuth
-
-	// Priority: 1. Function parameters, 2. Environment variables
-	if username != "" && password != "" {
-		auth = &http.BasicAuth{
-			Username: username,
-			Password: password,
-		}
+func getLocalBranches(path string) ([]string, error) {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return nil, err
 	}
+	return GetBranches(repo)
+}
 
-	// Check if repo exists
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		// Clone the repository with all branches
-		log.Printf("Cloning %s to %s...\n", url, path)
-		repo, err := git.PlainClone(path, &git.CloneOptions{
-			URL:      url,
-			Auth:     auth,
-			Progress: os.Stdout,
-		})
+// mirrorRefSpecs keep the branches and tags of a cached repository identical
+// to the remote ones.
+var mirrorRefSpecs = []config.RefSpec{"+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*"}
+
+// CloneOrUpdateRepo keeps a bare mirror of the branches and tags of the
+// remote repository at path. Updates force fetch every ref and prune the
+// deleted ones, so force pushes and deleted branches do not break them.
+// Clones with a worktree, as cached before, are replaced by a mirror.
+//
+// With shallow set, only the history after shallow.Since is fetched: the
+// first fetch is shallow.Depth commits deep and the mirror is deepened until
+// it reaches back to since, also when since is moved back later. Without it,
+// a shallow mirror is deepened to the full history.
+func CloneOrUpdateRepo(url, path string, auth transport.AuthMethod, shallow *ShallowClone) (*git.Repository, error) {
+	if _, err := os.Stat(path); err == nil {
+		repo, err := git.PlainOpen(path)
 		if err != nil {
 			return nil, err
 		}
-
-		// Fetch all remote branches
-		err = repo.Fetch(&git.FetchOptions{
-			RemoteName: "origin",
-			Auth:       auth,
-			RefSpecs:   []config.RefSpec{"refs/heads/*:refs/remotes/origin/*"},
-			Progress:   os.Stdout,
-		})
-		if err != nil && err != git.NoErrAlreadyUpToDate {
-			return nil, fmt.Errorf("could not fetch all branches: %v", err)
+		if _, err := repo.Worktree(); err != git.ErrIsBareRepository {
+			log.Printf("Replacing the worktree clone %s with a mirror...\n", path)
+			err = os.RemoveAll(path)
+			if err != nil {
+				return nil, err
+			}
+			return cloneMirror(url, path, auth, shallow)
 		}
 
+		log.Printf("Updating %s...\n", path)
+		err = fetchMirror(repo, auth, 0)
+		if err != nil {
+			return nil, fmt.Errorf("fetch error: %v", err)
+		}
+		err = deepenMirror(repo, auth, shallow)
+		if err != nil {
+			return nil, fmt.Errorf("deepen error: %v", err)
+		}
 		return repo, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
 	}
+	return cloneMirror(url, path, auth, shallow)
+}
 
-	// Open existing repository
-	log.Printf("Updating %s...\n", path)
-	repo, err := git.PlainOpen(path)
+func cloneMirror(url, path string, auth transport.AuthMethod, shallow *ShallowClone) (*git.Repository, error) {
+	log.Printf("Cloning %s to %s...\n", url, path)
+	repo, err := git.PlainInit(path, true)
 	if err != nil {
 		return nil, err
 	}
+	remote, err := repo.CreateRemote(&config.RemoteConfig{
+		Name:   "origin",
+		URLs:   []string{url},
+		Fetch:  mirrorRefSpecs,
+		Mirror: true,
+	})
+	if err != nil {
+		os.RemoveAll(path)
+		return nil, err
+	}
+	depth := 0
+	if shallow != nil {
+		depth = shallow.Depth
+	}
+	err = fetchMirror(repo, auth, depth)
+	if err == nil {
+		err = deepenMirror(repo, auth, shallow)
+	}
+	if err != nil {
+		os.RemoveAll(path)
+		return nil, fmt.Errorf("could not fetch all branches: %v", err)
+	}
 
-	// Fetch all branches from remote
-	log.Println("  Fetching all branches...")
-	err = repo.Fetch(&git.FetchOptions{
-		RemoteName: "origin",
-		Auth:       auth,
-		RefSpecs:   []config.RefSpec{"refs/heads/*:refs/remotes/origin/*"},
-		Progress:   os.Stdout,
-		Force:      true,
+	// HEAD follows the default branch of the remote
+	refs, err := remote.List(&git.ListOptions{Auth: auth})
+	if err != nil {
+		log.Printf("  ⚠️ Could not list the default branch of %s, HEAD follows a fetched branch: %v\n", url, err)
+		err = setFallbackHead(repo)
+	} else {
+		for _, ref := range refs {
+			if ref.Name() == plumbing.HEAD && ref.Type() == plumbing.SymbolicReference {
+				err = repo.Storer.SetReference(ref)
+				break
+			}
+		}
+	}
+	if err != nil {
+		os.RemoveAll(path)
+		return nil, err
+	}
+	return repo, nil
+}
+
+// setFallbackHead points HEAD at the main or master branch of a mirror, or
+// else at its first branch by name.
+func setFallbackHead(repo *git.Repository) error {
+	branches, err := repo.Branches()
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0)
+	err = branches.ForEach(func(ref *plumbing.Reference) error {
+		names = append(names, ref.Name().Short())
+		return nil
 	})
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no branch fetched")
+	}
+	sort.Strings(names)
+	head := names[0]
+	for _, name := range []string{"master", "main"} {
+		if slices.Contains(names, name) {
+			head = name
+		}
+	}
+	return repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName(head)))
+}
 
-	if err != nil && err != git.NoErrAlreadyUpToDate {
-		return nil, fmt.Errorf("fetch error: %v", err)
+// fullDepth is the depth git uses to unshallow a repository.
+const fullDepth = 1<<31 - 1
+
+// deepenMirror fetches more history into a shallow mirror until every
+// shallow boundary commit is older than shallow.Since, doubling the depth
+// each time, or the full history when shallow is nil. It stops early when
+// a fetch does not move the boundary, i.e. the history has no more commits.
+func deepenMirror(repo *git.Repository, auth transport.AuthMethod, shallow *ShallowClone) error {
+	boundary, err := repo.Storer.Shallow()
+	if err != nil || len(boundary) == 0 {
+		return err
+	}
+	if shallow == nil {
+		log.Printf("Fetching the full history of the shallow mirror...\n")
+		return fetchMirror(repo, auth, fullDepth)
 	}
 
-	// Try to pull current branch
-	w, err := repo.Worktree()
-	if err == nil {
-		err = w.Pull(&git.PullOptions{
-			RemoteName: "origin",
-			Auth:       auth,
-		})
-		if err != nil && err != git.NoErrAlreadyUpToDate {
-			return nil, fmt.Errorf("pull error: %v", err)
+	depth := shallow.Depth
+	for {
+		covered, err := IsHistorySince(repo, shallow.Since)
+		if err != nil || covered {
+			return err
+		}
+		depth *= 2
+		log.Printf("Deepening the shallow mirror to %d commits...\n", depth)
+		err = fetchMirror(repo, auth, depth)
+		if err != nil {
+			return err
 		}
+		deepened, err := repo.Storer.Shallow()
+		if err != nil {
+			return err
+		}
+		if equalHashes(boundary, deepened) {
+			return nil
+		}
+		boundary = deepened
 	}
+}
 
-	return repo, nil
+// IsHistorySince reports whether the history of a repository reaches back
+// to since, i.e. it is not shallow or each of its shallow boundary commits
+// was committed before since. Analysis walks stop at the first commit before
+// since, so they never need the missing parents of such a boundary.
+func IsHistorySince(repo *git.Repository, since time.Time) (bool, error) {
+	boundary, err := repo.Storer.Shallow()
+	if err != nil {
+		return false, err
+	}
+	for _, hash := range boundary {
+		c, err := repo.CommitObject(hash)
+		if err != nil {
+			return false, err
+		}
+		if !c.Committer.When.Before(since) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func equalHashes(a, b []plumbing.Hash) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[plumbing.Hash]bool, len(a))
+	for _, hash := range a {
+		seen[hash] = true
+	}
+	for _, hash := range b {
+		if !seen[hash] {
+			return false
+		}
+	}
+	return true
+}
+
+func fetchMirror(repo *git.Repository, auth transport.AuthMethod, depth int) error {
+	err := repo.Fetch(&git.FetchOptions{
+		RemoteName: "origin",
+		Auth:       auth,
+		RefSpecs:   mirrorRefSpecs,
+		Depth:      depth,
+		Progress:   os.Stdout,
+		Force:      true,
+		Prune:      true,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+	return nil
 }
 
 func GetBranches(repo *git.Repository) ([]string, error) {
@@ -224,7 +607,10 @@ func GetBranches(repo *git.Repository) ([]string, error) {
 	err = remoteRefs.ForEach(func(ref *plumbing.Reference) error {
 		refName := ref.Name().String()
 
-		// Check if it's a remote branch
+		// Mirrors keep the branches, other clones the remote branches
+		if strings.HasPrefix(refName, "refs/heads/") {
+			branchMap[strings.TrimPrefix(refName, "refs/heads/")] = true
+		}
 		if strings.HasPrefix(refName, "refs/remotes/origin/") {
 			branchName := strings.TrimPrefix(refName, "refs/remotes/origin/")
 			// Skip HEAD reference
@@ -0,0 +1,70 @@
+package gitinsight
+
+import (
+	"log"
+	"sort"
+
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing/object"
+)
+
+// Ownership configures the ownership snapshots, which blame every counted
+// file on the head of the snapshot branches.
+type Ownership struct {
+	Enabled  bool     `yaml:"enabled" json:"enabled" mapstructure:"enabled" description:"blame the head of the ownership branches after each analysis" default:"false"`
+	Branches []string `yaml:"branches,omitempty" json:"branches,omitempty" mapstructure:"branches" description:"branches to snapshot, defaults to the checked out branch"`
+}
+
+// OwnedLines is the number of lines of a file that an identity last changed.
+type OwnedLines struct {
+	Path        string
+	AuthorName  string
+	AuthorEmail string
+	Lines       int
+}
+
+// BlameOwnership blames the counted text files of the commit and returns the
+// surviving lines per file and author.
+func BlameOwnership(c *object.Commit, opts *DiffOptions) ([]OwnedLines, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	owned := make([]OwnedLines, 0)
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if !opts.match(f.Name) {
+			return nil
+		}
+		isBinary, err := f.IsBinary()
+		if err != nil {
+			log.Printf("  ⚠️ Error reading %s at %s: %v\n", f.Name, c.Hash.String(), err)
+			return nil
+		}
+		if isBinary {
+			return nil
+		}
+		blame, err := git.Blame(c, f.Name)
+		if err != nil {
+			log.Printf("  ⚠️ Error blaming %s at %s: %v\n", f.Name, c.Hash.String(), err)
+			return nil
+		}
+		index := make(map[[2]string]int)
+		first := len(owned)
+		for _, line := range blame.Lines {
+			key := [2]string{line.AuthorName, line.Author}
+			i, ok := index[key]
+			if !ok {
+				i = len(owned)
+				index[key] = i
+				owned = append(owned, OwnedLines{Path: f.Name, AuthorName: line.AuthorName, AuthorEmail: line.Author})
+			}
+			owned[i].Lines++
+		}
+		fileOwned := owned[first:]
+		sort.SliceStable(fileOwned, func(i, j int) bool {
+			return fileOwned[i].Lines > fileOwned[j].Lines
+		})
+		return nil
+	})
+	return owned, err
+}
@@ -0,0 +1,208 @@
+package gitinsight
+
+import (
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+)
+
+// Tag is a tag of a repository peeled to the commit it releases. Lightweight
+// tags have no tagger and are dated by their commit.
+type Tag struct {
+	Name        string
+	CommitHash  string
+	Annotated   bool
+	TaggerName  string
+	TaggerEmail string
+	Message     string
+	Date        time.Time
+}
+
+// GetTags returns the tags of the repository that point at commits, oldest
+// first. Tags of trees and blobs are skipped.
+func GetTags(repo *git.Repository) ([]Tag, error) {
+	refs, err := repo.Tags()
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]Tag, 0)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		name := strings.TrimPrefix(ref.Name().String(), "refs/tags/")
+		if tagObject, err := repo.TagObject(ref.Hash()); err == nil {
+			commit, err := tagObject.Commit()
+			if err != nil {
+				log.Printf("  ⚠️ Skipping tag %s: %v\n", name, err)
+				return nil
+			}
+			tags = append(tags, Tag{
+				Name:        name,
+				CommitHash:  commit.Hash.String(),
+				Annotated:   true,
+				TaggerName:  tagObject.Tagger.Name,
+				TaggerEmail: tagObject.Tagger.Email,
+				Message:     strings.TrimSpace(tagObject.Message),
+				Date:        tagObject.Tagger.When.UTC(),
+			})
+			return nil
+		}
+		commit, err := repo.CommitObject(ref.Hash())
+		if err != nil {
+			log.Printf("  ⚠️ Skipping tag %s: %v\n", name, err)
+			return nil
+		}
+		tags = append(tags, Tag{
+			Name:       name,
+			CommitHash: commit.Hash.String(),
+			Date:       commit.Committer.When.UTC(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		if !tags[i].Date.Equal(tags[j].Date) {
+			return tags[i].Date.Before(tags[j].Date)
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	return tags, nil
+}
+
+// TagCommit is a commit released by a tag with the change it shipped in:
+// the first-parent commit of the tag that brought it in, a merge for the
+// commits of a merged branch and the commit itself otherwise.
+type TagCommit struct {
+	CommitHash string
+	ChangeHash string
+}
+
+// GetTagCommits returns the commits each tag releases: the commits reachable
+// from the tag but not from the tag before it. Only the tags in stale are
+// walked, all of them when stale is nil. The walk stops at the since boundary
+// of the filter.
+func GetTagCommits(repo *git.Repository, tags []Tag, stale map[string]bool, filter CheckUpTodateFilter) (map[string][]TagCommit, error) {
+	tagCommits := make(map[string][]TagCommit, len(tags))
+	for i, tag := range tags {
+		if stale != nil && !stale[tag.Name] {
+			continue
+		}
+		commit, err := repo.CommitObject(plumbing.NewHash(tag.CommitHash))
+		if err != nil {
+			return nil, err
+		}
+		var previous *object.Commit
+		if i > 0 {
+			previous, err = repo.CommitObject(plumbing.NewHash(tags[i-1].CommitHash))
+			if err != nil {
+				return nil, err
+			}
+		}
+		cIter, err := newCommitRangeIter(previous, commit, filter)
+		if err != nil {
+			return nil, err
+		}
+		commits := make([]*object.Commit, 0)
+		err = cIter.ForEach(func(c *object.Commit) error {
+			commits = append(commits, c)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		changes := groupChanges(commit, commits)
+		released := make([]TagCommit, len(commits))
+		for i, c := range commits {
+			released[i] = TagCommit{CommitHash: c.Hash.String(), ChangeHash: changes[c.Hash].String()}
+		}
+		tagCommits[tag.Name] = released
+	}
+	return tagCommits, nil
+}
+
+// groupChanges maps the commits of a range to the first-parent commit of
+// head that brought them in. Merges are visited oldest first, so a commit
+// belongs to the first merge that made it reachable.
+func groupChanges(head *object.Commit, commits []*object.Commit) map[plumbing.Hash]plumbing.Hash {
+	inRange := make(map[plumbing.Hash]*object.Commit, len(commits))
+	for _, c := range commits {
+		inRange[c.Hash] = c
+	}
+	mainline := make([]*object.Commit, 0)
+	for c := inRange[head.Hash]; c != nil; {
+		mainline = append(mainline, c)
+		if c.NumParents() == 0 {
+			break
+		}
+		c = inRange[c.ParentHashes[0]]
+	}
+
+	changes := make(map[plumbing.Hash]plumbing.Hash, len(commits))
+	for _, c := range mainline {
+		changes[c.Hash] = c.Hash
+	}
+	for i := len(mainline) - 1; i >= 0; i-- {
+		merge := mainline[i]
+		pending := append([]plumbing.Hash{}, merge.ParentHashes[min(1, len(merge.ParentHashes)):]...)
+		for len(pending) > 0 {
+			hash := pending[len(pending)-1]
+			pending = pending[:len(pending)-1]
+			c, ok := inRange[hash]
+			if !ok {
+				continue
+			}
+			if _, ok := changes[hash]; ok {
+				continue
+			}
+			changes[hash] = merge.Hash
+			pending = append(pending, c.ParentHashes...)
+		}
+	}
+	for _, c := range commits {
+		if _, ok := changes[c.Hash]; !ok {
+			changes[c.Hash] = c.Hash
+		}
+	}
+	return changes
+}
+
+// newCommitRangeIter iterates the commits reachable from to but not from
+// from, all the history of to when from is nil. Neither walk goes past the
+// since boundary of the filter.
+func newCommitRangeIter(from *object.Commit, to *object.Commit, filter CheckUpTodateFilter) (object.CommitIter, error) {
+	excluded := make(map[plumbing.Hash]bool)
+	if from != nil {
+		isBefore := object.CommitFilter(func(c *object.Commit) bool {
+			return IsBeforeSince(c, filter)
+		})
+		isValid := object.CommitFilter(func(c *object.Commit) bool {
+			return !isBefore(c)
+		})
+		fromIter := object.NewFilterCommitIter(from, &isValid, &isBefore)
+		defer fromIter.Close()
+		for {
+			c, err := fromIter.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			excluded[c.Hash] = true
+		}
+	}
+
+	isLimit := object.CommitFilter(func(c *object.Commit) bool {
+		return excluded[c.Hash] || IsBeforeSince(c, filter)
+	})
+	isValid := object.CommitFilter(func(c *object.Commit) bool {
+		return !isLimit(c)
+	})
+	return object.NewFilterCommitIter(to, &isValid, &isLimit), nil
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/chaos-plus/chaos-plus-toolx/xgrpool"
+	"github.com/go-git/go-git/v6"
 )
 
 func HandleCommitLogs(insight *Config) {
@@ -17,6 +18,13 @@ func HandleCommitLogs(insight *Config) {
 		return
 	}
 
+	for repoPath, branchNames := range repos {
+		err := HandleDeletedBranchesToDb(insight, repoPath, branchNames)
+		if err != nil {
+			log.Printf("❌ Error removing deleted branches %s: %v\n", repoPath, err)
+		}
+	}
+
 	pool := xgrpool.New()
 
 	log.Printf("⏳⏳⏳⏳⏳⏳⏳⏳⏳⏳⏳⏳⏳⏳⏳⏳⏳⏳  Analyze by cron start ⏳⏳⏳⏳⏳⏳⏳⏳⏳⏳⏳⏳⏳⏳⏳⏳⏳⏳\n")
@@ -47,6 +55,31 @@ func HandleCommitLogs(insight *Config) {
 		}
 	}
 	pool.Wait()
+	for repoPath := range repos {
+		// the branches of the repository are no longer analyzed concurrently
+		err := HandleOrphanCommitsToDb(insight, repoPath)
+		if err != nil {
+			log.Printf("❌ Error removing orphan commits %s: %v\n", repoPath, err)
+		}
+		err = RefreshRepoNicknames(insight, repoPath)
+		if err != nil {
+			log.Printf("❌ Error refreshing nicknames %s: %v\n", repoPath, err)
+		}
+		err = RefreshRepoCommitMessages(insight, repoPath)
+		if err != nil {
+			log.Printf("❌ Error refreshing commit types %s: %v\n", repoPath, err)
+		}
+		err = HandleRepoTagsToDb(insight, repoPath)
+		if err != nil {
+			log.Printf("❌ Error handling tags %s: %v\n", repoPath, err)
+		}
+		if insight.Ownership.Enabled {
+			err = HandleRepoOwnershipToDb(insight, repoPath)
+			if err != nil {
+				log.Printf("❌ Error handling ownership %s: %v\n", repoPath, err)
+			}
+		}
+	}
 	timeStop := time.Now()
 	timeCost := timeStop.Sub(timeStart)
 	log.Printf("⏰⏰⏰⏰⏰⏰⏰⏰⏰⏰⏰⏰⏰⏰⏰⏰⏰⏰  Analyzed by cron cost %v ⏰⏰⏰⏰⏰⏰⏰⏰⏰⏰⏰⏰⏰⏰⏰⏰⏰⏰\n", timeCost)
@@ -54,62 +87,405 @@ func HandleCommitLogs(insight *Config) {
 }
 
 func HandleBranchCommitLogsToDb(insight *Config, repoPath string, branchName string) error {
-	repoUrl := GetRepoRemoteUrl(repoPath)
+	repoUrl := GetRepoUrl(insight, repoPath)
 
 	filter := CheckUpTodateFilter{
-		RepoUrl:    repoUrl,
-		BranchName: branchName,
-		SinceTime:  insight.SinceTime(),
-		SinceUTC:   insight.Since,
-		IsMerge:    "0",
+		RepoUrl:     repoUrl,
+		BranchName:  branchName,
+		SinceTime:   insight.SinceTime(),
+		SinceUTC:    insight.Since,
+		IsMerge:     "0",
+		Fingerprint: AnalysisFingerprint(insight, repoUrl),
 	}
-	isUpToDate, err := IsRepoUpToDate(repoPath, filter)
+
+	repo, err := git.PlainOpen(repoPath)
 	if err != nil {
-		log.Printf("❌ Error checking repo %s branch %s: %v\n", repoUrl, branchName, err)
 		return err
 	}
-	if isUpToDate {
-		log.Printf("✅   Repo %s branch %s is up to date 👍👍👍👍👍👍\n", repoUrl, branchName)
-		return nil
+	branchRef, err := GetBranchRef(repo, branchName)
+	if err != nil {
+		return err
+	}
+	state := &BranchStateModel{
+		RepoUrl:     repoUrl,
+		BranchName:  branchName,
+		CommitHash:  branchRef.Hash().String(),
+		Since:       insight.Since,
+		Fingerprint: filter.Fingerprint,
+	}
+
+	watermark, err := GetBranchState(repoUrl, branchName)
+	if err != nil {
+		log.Printf("❌ Error getting branch state %s %s: %v\n", repoUrl, branchName, err)
+		return err
+	}
+	if watermark == nil || watermark.Fingerprint == "" {
+		// The commits stored before the analysis config was recorded are
+		// taken as analyzed with the current one
+		_, err = AdoptCommitFingerprint(repoUrl, filter.Fingerprint)
+		if err != nil {
+			log.Printf("❌ Error adopting analysis config %s: %v\n", repoUrl, err)
+			return err
+		}
+		if watermark != nil {
+			watermark.Fingerprint = filter.Fingerprint
+		}
+	}
+	if watermark == nil {
+		// No watermark yet, adopt the cached commit logs if they already match
+		isUpToDate, err := IsRepoUpToDate(repoPath, filter)
+		if err != nil {
+			log.Printf("❌ Error checking repo %s branch %s: %v\n", repoUrl, branchName, err)
+			return err
+		}
+		if isUpToDate {
+			log.Printf("✅   Repo %s branch %s is up to date 👍👍👍👍👍👍\n", repoUrl, branchName)
+			return SaveBranchState(state)
+		}
+	} else if watermark.Since == insight.Since && watermark.Fingerprint == filter.Fingerprint {
+		if watermark.CommitHash == state.CommitHash {
+			log.Printf("✅   Repo %s branch %s is up to date 👍👍👍👍👍👍\n", repoUrl, branchName)
+			return nil
+		}
+		if IsAncestorCommit(repo, watermark.CommitHash, branchRef.Hash()) {
+			err = AppendBranchCommitLogsToDb(insight, repoPath, filter)
+			if err != nil {
+				return err
+			}
+			return SaveBranchState(state)
+		}
+		log.Printf("    ⚠️ Repo %s branch %s history was rewritten since %s, rebuilding\n", repoUrl, branchName, watermark.CommitHash)
+	} else if watermark.Since != insight.Since {
+		log.Printf("    ⚠️ Repo %s branch %s since changed from %s, rebuilding\n", repoUrl, branchName, watermark.Since)
 	} else {
-		// log.Fatal("❌   Repo branch  is not up to date ❌❌❌ \n", repoUrl, branchName)
+		log.Printf("    ⚠️ Repo %s branch %s analysis config changed, rebuilding\n", repoUrl, branchName)
+	}
+
+	err = RebuildBranchCommitLogsToDb(insight, repoPath, filter)
+	if err != nil {
+		return err
 	}
+	return SaveBranchState(state)
+}
 
-	commitLogs, err := AnalyzeRepoCommitLogs(insight, repoPath, filter)
+// RefreshRepoNicknames applies the current mailmaps and configured authors to
+// the stored commits of the repository.
+func RefreshRepoNicknames(insight *Config, repoPath string) error {
+	repoUrl := GetRepoUrl(insight, repoPath)
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return err
+	}
+	updated, err := RefreshNicknames(repoUrl, NewRepoIdentityResolver(insight, repo))
+	if err != nil {
+		return err
+	}
+	if updated > 0 {
+		log.Printf("✅   Refreshed repo %s nicknames of %d commits, co-authors and owned lines\n", repoUrl, updated)
+	}
+	return nil
+}
+
+// RefreshRepoCommitMessages classifies the stored commits of the repository
+// again with the configured commit types.
+func RefreshRepoCommitMessages(insight *Config, repoPath string) error {
+	repoUrl := GetRepoUrl(insight, repoPath)
+	updated, err := RefreshCommitMessages(repoUrl, insight.CommitTypes)
+	if err != nil {
+		return err
+	}
+	if updated > 0 {
+		log.Printf("✅   Refreshed repo %s types of %d commits\n", repoUrl, updated)
+	}
+	return nil
+}
+
+// HandleDeletedBranchesToDb removes the stored branches of the repository
+// that are not among branchNames, the branches it has now, and the commits
+// only they reached.
+func HandleDeletedBranchesToDb(insight *Config, repoPath string, branchNames []string) error {
+	repoUrl := GetRepoUrl(insight, repoPath)
+	deleted, err := DeleteStaleBranches(repoUrl, branchNames)
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		log.Printf("✅   Removed repo %s deleted branches, %d branch commits\n", repoUrl, deleted)
+	}
+	return nil
+}
+
+// HandleOrphanCommitsToDb removes the commits of the repository that its
+// rewritten branches no longer reach.
+func HandleOrphanCommitsToDb(insight *Config, repoPath string) error {
+	repoUrl := GetRepoUrl(insight, repoPath)
+	deleted, err := DeleteOrphanCommits(repoUrl)
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		log.Printf("✅   Removed repo %s %d orphan commits\n", repoUrl, deleted)
+	}
+	return nil
+}
+
+// HandleRepoTagsToDb stores the tags of the repository and the commits each
+// of them releases. Only the tags that are new, moved or follow another tag
+// than before are walked, the others keep their stored commits until the
+// since boundary changes.
+func HandleRepoTagsToDb(insight *Config, repoPath string) error {
+	repoUrl := GetRepoUrl(insight, repoPath)
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return err
+	}
+	tags, err := GetTags(repo)
+	if err != nil {
+		return err
+	}
+	stored, err := GetRepoTags([]string{repoUrl})
+	if err != nil {
+		return err
+	}
+	stale, removed := staleTags(stored, tags, insight.Since)
+	if len(stale) == 0 && len(removed) == 0 {
+		log.Printf("✅   Repo %s tags are up to date 👍👍👍👍👍👍\n", repoUrl)
+		return nil
+	}
+
+	filter := CheckUpTodateFilter{
+		RepoUrl:   repoUrl,
+		SinceTime: insight.SinceTime(),
+		SinceUTC:  insight.Since,
+	}
+	tagCommits, err := GetTagCommits(repo, tags, stale, filter)
+	if err != nil {
+		return err
+	}
+	replaced := append(sortedKeys(stale), removed...)
+	err = UpdateRepoTags(repoUrl, ToTagModels(repoUrl, insight.Since, tags), replaced, ToTagCommitModels(repoUrl, tagCommits))
+	if err != nil {
+		return err
+	}
+	log.Printf("✅   Cached repo %s %d tags, walked %d\n", repoUrl, len(tags), len(stale))
+	return nil
+}
+
+// HandleRepoOwnershipToDb snapshots the ownership of the configured branches,
+// or of the checked out branch, whenever their head moved.
+func HandleRepoOwnershipToDb(insight *Config, repoPath string) error {
+	repoUrl := GetRepoUrl(insight, repoPath)
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return err
+	}
+	branchNames := insight.Ownership.Branches
+	if len(branchNames) == 0 {
+		head, err := repo.Head()
+		if err != nil {
+			return err
+		}
+		branchNames = []string{head.Name().Short()}
+	}
+	deleted, err := DeleteStaleOwnership(repoUrl, branchNames)
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		log.Printf("✅   Removed repo %s %d ownership rows of unconfigured branches\n", repoUrl, deleted)
+	}
+
+	opts := NewDiffOptions(insight, repoUrl)
+	identities := NewRepoIdentityResolver(insight, repo)
+	for _, branchName := range branchNames {
+		branchRef, err := GetBranchRef(repo, branchName)
+		if err != nil {
+			log.Printf("  ⚠️ Skipping ownership of %s %s: %v\n", repoUrl, branchName, err)
+			continue
+		}
+		commitHash := branchRef.Hash().String()
+		snapshotHash, err := GetOwnershipHead(repoUrl, branchName)
+		if err != nil {
+			return err
+		}
+		if snapshotHash == commitHash {
+			log.Printf("✅   Repo %s branch %s ownership is up to date 👍👍👍👍👍👍\n", repoUrl, branchName)
+			continue
+		}
+		head, err := repo.CommitObject(branchRef.Hash())
+		if err != nil {
+			return err
+		}
+		log.Printf("    ⏳ Blaming repo %s branch %s at %s\n", repoUrl, branchName, commitHash)
+		owned, err := BlameOwnership(head, opts)
+		if err != nil {
+			return err
+		}
+		err = ReplaceOwnership(repoUrl, branchName, commitHash, ToOwnershipModels(repoUrl, branchName, commitHash, owned, identities))
+		if err != nil {
+			return err
+		}
+		log.Printf("✅   Cached repo %s branch %s ownership of %d files\n", repoUrl, branchName, countOwnedFiles(owned))
+	}
+	return nil
+}
+
+func countOwnedFiles(owned []OwnedLines) int {
+	files := make(map[string]bool)
+	for _, o := range owned {
+		files[o.Path] = true
+	}
+	return len(files)
+}
+
+// staleTags compares the stored tags with the tags of the repository, both
+// oldest first. A tag is stale when it is new, points at another commit or
+// follows another tag, as its commits are the ones after that tag, and every
+// tag is stale when the since boundary changed. removed are the stored tags
+// the repository no longer has.
+func staleTags(stored []TagModel, tags []Tag, since string) (map[string]bool, []string) {
+	previous := make(map[string]string, len(stored))
+	for i, tag := range stored {
+		key := tag.CommitHash
+		if i > 0 {
+			key += " " + stored[i-1].TagName + " " + stored[i-1].CommitHash
+		}
+		if tag.Since != since {
+			key = ""
+		}
+		previous[tag.TagName] = key
+	}
+
+	stale := make(map[string]bool)
+	for i, tag := range tags {
+		key := tag.CommitHash
+		if i > 0 {
+			key += " " + tags[i-1].Name + " " + tags[i-1].CommitHash
+		}
+		if previous[tag.Name] != key {
+			stale[tag.Name] = true
+		}
+		delete(previous, tag.Name)
+	}
+	return stale, sortedKeys(previous)
+}
+
+// AppendBranchCommitLogsToDb links the commits that are new on the branch
+// and analyzes the ones the repository has not seen on any branch yet.
+func AppendBranchCommitLogsToDb(insight *Config, repoPath string, filter CheckUpTodateFilter) error {
+	branchFilter := &CommitLogFilter{
+		RepoUrl:    filter.RepoUrl,
+		BranchName: filter.BranchName,
+		IsMerge:    "0,1",
+	}
+	known, err := GetCommitHashes(branchFilter)
+	if err != nil {
+		log.Printf("❌ Error getting cached commits %s %s: %v\n", filter.RepoUrl, filter.BranchName, err)
+		return err
+	}
+	analyzed, err := GetAnalyzedCommitHashes(filter.RepoUrl, filter.Fingerprint)
+	if err != nil {
+		log.Printf("❌ Error getting cached commits %s: %v\n", filter.RepoUrl, err)
+		return err
+	}
+
+	commitLogs, hashes, err := AnalyzeRepoCommitLogs(insight, repoPath, filter, known, analyzed)
 	if err != nil {
 		log.Printf("❌ Error analyzing repository %s: %v\n", repoPath, err)
 		return err
 	}
+	log.Printf("    ⏳ Appending repo %s branch %s %d commits\n", filter.RepoUrl, filter.BranchName, len(hashes))
 
-	log.Printf("    ⏳ Caching repo %s branch %s\n", repoUrl, branchName)
+	_, err = AddBranchCommitLogs(NewCommitLogBatch(filter, commitLogs, hashes))
+	if err != nil {
+		log.Printf("❌ Error caching commit logs: %v\n", err)
+		return err
+	}
+	log.Printf("✅   Appended repo %s branch commit logs\n", filter.RepoUrl)
+	return nil
+}
+
+// RebuildBranchCommitLogsToDb re-walks the whole branch since the configured
+// time and replaces its commit membership. Commits already analyzed on other
+// branches with the same analysis config are linked without recomputing their
+// diff, the ones analyzed with another config are diffed again.
+func RebuildBranchCommitLogsToDb(insight *Config, repoPath string, filter CheckUpTodateFilter) error {
+	analyzed, err := GetAnalyzedCommitHashes(filter.RepoUrl, filter.Fingerprint)
+	if err != nil {
+		log.Printf("❌ Error getting cached commits %s: %v\n", filter.RepoUrl, err)
+		return err
+	}
 
+	commitLogs, hashes, err := AnalyzeRepoCommitLogs(insight, repoPath, filter, nil, analyzed)
+	if err != nil {
+		log.Printf("❌ Error analyzing repository %s: %v\n", repoPath, err)
+		return err
+	}
+
+	log.Printf("    ⏳ Caching repo %s branch %s\n", filter.RepoUrl, filter.BranchName)
+
+	_, err = ReplaceBranchCommitLogs(NewCommitLogBatch(filter, commitLogs, hashes))
+	if err != nil {
+		log.Printf("❌ Error caching commit logs: %v\n", err)
+		return err
+	}
+	log.Printf("✅   Cached repo %s branch commit logs\n", filter.RepoUrl)
+	return nil
+}
+
+func NewCommitLogBatch(filter CheckUpTodateFilter, commitLogs []CommitLog, hashes []string) *CommitLogBatch {
+	return &CommitLogBatch{
+		RepoUrl:         filter.RepoUrl,
+		BranchName:      filter.BranchName,
+		CommitLogs:      ToCommitLogModels(filter, commitLogs),
+		CommitFiles:     ToCommitFileModels(filter.RepoUrl, commitLogs),
+		CommitCoAuthors: ToCommitCoAuthorModels(filter.RepoUrl, commitLogs),
+		CommitChurn:     ToCommitChurnModels(filter.RepoUrl, commitLogs),
+		BranchCommits:   ToBranchCommitModels(filter, hashes),
+	}
+}
+
+func ToCommitLogModels(filter CheckUpTodateFilter, commitLogs []CommitLog) []CommitLogModel {
 	commitLogModels := make([]CommitLogModel, len(commitLogs))
 	for i, commitLog := range commitLogs {
 		commitLogModels[i] = CommitLogModel{
-			RepoUrl:       repoUrl,
-			BranchName:    filter.BranchName,
+			RepoUrl:       filter.RepoUrl,
 			CommitHash:    commitLog.Hash,
 			IsMerge:       commitLog.IsMerge,
 			Message:       commitLog.Message,
 			MessageType:   commitLog.MessageType,
+			Scope:         commitLog.Scope,
+			Breaking:      commitLog.Breaking,
+			Subject:       commitLog.Subject,
+			Footers:       MarshalCommitFooters(commitLog.Footers),
+			IsRevert:      commitLog.IsRevert,
+			RevertsHash:   commitLog.RevertsHash,
 			Date:          commitLog.Date,
 			CommitterDate: commitLog.CommitterDate,
 			Additions:     commitLog.Additions,
 			Deletions:     commitLog.Deletions,
 			Effectives:    commitLog.Effectives,
+			Introduced:    commitLog.Introduced,
 			LanguageStats: commitLog.LanguageStats,
 			AuthorName:    commitLog.AuthorName,
 			AuthorEmail:   commitLog.AuthorEmail,
 			Nickname:      commitLog.Nickname,
+			Fingerprint:   filter.Fingerprint,
 		}
 	}
-	_, err = ReplaceCommitLogs(filter.ToCommitLogFilter(), commitLogModels)
-	if err != nil {
-		log.Printf("❌ Error caching commit logs: %v\n", err)
-		return err
+	return commitLogModels
+}
+
+func ToBranchCommitModels(filter CheckUpTodateFilter, hashes []string) []BranchCommitModel {
+	branchCommitModels := make([]BranchCommitModel, len(hashes))
+	for i, hash := range hashes {
+		branchCommitModels[i] = BranchCommitModel{
+			RepoUrl:    filter.RepoUrl,
+			BranchName: filter.BranchName,
+			CommitHash: hash,
+		}
 	}
-	log.Printf("✅   Cached repo %s branch commit logs\n", repoUrl)
-	return nil
+	return branchCommitModels
 }
 
 func IsRepoUpToDate(repoPath string, filter CheckUpTodateFilter) (bool, error) {
@@ -0,0 +1,113 @@
+package gitinsight
+
+import (
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-git/v6"
+)
+
+// IdentityResolver maps commit identities to the nickname of the configured
+// author, after resolving them through the mailmaps.
+type IdentityResolver struct {
+	mailmap *Mailmap
+	authors []identityAuthor
+}
+
+type identityAuthor struct {
+	nickname string
+	names    []string
+	emails   []string
+	patterns []*regexp.Regexp
+}
+
+// NewIdentityResolver combines the repository mailmap with the global mailmap
+// file and the configured authors. The global mailmap takes precedence.
+func NewIdentityResolver(config *Config, repoMailmap *Mailmap) *IdentityResolver {
+	mailmap := repoMailmap
+	if config.Mailmap != "" {
+		global, err := LoadMailmapFile(config.Mailmap)
+		if err != nil {
+			log.Printf("  ⚠️ Error reading mailmap %s: %v\n", config.Mailmap, err)
+		}
+		mailmap = mailmap.Merge(global)
+	}
+
+	resolver := &IdentityResolver{mailmap: mailmap}
+	for _, author := range config.Authors {
+		a := identityAuthor{
+			nickname: author.Nickname,
+			names:    nonEmpty(append([]string{author.Name}, author.Names...)),
+			emails:   nonEmpty(append([]string{author.Email}, author.Emails...)),
+		}
+		for _, pattern := range author.Patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				log.Printf("  ⚠️ Invalid author pattern %s: %v\n", pattern, err)
+				continue
+			}
+			a.patterns = append(a.patterns, re)
+		}
+		resolver.authors = append(resolver.authors, a)
+	}
+	return resolver
+}
+
+// NewRepoIdentityResolver resolves identities with the .mailmap of the
+// repository.
+func NewRepoIdentityResolver(config *Config, repo *git.Repository) *IdentityResolver {
+	mailmap, err := ReadRepoMailmap(repo)
+	if err != nil {
+		log.Printf("  ⚠️ Error reading repository .mailmap: %v\n", err)
+	}
+	return NewIdentityResolver(config, mailmap)
+}
+
+// Nickname returns the nickname of a commit identity. Both the mailmapped and
+// the raw identity are tried, an email match on any author wins over a name
+// match, which wins over a pattern match. Unknown authors keep their
+// mailmapped name.
+func (r *IdentityResolver) Nickname(authorName string, authorEmail string) string {
+	authorName = strings.TrimSpace(authorName)
+	authorEmail = strings.TrimSpace(authorEmail)
+	name, email := r.mailmap.Resolve(authorName, authorEmail)
+
+	for _, a := range r.authors {
+		if containsFold(a.emails, email) || containsFold(a.emails, authorEmail) {
+			return a.nickname
+		}
+	}
+	for _, a := range r.authors {
+		if containsFold(a.names, name) || containsFold(a.names, authorName) {
+			return a.nickname
+		}
+	}
+	for _, a := range r.authors {
+		for _, re := range a.patterns {
+			if re.MatchString(name+" <"+email+">") || re.MatchString(authorName+" <"+authorEmail+">") {
+				return a.nickname
+			}
+		}
+	}
+	return name
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func nonEmpty(values []string) []string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,266 @@
+package gitinsight
+
+import (
+	"path"
+	"strings"
+)
+
+// LanguageOther is the language of files no table knows.
+const LanguageOther = "Other"
+
+var languageExtensions = map[string]string{
+	".go":      "Go",
+	".c":       "C",
+	".cc":      "C++",
+	".cpp":     "C++",
+	".cxx":     "C++",
+	".hh":      "C++",
+	".hpp":     "C++",
+	".hxx":     "C++",
+	".m":       "Objective-C",
+	".mm":      "Objective-C++",
+	".cs":      "C#",
+	".java":    "Java",
+	".kt":      "Kotlin",
+	".kts":     "Kotlin",
+	".scala":   "Scala",
+	".groovy":  "Groovy",
+	".gradle":  "Groovy",
+	".swift":   "Swift",
+	".rs":      "Rust",
+	".py":      "Python",
+	".pyi":     "Python",
+	".rb":      "Ruby",
+	".php":     "PHP",
+	".pl":      "Perl",
+	".pm":      "Perl",
+	".lua":     "Lua",
+	".r":       "R",
+	".dart":    "Dart",
+	".ex":      "Elixir",
+	".exs":     "Elixir",
+	".erl":     "Erlang",
+	".hs":      "Haskell",
+	".clj":     "Clojure",
+	".fs":      "F#",
+	".zig":     "Zig",
+	".js":      "JavaScript",
+	".mjs":     "JavaScript",
+	".cjs":     "JavaScript",
+	".jsx":     "JavaScript",
+	".ts":      "TypeScript",
+	".mts":     "TypeScript",
+	".cts":     "TypeScript",
+	".tsx":     "TypeScript",
+	".vue":     "Vue",
+	".svelte":  "Svelte",
+	".html":    "HTML",
+	".htm":     "HTML",
+	".css":     "CSS",
+	".scss":    "SCSS",
+	".sass":    "Sass",
+	".less":    "Less",
+	".sh":      "Shell",
+	".bash":    "Shell",
+	".zsh":     "Shell",
+	".fish":    "Shell",
+	".ps1":     "PowerShell",
+	".bat":     "Batchfile",
+	".cmd":     "Batchfile",
+	".sql":     "SQL",
+	".proto":   "Protocol Buffers",
+	".graphql": "GraphQL",
+	".tf":      "HCL",
+	".hcl":     "HCL",
+	".json":    "JSON",
+	".yaml":    "YAML",
+	".yml":     "YAML",
+	".toml":    "TOML",
+	".xml":     "XML",
+	".ini":     "INI",
+	".md":      "Markdown",
+	".rst":     "reStructuredText",
+	".txt":     "Text",
+	".cmake":   "CMake",
+	".mk":      "Makefile",
+}
+
+var languageFilenames = map[string]string{
+	"Makefile":       "Makefile",
+	"GNUmakefile":    "Makefile",
+	"makefile":       "Makefile",
+	"Dockerfile":     "Dockerfile",
+	"Containerfile":  "Dockerfile",
+	"CMakeLists.txt": "CMake",
+	"Jenkinsfile":    "Groovy",
+	"Rakefile":       "Ruby",
+	"Gemfile":        "Ruby",
+	"Vagrantfile":    "Ruby",
+	"BUILD":          "Starlark",
+	"BUILD.bazel":    "Starlark",
+	"WORKSPACE":      "Starlark",
+	"go.mod":         "Go Module",
+	".bashrc":        "Shell",
+	".zshrc":         "Shell",
+	".profile":       "Shell",
+}
+
+var languageInterpreters = map[string]string{
+	"sh":      "Shell",
+	"bash":    "Shell",
+	"zsh":     "Shell",
+	"dash":    "Shell",
+	"ksh":     "Shell",
+	"fish":    "Shell",
+	"python":  "Python",
+	"ruby":    "Ruby",
+	"perl":    "Perl",
+	"php":     "PHP",
+	"lua":     "Lua",
+	"node":    "JavaScript",
+	"deno":    "TypeScript",
+	"ts-node": "TypeScript",
+	"Rscript": "R",
+	"pwsh":    "PowerShell",
+	"tclsh":   "Tcl",
+	"awk":     "Awk",
+}
+
+// LanguageMapping extends the built-in language tables, e.g. mapping the
+// ".tpl" extension to "Go Template". Mappings override the built-in ones.
+type LanguageMapping struct {
+	Language     string   `yaml:"language" json:"language" mapstructure:"language" description:"language name"`
+	Extensions   []string `yaml:"extensions,omitempty" json:"extensions,omitempty" mapstructure:"extensions" description:"file extensions, e.g. .tpl"`
+	Filenames    []string `yaml:"filenames,omitempty" json:"filenames,omitempty" mapstructure:"filenames" description:"file names, e.g. Tiltfile"`
+	Interpreters []string `yaml:"interpreters,omitempty" json:"interpreters,omitempty" mapstructure:"interpreters" description:"shebang interpreters, e.g. bun"`
+}
+
+// LanguageClassifier detects the language of a file from its name, its
+// extension and, for extension-less scripts and C headers, its content. A nil
+// classifier uses the built-in tables only.
+type LanguageClassifier struct {
+	extensions   map[string]string
+	filenames    map[string]string
+	interpreters map[string]string
+}
+
+func NewLanguageClassifier(config *Config) *LanguageClassifier {
+	classifier := &LanguageClassifier{
+		extensions:   map[string]string{},
+		filenames:    map[string]string{},
+		interpreters: map[string]string{},
+	}
+	for _, mapping := range config.Languages {
+		for _, ext := range mapping.Extensions {
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			classifier.extensions[strings.ToLower(ext)] = mapping.Language
+		}
+		for _, name := range mapping.Filenames {
+			classifier.filenames[name] = mapping.Language
+		}
+		for _, interpreter := range mapping.Interpreters {
+			classifier.interpreters[interpreter] = mapping.Language
+		}
+	}
+	return classifier
+}
+
+// Classify returns the language of the file. content is only read when the
+// name is not conclusive and returns the start of the file.
+func (lc *LanguageClassifier) Classify(filePath string, content func() string) string {
+	if lc == nil {
+		lc = &LanguageClassifier{}
+	}
+	name := path.Base(filePath)
+	if language, ok := lookupLanguage(name, lc.filenames, languageFilenames); ok {
+		return language
+	}
+	ext := strings.ToLower(path.Ext(name))
+	if ext != "" {
+		if language, ok := lookupLanguage(ext, lc.extensions, languageExtensions); ok {
+			return language
+		}
+		if ext == ".h" {
+			return classifyHeader(content())
+		}
+	}
+	if interpreter := shebangInterpreter(content()); interpreter != "" {
+		if language, ok := lookupLanguage(interpreter, lc.interpreters, languageInterpreters); ok {
+			return language
+		}
+		// python3.12 -> python
+		if language, ok := lookupLanguage(strings.TrimRight(interpreter, "0123456789."), lc.interpreters, languageInterpreters); ok {
+			return language
+		}
+	}
+	return LanguageOther
+}
+
+func lookupLanguage(key string, custom map[string]string, builtin map[string]string) (string, bool) {
+	if language, ok := custom[key]; ok {
+		return language, true
+	}
+	language, ok := builtin[key]
+	return language, ok
+}
+
+// classifyHeader tells C, C++ and Objective-C headers apart by their content.
+func classifyHeader(content string) string {
+	for _, marker := range []string{"@interface", "@protocol", "#import"} {
+		if strings.Contains(content, marker) {
+			return "Objective-C"
+		}
+	}
+	for _, marker := range []string{"namespace ", "template<", "template <", "class ", "std::", "public:", "private:"} {
+		if strings.Contains(content, marker) {
+			return "C++"
+		}
+	}
+	return "C"
+}
+
+// shebangInterpreter returns the interpreter of a "#!" line, looking through
+// env, e.g. "python3" for "#!/usr/bin/env python3".
+func shebangInterpreter(content string) string {
+	if !strings.HasPrefix(content, "#!") {
+		return ""
+	}
+	line, _, _ := strings.Cut(content[2:], "\n")
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := path.Base(fields[0])
+	if interpreter == "env" {
+		interpreter = ""
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") {
+				interpreter = field
+				break
+			}
+		}
+	}
+	return interpreter
+}
+
+// LanguageStat is the line change of one language in a commit.
+type LanguageStat struct {
+	Files     int `json:"files"`
+	Additions int `json:"additions"`
+	Deletions int `json:"deletions"`
+}
+
+// GetCommitLanguageStats sums the file changes of a commit per language.
+func GetCommitLanguageStats(files []CommitFile) map[string]LanguageStat {
+	languageStats := make(map[string]LanguageStat)
+	for _, file := range files {
+		stat := languageStats[file.Language]
+		stat.Files++
+		stat.Additions += file.Additions
+		stat.Deletions += file.Deletions
+		languageStats[file.Language] = stat
+	}
+	return languageStats
+}
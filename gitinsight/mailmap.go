@@ -0,0 +1,152 @@
+package gitinsight
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v6"
+)
+
+// Mailmap maps the name and email an author committed with to their proper
+// identity, in the format of git's .mailmap:
+//
+//	Proper Name <commit@email>
+//	<proper@email> <commit@email>
+//	Proper Name <proper@email> <commit@email>
+//	Proper Name <proper@email> Commit Name <commit@email>
+type Mailmap struct {
+	entries []mailmapEntry
+}
+
+type mailmapEntry struct {
+	properName  string
+	properEmail string
+	commitName  string
+	commitEmail string
+}
+
+// ParseMailmap reads mailmap lines, skipping comments and malformed lines.
+func ParseMailmap(r io.Reader) *Mailmap {
+	mailmap := &Mailmap{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		entry, ok := parseMailmapLine(line)
+		if ok {
+			mailmap.entries = append(mailmap.entries, entry)
+		}
+	}
+	return mailmap
+}
+
+func parseMailmapLine(line string) (mailmapEntry, bool) {
+	name1, email1, rest, ok := cutMailmapIdentity(line)
+	if !ok {
+		return mailmapEntry{}, false
+	}
+	name2, email2, _, ok := cutMailmapIdentity(rest)
+	if !ok {
+		return mailmapEntry{properName: name1, commitEmail: email1}, true
+	}
+	return mailmapEntry{properName: name1, properEmail: email1, commitName: name2, commitEmail: email2}, true
+}
+
+// cutMailmapIdentity cuts an optional name and a required <email> off the
+// start of s.
+func cutMailmapIdentity(s string) (name string, email string, rest string, ok bool) {
+	start := strings.Index(s, "<")
+	if start < 0 {
+		return "", "", s, false
+	}
+	end := strings.Index(s[start:], ">")
+	if end < 0 {
+		return "", "", s, false
+	}
+	end += start
+	return strings.TrimSpace(s[:start]), strings.TrimSpace(s[start+1 : end]), s[end+1:], true
+}
+
+// Merge appends the entries of other, which take precedence over the entries
+// already in the mailmap.
+func (m *Mailmap) Merge(other *Mailmap) *Mailmap {
+	merged := &Mailmap{}
+	if m != nil {
+		merged.entries = append(merged.entries, m.entries...)
+	}
+	if other != nil {
+		merged.entries = append(merged.entries, other.entries...)
+	}
+	return merged
+}
+
+// Resolve returns the proper name and email of a commit identity. An entry
+// matching name and email wins over one matching the email only, the last
+// entry wins among equals.
+func (m *Mailmap) Resolve(name string, email string) (string, string) {
+	if m == nil {
+		return name, email
+	}
+	var match *mailmapEntry
+	for i := range m.entries {
+		entry := &m.entries[i]
+		if !strings.EqualFold(entry.commitEmail, email) {
+			continue
+		}
+		if entry.commitName != "" && !strings.EqualFold(entry.commitName, name) {
+			continue
+		}
+		if match != nil && match.commitName != "" && entry.commitName == "" {
+			continue
+		}
+		match = entry
+	}
+	if match == nil {
+		return name, email
+	}
+	if match.properName != "" {
+		name = match.properName
+	}
+	if match.properEmail != "" {
+		email = match.properEmail
+	}
+	return name, email
+}
+
+// LoadMailmapFile reads a mailmap file, a missing file is an empty mailmap.
+func LoadMailmapFile(path string) (*Mailmap, error) {
+	f, err := os.Open(ExpandHome(path))
+	if os.IsNotExist(err) {
+		return &Mailmap{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseMailmap(f), nil
+}
+
+// ReadRepoMailmap reads the .mailmap committed on the HEAD of the repository,
+// so every branch resolves identities the same way.
+func ReadRepoMailmap(repo *git.Repository) (*Mailmap, error) {
+	head, err := repo.Head()
+	if err != nil {
+		return nil, err
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	file, err := commit.File(".mailmap")
+	if err != nil {
+		// No .mailmap in the repository
+		return &Mailmap{}, nil
+	}
+	reader, err := file.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ParseMailmap(reader), nil
+}
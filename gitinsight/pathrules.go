@@ -0,0 +1,125 @@
+package gitinsight
+
+import (
+	"path"
+	"strings"
+)
+
+// DefaultExcludePaths are generated, vendored and lock files that are not
+// counted unless the built-in defaults are turned off.
+var DefaultExcludePaths = []string{
+	"**/vendor/**",
+	"**/node_modules/**",
+	"dist/**",
+	"*.lock",
+	"package-lock.json",
+	"pnpm-lock.yaml",
+	"go.sum",
+	"*.min.js",
+	"*.min.css",
+	"*.map",
+	"*.pb.go",
+	"*.pb.gw.go",
+	"*_pb2.py",
+	"*_pb2_grpc.py",
+	"*.pb.h",
+	"*.pb.cc",
+	"*_generated.go",
+	"*.gen.go",
+	"*.generated.*",
+	"zz_generated.*",
+}
+
+type PathRules struct {
+	Include    []string `yaml:"include,omitempty" json:"include,omitempty" mapstructure:"include" description:"only count paths matching these globs"`
+	Exclude    []string `yaml:"exclude,omitempty" json:"exclude,omitempty" mapstructure:"exclude" description:"do not count paths matching these globs"`
+	NoDefaults bool     `yaml:"no_defaults,omitempty" json:"no_defaults,omitempty" mapstructure:"no_defaults" description:"turn off the built-in generated and lock file excludes" default:"false"`
+}
+
+// PathMatcher decides which paths of a repository are counted. A nil
+// matcher counts every path.
+type PathMatcher struct {
+	include []string
+	exclude []string
+}
+
+// NewPathMatcher merges the global path rules with the rules of the
+// configured repository. Rule changes change the AnalysisFingerprint, the
+// stored commits are analyzed again on the next sync.
+func NewPathMatcher(config *Config, repoUrl string) *PathMatcher {
+	matcher := &PathMatcher{}
+	if !config.Paths.NoDefaults {
+		matcher.exclude = append(matcher.exclude, DefaultExcludePaths...)
+	}
+	matcher.include = append(matcher.include, config.Paths.Include...)
+	matcher.exclude = append(matcher.exclude, config.Paths.Exclude...)
+	for _, repo := range GetRepos(config) {
+		if repo.Url == repoUrl {
+			matcher.include = append(matcher.include, repo.Include...)
+			matcher.exclude = append(matcher.exclude, repo.Exclude...)
+		}
+	}
+	return matcher
+}
+
+// Match reports whether the path is counted: it matches an include glob, if
+// there are any, and no exclude glob.
+func (m *PathMatcher) Match(filePath string) bool {
+	if m == nil {
+		return true
+	}
+	if len(m.include) > 0 && !matchAnyGlob(m.include, filePath) {
+		return false
+	}
+	return !matchAnyGlob(m.exclude, filePath)
+}
+
+func matchAnyGlob(patterns []string, filePath string) bool {
+	for _, pattern := range patterns {
+		if MatchGlob(pattern, filePath) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchGlob matches a slash separated path against a gitignore style glob:
+// "*" matches within a path segment, "**" matches any number of segments and
+// a pattern without a slash matches the file name in any directory.
+func MatchGlob(pattern string, filePath string) bool {
+	pattern = strings.TrimPrefix(pattern, "/")
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(filePath, "/"))
+}
+
+func matchSegments(patterns []string, segments []string) bool {
+	for len(patterns) > 0 {
+		if patterns[0] == "**" {
+			rest := patterns[1:]
+			if len(rest) == 0 {
+				return len(segments) > 0
+			}
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(rest, segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		ok, err := path.Match(patterns[0], segments[0])
+		if err != nil || !ok {
+			return false
+		}
+		patterns = patterns[1:]
+		segments = segments[1:]
+	}
+	return len(segments) == 0
+}
@@ -0,0 +1,39 @@
+package gitinsight
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	revertHashRe    = regexp.MustCompile(`(?i)This reverts commit ([0-9a-f]{7,40})`)
+	revertSubjectRe = regexp.MustCompile(`^(Revert|Reapply) ".*"`)
+	commitHashRe    = regexp.MustCompile(`\b[0-9a-fA-F]{7,40}\b`)
+)
+
+// ParseRevert recognizes the message of a revert: git's `Revert "<subject>"`
+// with its "This reverts commit <hash>." line, or a Conventional Commits
+// revert naming the reverted commits in a Refs footer. The hash is empty
+// when the message does not name the reverted commit.
+func ParseRevert(message string, types []string) (bool, string) {
+	if m := revertHashRe.FindStringSubmatch(message); m != nil {
+		return true, strings.ToLower(m[1])
+	}
+	message = strings.TrimSpace(message)
+	if revertSubjectRe.MatchString(message) {
+		return true, ""
+	}
+	conventional := ParseConventionalCommit(message, types)
+	if conventional.Type != "revert" {
+		return false, ""
+	}
+	for _, footer := range conventional.Footers {
+		if !strings.EqualFold(footer.Token, "Refs") {
+			continue
+		}
+		if hash := commitHashRe.FindString(footer.Value); hash != "" {
+			return true, strings.ToLower(hash)
+		}
+	}
+	return true, ""
+}
@@ -0,0 +1,349 @@
+package gitinsight
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Source is an organization, or group, whose repositories are discovered
+// through the API of its forge.
+type Source struct {
+	Type     string   `yaml:"type" json:"type" mapstructure:"type" description:"github, gitlab or gitea"`
+	Api      string   `yaml:"api,omitempty" json:"api,omitempty" mapstructure:"api" description:"api base url, defaults to https://api.github.com or https://gitlab.com/api/v4"`
+	Org      string   `yaml:"org" json:"org" mapstructure:"org" description:"organization, or gitlab group including its subgroups"`
+	Token    string   `yaml:"token,omitempty" json:"token,omitempty" mapstructure:"token" description:"api token, also used to clone over https"`
+	User     string   `yaml:"user,omitempty" json:"user,omitempty" mapstructure:"user" description:"user cloning with the token, defaults to x-access-token on github and oauth2 otherwise"`
+	SSH      bool     `yaml:"ssh,omitempty" json:"ssh,omitempty" mapstructure:"ssh" description:"clone over ssh with the auths of the host"`
+	Include  []string `yaml:"include,omitempty" json:"include,omitempty" mapstructure:"include" description:"only repositories whose path in the organization matches these globs"`
+	Exclude  []string `yaml:"exclude,omitempty" json:"exclude,omitempty" mapstructure:"exclude" description:"no repositories whose path in the organization matches these globs"`
+	Archived bool     `yaml:"archived,omitempty" json:"archived,omitempty" mapstructure:"archived" description:"also archived repositories"`
+	Forks    bool     `yaml:"forks,omitempty" json:"forks,omitempty" mapstructure:"forks" description:"also forks"`
+}
+
+// SourceRepo is a repository listed by the API of a source.
+type SourceRepo struct {
+	// Path is the path of the repository in the organization
+	Path     string
+	CloneUrl string
+	SSHUrl   string
+	Archived bool
+	Fork     bool
+}
+
+const sourcePageSize = 50
+
+var sourceClient = &http.Client{Timeout: 30 * time.Second}
+
+func (source *Source) key() string {
+	return source.Type + " " + source.api() + " " + source.Org
+}
+
+func (source *Source) api() string {
+	if source.Api != "" {
+		return strings.TrimSuffix(source.Api, "/")
+	}
+	switch source.Type {
+	case "github":
+		return "https://api.github.com"
+	case "gitlab":
+		return "https://gitlab.com/api/v4"
+	}
+	return ""
+}
+
+func (source *Source) user() string {
+	if source.User != "" {
+		return source.User
+	}
+	if source.Type == "github" {
+		return "x-access-token"
+	}
+	return "oauth2"
+}
+
+// discoveredRepos are the repositories each source listed last, by source
+// key. They live outside of the configs, so that the copy the sync refreshes
+// and the ones the handlers read see the same repositories.
+var (
+	discoveredReposMu sync.RWMutex
+	discoveredRepos   = make(map[string][]Repo)
+)
+
+// RefreshSourceRepos lists the repositories of the sources again. A source
+// whose API fails keeps the repositories it listed before.
+func RefreshSourceRepos(config *Config) {
+	for i := range config.Sources {
+		source := &config.Sources[i]
+		discovered, err := DiscoverRepos(source)
+		if err != nil {
+			log.Printf("  ⚠️ Error discovering the repositories of %s: %v\n", source.key(), err)
+			continue
+		}
+		log.Printf("Discovered %d repositories in %s\n", len(discovered), source.key())
+		discoveredReposMu.Lock()
+		discoveredRepos[source.key()] = discovered
+		discoveredReposMu.Unlock()
+	}
+}
+
+// GetRepos returns the configured repositories followed by the ones the
+// sources of the config discovered. The configured repositories take
+// precedence over the discovered ones of the same url.
+func GetRepos(config *Config) []Repo {
+	if len(config.Sources) == 0 {
+		return config.Repos
+	}
+	repos := append(make([]Repo, 0, len(config.Repos)), config.Repos...)
+	urls := make(map[string]bool, len(config.Repos))
+	for _, repo := range config.Repos {
+		urls[repo.Url] = true
+	}
+	discoveredReposMu.RLock()
+	defer discoveredReposMu.RUnlock()
+	for i := range config.Sources {
+		for _, repo := range discoveredRepos[config.Sources[i].key()] {
+			if urls[repo.Url] {
+				continue
+			}
+			urls[repo.Url] = true
+			repos = append(repos, repo)
+		}
+	}
+	return repos
+}
+
+// DiscoverRepos lists the repositories of a source matching its filters.
+func DiscoverRepos(source *Source) ([]Repo, error) {
+	listed, err := ListSourceRepos(source)
+	if err != nil {
+		return nil, err
+	}
+	repos := make([]Repo, 0, len(listed))
+	for _, item := range listed {
+		if (item.Archived && !source.Archived) || (item.Fork && !source.Forks) {
+			continue
+		}
+		if len(source.Include) > 0 && !matchAnyGlob(source.Include, item.Path) {
+			continue
+		}
+		if matchAnyGlob(source.Exclude, item.Path) {
+			continue
+		}
+		repo := Repo{Url: item.CloneUrl}
+		if source.SSH {
+			repo.Url = item.SSHUrl
+		} else if source.Token != "" {
+			repo.User = source.user()
+			repo.Password = source.Token
+		}
+		if repo.Url == "" {
+			continue
+		}
+		repos = append(repos, repo)
+	}
+	return repos, nil
+}
+
+// ListSourceRepos lists every repository of the organization of a source,
+// page by page. The next page is the one the Link header, or the X-Next-Page
+// header of GitLab, points at; without them pages are listed until one comes
+// back empty, as the API may cap the page size.
+func ListSourceRepos(source *Source) ([]SourceRepo, error) {
+	if source.Org == "" {
+		return nil, fmt.Errorf("no organization in the %s source", source.Type)
+	}
+	if source.api() == "" {
+		return nil, fmt.Errorf("no api base url in the %s source of %s", source.Type, source.Org)
+	}
+	var list func(source *Source, endpoint string) ([]SourceRepo, http.Header, error)
+	var endpoint string
+	switch source.Type {
+	case "github", "gitea":
+		list = listOrgRepos
+		endpoint = fmt.Sprintf("%s/orgs/%s/repos?page=1", source.api(), url.PathEscape(source.Org))
+		if source.Type == "github" {
+			endpoint += fmt.Sprintf("&per_page=%d&type=all", sourcePageSize)
+		} else {
+			endpoint += fmt.Sprintf("&limit=%d", sourcePageSize)
+		}
+	case "gitlab":
+		list = listGroupProjects
+		endpoint = fmt.Sprintf("%s/groups/%s/projects?include_subgroups=true&page=1&per_page=%d",
+			source.api(), url.PathEscape(source.Org), sourcePageSize)
+	default:
+		return nil, fmt.Errorf("invalid source type %q, must be one of: github, gitlab, gitea", source.Type)
+	}
+
+	repos := make([]SourceRepo, 0)
+	for endpoint != "" {
+		listed, header, err := list(source, endpoint)
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, listed...)
+		endpoint, err = nextPage(endpoint, header, len(listed))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return repos, nil
+}
+
+// nextPage returns the url of the page after endpoint, empty after the last
+// page.
+func nextPage(endpoint string, header http.Header, listed int) (string, error) {
+	if links := header.Values("Link"); len(links) > 0 {
+		return nextLink(endpoint, links)
+	}
+	page := 0
+	if values, ok := header[http.CanonicalHeaderKey("X-Next-Page")]; ok {
+		if len(values) == 0 || values[0] == "" {
+			return "", nil
+		}
+		next, err := strconv.Atoi(values[0])
+		if err != nil {
+			return "", fmt.Errorf("invalid X-Next-Page %q", values[0])
+		}
+		page = next
+	} else if listed == 0 {
+		return "", nil
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	if page == 0 {
+		current, _ := strconv.Atoi(query.Get("page"))
+		page = max(current, 1) + 1
+	}
+	query.Set("page", strconv.Itoa(page))
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// nextLink returns the rel="next" url of Link headers, resolved against
+// endpoint, or empty when there is none.
+func nextLink(endpoint string, links []string) (string, error) {
+	for _, header := range links {
+		for _, link := range strings.Split(header, ",") {
+			target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+			if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range strings.Split(params, ";") {
+				name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if name != "rel" || !slices.Contains(strings.Fields(strings.Trim(value, `"`)), "next") {
+					continue
+				}
+				base, err := url.Parse(endpoint)
+				if err != nil {
+					return "", err
+				}
+				next, err := base.Parse(target[1 : len(target)-1])
+				if err != nil {
+					return "", err
+				}
+				return next.String(), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// listOrgRepos lists a page of the repositories of a GitHub or Gitea
+// organization, both APIs share the endpoint and the fields.
+func listOrgRepos(source *Source, endpoint string) ([]SourceRepo, http.Header, error) {
+	var items []struct {
+		Name     string `json:"name"`
+		CloneUrl string `json:"clone_url"`
+		SSHUrl   string `json:"ssh_url"`
+		Archived bool   `json:"archived"`
+		Fork     bool   `json:"fork"`
+	}
+	header, err := getSourceJson(source, endpoint, &items)
+	if err != nil {
+		return nil, nil, err
+	}
+	repos := make([]SourceRepo, len(items))
+	for i, item := range items {
+		repos[i] = SourceRepo{
+			Path:     item.Name,
+			CloneUrl: item.CloneUrl,
+			SSHUrl:   item.SSHUrl,
+			Archived: item.Archived,
+			Fork:     item.Fork,
+		}
+	}
+	return repos, header, nil
+}
+
+// listGroupProjects lists a page of the projects of a GitLab group and its
+// subgroups, with their path below the group.
+func listGroupProjects(source *Source, endpoint string) ([]SourceRepo, http.Header, error) {
+	var items []struct {
+		Path              string    `json:"path"`
+		PathWithNamespace string    `json:"path_with_namespace"`
+		HttpUrlToRepo     string    `json:"http_url_to_repo"`
+		SSHUrlToRepo      string    `json:"ssh_url_to_repo"`
+		Archived          bool      `json:"archived"`
+		ForkedFromProject *struct{} `json:"forked_from_project"`
+	}
+	header, err := getSourceJson(source, endpoint, &items)
+	if err != nil {
+		return nil, nil, err
+	}
+	repos := make([]SourceRepo, len(items))
+	for i, item := range items {
+		path := strings.TrimPrefix(item.PathWithNamespace, strings.Trim(source.Org, "/")+"/")
+		if path == "" {
+			path = item.Path
+		}
+		repos[i] = SourceRepo{
+			Path:     path,
+			CloneUrl: item.HttpUrlToRepo,
+			SSHUrl:   item.SSHUrlToRepo,
+			Archived: item.Archived,
+			Fork:     item.ForkedFromProject != nil,
+		}
+	}
+	return repos, header, nil
+}
+
+// getSourceJson decodes the response of an API endpoint into v and returns
+// its headers, which link the next page.
+func getSourceJson(source *Source, endpoint string, v any) (http.Header, error) {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if source.Token != "" {
+		switch source.Type {
+		case "gitlab":
+			req.Header.Set("PRIVATE-TOKEN", source.Token)
+		case "gitea":
+			req.Header.Set("Authorization", "token "+source.Token)
+		default:
+			req.Header.Set("Authorization", "Bearer "+source.Token)
+		}
+	}
+	resp, err := sourceClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", req.URL.Redacted(), resp.Status)
+	}
+	return resp.Header, json.NewDecoder(resp.Body).Decode(v)
+}
@@ -1,14 +1,13 @@
 package gitinsight
 
 import (
-	"io"
-	"net/url"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/go-git/go-git/v6"
 	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/transport"
 )
 
 func ParseTime(t string) time.Time {
@@ -45,11 +44,11 @@ func IsBeforeSince(c *object.Commit, filter CheckUpTodateFilter) bool {
 
 func FindAuth(config *Config, repo *Repo) (*Auth, error) {
 	for _, auth := range config.Auths {
-		uri, err := url.Parse(repo.Url)
+		endpoint, err := transport.NewEndpoint(repo.Url)
 		if err != nil {
 			return nil, err
 		}
-		host := strings.ToLower(uri.Host)
+		host := strings.ToLower(endpoint.Host)
 		domain := strings.ToLower(auth.Domain)
 		if strings.Contains(host, domain) {
 			return &auth, nil
@@ -58,17 +57,6 @@ func FindAuth(config *Config, repo *Repo) (*Auth, error) {
 	return nil, nil
 }
 
-func FindNickname(config *Config, authorName string, authorEmail string) string {
-	for _, author := range config.Authors {
-		authorName = strings.TrimSpace(authorName)
-		authorEmail = strings.TrimSpace(authorEmail)
-		if strings.EqualFold(author.Name, authorName) || strings.EqualFold(author.Email, authorEmail) {
-			return author.Nickname
-		}
-	}
-	return authorName
-}
-
 func GetRepoRemoteUrl(repoPath string) string {
 	repo, err := git.PlainOpen(repoPath)
 	if err != nil {
@@ -81,73 +69,16 @@ func GetRepoRemoteUrl(repoPath string) string {
 	return remote.Config().URLs[0]
 }
 
+// GetMessageType returns the Conventional Commits type of a message, empty
+// when the header is not a conventional one with a default type.
 func GetMessageType(message string) string {
-	message = strings.ReplaceAll(message, "：", ":")
-	if !strings.Contains(message, ":") {
-		return ""
-	}
-	slits := strings.Split(message, ":")
-	spec := strings.TrimSpace(slits[0])
-
-	return extractLetters(spec)
+	return ParseConventionalCommit(message, nil).Type
 }
 
 func IsAsciiLetter(r rune) bool {
 	return (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z')
 }
 
-func extractLetters(s string) string {
-	var result []rune
-	for _, r := range s {
-		if (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') {
-			result = append(result, r)
-		} else {
-			break
-		}
-	}
-	return string(result)
-}
-
-func GetLanguageStatPatch(c *object.Commit) map[string]int {
-	languageStats := make(map[string]int)
-
-	// 如果有 parent，拿 diff
-	if c.NumParents() > 0 {
-		parent, err := c.Parent(0)
-		if err != nil {
-			return languageStats
-		}
-
-		patch, err := parent.Patch(c)
-		if err != nil {
-			return languageStats
-		}
-
-		for _, fileStat := range patch.FilePatches() {
-			from, to := fileStat.Files()
-			var filename string
-			if to != nil {
-				filename = to.Path()
-			} else if from != nil {
-				filename = from.Path()
-			}
-
-			ext := filepath.Ext(filename)
-			languageStats[ext]++
-		}
-	} else {
-		// 第一个 commit，没有 parent，就遍历所有文件
-		fIter, _ := c.Files()
-		fIter.ForEach(func(f *object.File) error {
-			ext := filepath.Ext(f.Name)
-			languageStats[ext]++
-			return nil
-		})
-	}
-
-	return languageStats
-}
-
 func GetLanguageStatsALl(c *object.Commit) map[string]int {
 	languageStats := make(map[string]int)
 	f, err := c.Files()
@@ -163,50 +94,3 @@ func GetLanguageStatsALl(c *object.Commit) map[string]int {
 	})
 	return languageStats
 }
-
-func GetCommitDiff(c *object.Commit) (int, int) {
-
-	// Get diff stats
-	additions, deletions := 0, 0
-	// var fileStats object.FileStats
-	// if c.NumParents() > 0 {
-	// 	parent, err := c.Parents().Next()
-	// 	if err == nil {
-	// 		parentTree, _ := parent.Tree()
-	// 		commitTree, _ := c.Tree()
-	// 		changes, _ := object.DiffTree(parentTree, commitTree)
-	// 		patch, _ := changes.Patch()
-	// 		if patch != nil {
-	// 			fileStats = patch.Stats()
-	// 		}
-	// 	}
-	// }
-	// for _, stat := range fileStats {
-	// 	additions += stat.Addition
-	// 	deletions += stat.Deletion
-	// }
-	if c.NumParents() > 0 {
-		parentIter := c.Parents()
-		for {
-			parent, err := parentIter.Next()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				break
-			}
-			parentTree, _ := parent.Tree()
-			commitTree, _ := c.Tree()
-			changes, _ := object.DiffTree(parentTree, commitTree)
-			patch, _ := changes.Patch()
-			if patch != nil {
-				stats := patch.Stats()
-				for _, stat := range stats {
-					additions += stat.Addition
-					deletions += stat.Deletion
-				}
-			}
-		}
-	}
-	return additions, deletions
-}
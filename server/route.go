@@ -13,6 +13,17 @@ func RegisterRoute(g *gin.RouterGroup) {
 	g.GET("/ranking", GetRanking)
 	g.GET("/heatmap", GetCommitHeatmap)
 	g.GET("/period", GetCommitPeriod)
+	g.GET("/files", GetFiles)
+	g.GET("/directories", GetDirectories)
+	g.GET("/languages", GetLanguages)
+	g.GET("/hotspots", GetHotspots)
+	g.GET("/coupling", GetCoupling)
+	g.GET("/changelog", GetChangelog)
+	g.GET("/releases", GetReleases)
+	g.GET("/dora", GetDora)
+	g.GET("/reverts", GetReverts)
+	g.GET("/ownership", GetOwnership)
+	g.GET("/risk/bus-factor", GetBusFactor)
 }
 
 func getFilterFromContext(c *gin.Context) *gitinsight.CommitLogFilter {
@@ -23,11 +34,16 @@ func getFilterFromContext(c *gin.Context) *gitinsight.CommitLogFilter {
 	authors := c.Query("authors")
 	isMerge := c.Query("isMerge")
 	messageType := c.Query("messageType")
+	scope := c.Query("scope")
+	breaking := c.Query("breaking")
 	period := c.Query("period")
+	groupBy := c.Query("groupBy")
+	attribution := c.Query("attribution")
 
 	commitHash := c.Query("commitHash")
 	leEffective := c.Query("leEffective")
 	geEffective := c.Query("geEffective")
+	path := c.Query("path")
 
 	offset, err := xcast.ToIntE(c.Query("offset"))
 	if err != nil {
@@ -37,29 +53,47 @@ func getFilterFromContext(c *gin.Context) *gitinsight.CommitLogFilter {
 	if err != nil {
 		limit = 50
 	}
+	depth, err := xcast.ToIntE(c.Query("depth"))
+	if err != nil {
+		depth = 1
+	}
 	if since == "" {
 		since = GetConfig().Insight.Since
 	}
+	if attribution == "" {
+		attribution = GetConfig().Insight.Attribution
+	}
+	excludeReverts := GetConfig().Insight.ExcludeReverts
+	if value := c.Query("excludeReverts"); value != "" {
+		excludeReverts = xcast.ToBool(value)
+	}
 
 	sinceTime := gitinsight.ParseTime(since)
 	untilTime := gitinsight.ParseTime(until)
 
 	filter := &gitinsight.CommitLogFilter{
-		Offset:      offset,
-		Limit:       limit,
-		SinceUTC:    since,
-		UntilUTC:    until,
-		SinceTime:   sinceTime,
-		UntilTime:   untilTime,
-		RepoUrl:     repos,
-		BranchName:  branches,
-		CommitHash:  commitHash,
-		Nickname:    authors,
-		IsMerge:     isMerge,
-		MessageType: messageType,
-		Period:      period,
-		LeEffective: leEffective,
-		GeEffective: geEffective,
+		Offset:         offset,
+		Limit:          limit,
+		SinceUTC:       since,
+		UntilUTC:       until,
+		SinceTime:      sinceTime,
+		UntilTime:      untilTime,
+		RepoUrl:        repos,
+		BranchName:     branches,
+		CommitHash:     commitHash,
+		Nickname:       authors,
+		Attribution:    attribution,
+		ExcludeReverts: excludeReverts,
+		IsMerge:        isMerge,
+		MessageType:    messageType,
+		Scope:          scope,
+		Breaking:       breaking,
+		Period:         period,
+		GroupBy:        groupBy,
+		LeEffective:    leEffective,
+		GeEffective:    geEffective,
+		Path:           path,
+		Depth:          depth,
 	}
 	return filter
 }
@@ -78,8 +112,9 @@ func GetRanking(c *gin.Context) {
 			"code":    200,
 			"message": "success",
 			"meta": gin.H{
-				"since": filter.SinceUTC,
-				"until": filter.UntilUTC,
+				"since":       filter.SinceUTC,
+				"until":       filter.UntilUTC,
+				"attribution": filter.Attribution,
 			},
 			"data": ranking,
 		})
@@ -122,8 +157,9 @@ func GetContributors(c *gin.Context) {
 			"code":    200,
 			"message": "success",
 			"meta": gin.H{
-				"since": filter.SinceUTC,
-				"until": filter.UntilUTC,
+				"since":       filter.SinceUTC,
+				"until":       filter.UntilUTC,
+				"attribution": filter.Attribution,
 			},
 			"data": contributors,
 		})
@@ -219,3 +255,322 @@ func GetCommitPeriod(c *gin.Context) {
 		})
 	}
 }
+
+func GetFiles(c *gin.Context) {
+	filter := getFilterFromContext(c)
+	files, total, err := gitinsight.GetFileStats(filter)
+	if err != nil {
+		c.JSON(200, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	} else {
+		c.JSON(200, gin.H{
+			"code":    200,
+			"message": "success",
+			"meta": gin.H{
+				"offset": filter.Offset,
+				"limit":  filter.Limit,
+				"since":  filter.SinceUTC,
+				"until":  filter.UntilUTC,
+				"path":   filter.Path,
+				"total":  total,
+			},
+			"data": files,
+		})
+	}
+}
+
+func GetHotspots(c *gin.Context) {
+	filter := getFilterFromContext(c)
+	hotspots, total, err := gitinsight.GetHotspots(filter)
+	if err != nil {
+		c.JSON(200, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	} else {
+		c.JSON(200, gin.H{
+			"code":    200,
+			"message": "success",
+			"meta": gin.H{
+				"offset": filter.Offset,
+				"limit":  filter.Limit,
+				"since":  filter.SinceUTC,
+				"until":  filter.UntilUTC,
+				"path":   filter.Path,
+				"total":  total,
+			},
+			"data": hotspots,
+		})
+	}
+}
+
+func GetCoupling(c *gin.Context) {
+	filter := getFilterFromContext(c)
+	if repo := c.Query("repo"); repo != "" {
+		filter.RepoUrl = repo
+	}
+	coupling := GetConfig().Insight.Coupling
+	if minSupport, err := xcast.ToIntE(c.Query("minSupport")); err == nil && minSupport > 0 {
+		coupling.MinSupport = minSupport
+	}
+	if minConfidence, err := xcast.ToFloat64E(c.Query("minConfidence")); err == nil && minConfidence > 0 {
+		coupling.MinConfidence = minConfidence
+	}
+	pairs, total, err := gitinsight.GetCoupling(filter, &coupling)
+	if err != nil {
+		c.JSON(200, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	} else {
+		c.JSON(200, gin.H{
+			"code":    200,
+			"message": "success",
+			"meta": gin.H{
+				"offset":        filter.Offset,
+				"limit":         filter.Limit,
+				"since":         filter.SinceUTC,
+				"until":         filter.UntilUTC,
+				"path":          filter.Path,
+				"minSupport":    coupling.MinSupport,
+				"minConfidence": coupling.MinConfidence,
+				"total":         total,
+			},
+			"data": pairs,
+		})
+	}
+}
+
+func GetDirectories(c *gin.Context) {
+	filter := getFilterFromContext(c)
+	directories, total, err := gitinsight.GetDirectoryStats(filter)
+	if err != nil {
+		c.JSON(200, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	} else {
+		c.JSON(200, gin.H{
+			"code":    200,
+			"message": "success",
+			"meta": gin.H{
+				"offset": filter.Offset,
+				"limit":  filter.Limit,
+				"since":  filter.SinceUTC,
+				"until":  filter.UntilUTC,
+				"path":   filter.Path,
+				"depth":  filter.Depth,
+				"total":  total,
+			},
+			"data": directories,
+		})
+	}
+}
+
+func GetLanguages(c *gin.Context) {
+	filter := getFilterFromContext(c)
+	languages, err := gitinsight.GetLanguageStats(filter)
+	if err != nil {
+		c.JSON(200, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	} else {
+		c.JSON(200, gin.H{
+			"code":    200,
+			"message": "success",
+			"meta": gin.H{
+				"since":   filter.SinceUTC,
+				"until":   filter.UntilUTC,
+				"period":  filter.Period,
+				"groupBy": filter.GroupBy,
+			},
+			"data": languages,
+		})
+	}
+}
+
+func GetChangelog(c *gin.Context) {
+	insight := &GetConfig().Insight
+	repoUrl := c.Query("repo")
+	from := c.Query("from")
+	to := c.Query("to")
+	format := c.Query("format")
+
+	if !gitinsight.IsConfiguredRepo(insight, repoUrl) {
+		c.JSON(200, gin.H{
+			"code":    400,
+			"message": "unknown repo: " + repoUrl,
+			"data":    nil,
+		})
+		return
+	}
+	changelog, err := gitinsight.GenerateChangelog(insight, gitinsight.GetRepoPath(insight, repoUrl), repoUrl, from, to)
+	if err != nil {
+		c.JSON(200, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+	if format == "markdown" || format == "md" {
+		c.Data(200, "text/markdown; charset=utf-8", []byte(gitinsight.RenderChangelogMarkdown(changelog)))
+		return
+	}
+	c.JSON(200, gin.H{
+		"code":    200,
+		"message": "success",
+		"meta": gin.H{
+			"repo": repoUrl,
+			"from": changelog.From,
+			"to":   changelog.To,
+		},
+		"data": changelog,
+	})
+}
+
+func GetReleases(c *gin.Context) {
+	filter := getFilterFromContext(c)
+	releases, err := gitinsight.GetReleases(filter)
+	if err != nil {
+		c.JSON(200, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	} else {
+		c.JSON(200, gin.H{
+			"code":    200,
+			"message": "success",
+			"meta": gin.H{
+				"since": filter.SinceUTC,
+				"until": filter.UntilUTC,
+			},
+			"data": releases,
+		})
+	}
+}
+
+func GetDora(c *gin.Context) {
+	filter := getFilterFromContext(c)
+	dora, err := gitinsight.GetDora(filter, &GetConfig().Insight.Dora)
+	if err != nil {
+		c.JSON(200, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	} else {
+		c.JSON(200, gin.H{
+			"code":    200,
+			"message": "success",
+			"meta": gin.H{
+				"since":  filter.SinceUTC,
+				"until":  filter.UntilUTC,
+				"period": filter.Period,
+			},
+			"data": dora,
+		})
+	}
+}
+
+func GetReverts(c *gin.Context) {
+	filter := getFilterFromContext(c)
+	reverts, err := gitinsight.GetRevertStats(filter)
+	if err != nil {
+		c.JSON(200, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	} else {
+		c.JSON(200, gin.H{
+			"code":    200,
+			"message": "success",
+			"meta": gin.H{
+				"since": filter.SinceUTC,
+				"until": filter.UntilUTC,
+			},
+			"data": reverts,
+		})
+	}
+}
+
+func GetOwnership(c *gin.Context) {
+	filter := getFilterFromContext(c)
+	if repo := c.Query("repo"); repo != "" {
+		filter.RepoUrl = repo
+	}
+	if branch := c.Query("branch"); branch != "" {
+		filter.BranchName = branch
+	}
+	ownership, err := gitinsight.GetOwnership(filter, gitinsight.GetTeams(&GetConfig().Insight))
+	if err != nil {
+		c.JSON(200, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	} else {
+		c.JSON(200, gin.H{
+			"code":    200,
+			"message": "success",
+			"meta": gin.H{
+				"path":  filter.Path,
+				"depth": filter.Depth,
+			},
+			"data": ownership,
+		})
+	}
+}
+
+func GetBusFactor(c *gin.Context) {
+	filter := getFilterFromContext(c)
+	busFactor := GetConfig().Insight.BusFactor
+	if threshold, err := xcast.ToFloat64E(c.Query("threshold")); err == nil && threshold > 0 {
+		busFactor.Threshold = threshold
+	}
+	if inactiveDays, err := xcast.ToIntE(c.Query("inactiveDays")); err == nil && inactiveDays > 0 {
+		busFactor.InactiveDays = inactiveDays
+	}
+	risks, err := gitinsight.GetBusFactor(filter, &busFactor)
+	if err != nil {
+		c.JSON(200, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	} else {
+		c.JSON(200, gin.H{
+			"code":    200,
+			"message": "success",
+			"meta": gin.H{
+				"since":        filter.SinceUTC,
+				"until":        filter.UntilUTC,
+				"path":         filter.Path,
+				"depth":        filter.Depth,
+				"threshold":    busFactor.Threshold,
+				"inactiveDays": busFactor.InactiveDays,
+			},
+			"data": risks,
+		})
+	}
+}
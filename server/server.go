@@ -36,11 +36,16 @@ func GetConfig() *AppConfig {
 	return gConfig
 }
 
+// SetConfig sets the config the handlers read.
+func SetConfig(config *AppConfig) {
+	gConfig = config
+}
+
 func Run(config *AppConfig) error {
 
 	log.Printf("load config: %v\n", config)
 
-	gConfig = config
+	SetConfig(config)
 	insight := config.Insight
 	server := config.Server
 
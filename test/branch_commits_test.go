@@ -0,0 +1,186 @@
+package gitinsight_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun/driver/sqliteshim"
+)
+
+func TestBranchesShareCommits(t *testing.T) {
+	openTestDb(t)
+	config := testConfig()
+	fixture := newFixtureRepo(t)
+
+	fixture.Commit("alice", "feat: init", map[string]string{"main.go": "package main\n"})
+	feature := fixture.Commit("bob", "feat: feature", map[string]string{"feature.go": "package main\n\nvar x = 1\n"})
+	fixture.Branch("feature", feature)
+	fixture.Commit("alice", "fix: main", map[string]string{"main.go": "package main\n\nfunc main() {}\n"})
+
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "master"))
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "feature"))
+
+	total, err := gitinsight.CountCommitLogs(&gitinsight.CommitLogFilter{})
+	require.NoError(t, err)
+	require.Equal(t, 3, total)
+
+	featureTotal, err := gitinsight.CountCommitLogs(&gitinsight.CommitLogFilter{BranchName: "feature"})
+	require.NoError(t, err)
+	require.Equal(t, 2, featureTotal)
+
+	branches, err := gitinsight.GetRepoBranches(&gitinsight.CommitLogFilter{})
+	require.NoError(t, err)
+	commits := map[string]int{}
+	for _, b := range branches {
+		commits[b.BranchName] = b.Commits
+	}
+	require.Equal(t, map[string]int{"master": 3, "feature": 2}, commits)
+
+	authors, err := gitinsight.GetAuthors(&gitinsight.CommitLogFilter{BranchName: "master,feature"})
+	require.NoError(t, err)
+	authorCommits := map[string]int{}
+	for _, a := range authors {
+		authorCommits[a.Nickname] = a.Commits
+	}
+	require.Equal(t, map[string]int{"alice": 2, "bob": 1}, authorCommits)
+
+	logs, err := gitinsight.GetCommitLogs(&gitinsight.CommitLogFilter{BranchName: "feature", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+	require.Equal(t, "feature", logs[0].BranchName)
+}
+
+func TestDeletedBranches(t *testing.T) {
+	openTestDb(t)
+	fixture := newFixtureRepo(t)
+	initial := fixture.Commit("alice", "feat: init", map[string]string{"main.go": "package main\n"})
+	topic := fixture.Commit("bob", "feat: topic", map[string]string{"topic.go": "package main\n"})
+	fixture.Branch("topic", topic)
+	fixture.Reset(initial)
+	fixture.Commit("alice", "fix: main", map[string]string{"main.go": "package main\n\nfunc main() {}\n"})
+
+	config := testConfig()
+	config.Cache.Path = filepath.Join(t.TempDir(), "repos")
+	config.Repos = []gitinsight.Repo{{Url: fixture.Path}}
+	gitinsight.HandleCommitLogs(config)
+	branches, err := gitinsight.GetRepoBranches(&gitinsight.CommitLogFilter{})
+	require.NoError(t, err)
+	require.Len(t, branches, 2)
+	state, err := gitinsight.GetBranchState(fixture.Path, "topic")
+	require.NoError(t, err)
+	require.NotNil(t, state)
+
+	// the branch and the commits only it reached are removed on the next sync
+	require.NoError(t, fixture.Repo.Storer.RemoveReference(plumbing.NewBranchReferenceName("topic")))
+	gitinsight.HandleCommitLogs(config)
+	branches, err = gitinsight.GetRepoBranches(&gitinsight.CommitLogFilter{})
+	require.NoError(t, err)
+	require.Len(t, branches, 1)
+	require.Equal(t, "master", branches[0].BranchName)
+	total, err := gitinsight.CountCommitLogs(&gitinsight.CommitLogFilter{})
+	require.NoError(t, err)
+	require.Equal(t, 2, total)
+	state, err = gitinsight.GetBranchState(fixture.Path, "topic")
+	require.NoError(t, err)
+	require.Nil(t, state)
+	state, err = gitinsight.GetBranchState(fixture.Path, "master")
+	require.NoError(t, err)
+	require.NotNil(t, state)
+}
+
+func TestOrphanCommitsAfterRewrite(t *testing.T) {
+	openTestDb(t)
+	fixture := newFixtureRepo(t)
+	initial := fixture.Commit("alice", "feat: init", map[string]string{"main.go": "package main\n"})
+	fixture.Branch("topic", initial)
+	dropped := fixture.Commit("bob", "feat: dropped", map[string]string{"dropped.go": "package main\n"})
+
+	config := testConfig()
+	config.Parallel = true
+	config.Cache.Path = filepath.Join(t.TempDir(), "repos")
+	config.Repos = []gitinsight.Repo{{Url: fixture.Path}}
+	gitinsight.HandleCommitLogs(config)
+	total, err := gitinsight.CountCommitLogs(&gitinsight.CommitLogFilter{})
+	require.NoError(t, err)
+	require.Equal(t, 2, total)
+
+	// the rebuild of the rewritten branch keeps the commit it no longer
+	// reaches, another branch analyzed at the same time may link it
+	fixture.Reset(initial)
+	fixture.Commit("carol", "feat: rewritten", map[string]string{"main.go": "package main\n\nfunc main() {}\n"})
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "master"))
+	hashes, err := gitinsight.GetCommitHashes(&gitinsight.CommitLogFilter{IsMerge: "0,1"})
+	require.NoError(t, err)
+	require.True(t, hashes[dropped.String()])
+
+	deleted, err := gitinsight.DeleteOrphanCommits(fixture.Path)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+	hashes, err = gitinsight.GetCommitHashes(&gitinsight.CommitLogFilter{IsMerge: "0,1"})
+	require.NoError(t, err)
+	require.Len(t, hashes, 2)
+	require.False(t, hashes[dropped.String()])
+	require.True(t, hashes[initial.String()])
+}
+
+func TestMigrateLegacyCommitLog(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "gitinsight.db")
+	legacy, err := sql.Open(sqliteshim.ShimName, dsn)
+	require.NoError(t, err)
+	_, err = legacy.Exec(`CREATE TABLE commit_log (id INTEGER PRIMARY KEY AUTOINCREMENT, repo_url VARCHAR NOT NULL,
+		branch_name VARCHAR NOT NULL, commit_hash VARCHAR NOT NULL, is_merge BOOLEAN NOT NULL, message TEXT NOT NULL,
+		message_type VARCHAR NOT NULL, date TIMESTAMP NOT NULL, committer_date TIMESTAMP NOT NULL, additions INTEGER NOT NULL,
+		deletions INTEGER NOT NULL, effectives INTEGER NOT NULL, language_stats TEXT NOT NULL, author_name VARCHAR NOT NULL,
+		author_email VARCHAR NOT NULL, nickname VARCHAR NOT NULL)`)
+	require.NoError(t, err)
+	// the shared commit is stored once per branch
+	for _, row := range [][]any{
+		{"master", "a1b2c3d4e5", "feat(api): add endpoint", 10},
+		{"feature", "a1b2c3d4e5", "feat(api): add endpoint", 10},
+		{"feature", "f6e5d4c3b2", "Revert \"feat(api): add endpoint\"\n\nThis reverts commit a1b2c3d4e5.", 0},
+	} {
+		_, err = legacy.Exec(`INSERT INTO commit_log (repo_url, branch_name, commit_hash, is_merge, message, message_type,
+			date, committer_date, additions, deletions, effectives, language_stats, author_name, author_email, nickname)
+			VALUES ('repo', ?, ?, 0, ?, '', '2025-10-01 08:00:00', '2025-10-01 08:00:00', ?, 0, ?, '{}', 'alice', 'alice@example.com', 'alice')`,
+			row[0], row[1], row[2], row[3], row[3])
+		require.NoError(t, err)
+	}
+	require.NoError(t, legacy.Close())
+
+	require.NoError(t, gitinsight.OpenDb("sqliteshim", dsn))
+	t.Cleanup(func() {
+		gitinsight.CloseDb()
+	})
+	require.NoError(t, gitinsight.InitDb())
+
+	commitLogs, err := gitinsight.GetCommitLogs(&gitinsight.CommitLogFilter{RepoUrl: "repo", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, commitLogs, 2)
+	byHash := make(map[string]gitinsight.CommitLogModel)
+	for _, commitLog := range commitLogs {
+		byHash[commitLog.CommitHash] = commitLog
+	}
+	require.Equal(t, "feat", byHash["a1b2c3d4e5"].MessageType)
+	require.Equal(t, "api", byHash["a1b2c3d4e5"].Scope)
+	require.Equal(t, 10, byHash["a1b2c3d4e5"].Additions)
+	require.True(t, byHash["f6e5d4c3b2"].IsRevert)
+	require.Equal(t, "a1b2c3d4e5", byHash["f6e5d4c3b2"].RevertsHash)
+
+	branches, err := gitinsight.GetRepoBranches(&gitinsight.CommitLogFilter{RepoUrl: "repo"})
+	require.NoError(t, err)
+	commits := make(map[string]int)
+	for _, branch := range branches {
+		commits[branch.BranchName] = branch.Commits
+	}
+	require.Equal(t, map[string]int{"feature": 2, "master": 1}, commits)
+
+	// the legacy table is gone, opening again does not migrate twice
+	require.NoError(t, gitinsight.InitDb())
+	commitLogs, err = gitinsight.GetCommitLogs(&gitinsight.CommitLogFilter{RepoUrl: "repo", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, commitLogs, 2)
+}
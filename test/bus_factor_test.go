@@ -0,0 +1,63 @@
+package gitinsight_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBusFactor(t *testing.T) {
+	openTestDb(t)
+	config := testConfig()
+	fixture := newFixtureRepo(t)
+
+	fixture.Commit("alice", "feat: api", map[string]string{"api/a.go": "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"})
+	fixture.Commit("bob", "fix: api", map[string]string{"api/b.go": "1\n"})
+	fixture.Commit("carol", "feat: web", map[string]string{"web/a.ts": "1\n2\n3\n4\n"})
+	fixture.Commit("dave", "feat: web", map[string]string{"web/b.ts": "1\n2\n3\n"})
+	fixture.Commit("erin", "feat: web", map[string]string{"web/c.ts": "1\n2\n3\n"})
+	// alice goes inactive
+	fixture.When = fixture.When.Add(120 * 24 * time.Hour)
+	fixture.Commit("carol", "fix: web", map[string]string{"web/d.ts": "1\n"})
+	fixture.Commit("dave", "fix: web", map[string]string{"web/d.ts": "2\n"})
+	fixture.Commit("erin", "fix: web", map[string]string{"web/d.ts": "3\n"})
+
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "master"))
+
+	filter := &gitinsight.CommitLogFilter{UntilTime: fixture.When.Add(time.Hour)}
+	risks, err := gitinsight.GetBusFactor(filter, &gitinsight.BusFactor{Threshold: 0.8, InactiveDays: 90})
+	require.NoError(t, err)
+	byPath := map[string]gitinsight.BusFactorDTO{}
+	for _, r := range risks {
+		byPath[r.Path] = r
+	}
+	require.Len(t, byPath, 2)
+
+	api := byPath["api"]
+	require.Equal(t, "api", risks[0].Path)
+	require.Equal(t, 1, api.BusFactor)
+	require.Equal(t, 2, api.Authors)
+	require.Equal(t, 11, api.Changes)
+	require.True(t, api.SingleOwner)
+	require.True(t, api.AtRisk)
+	require.Equal(t, 100.0, api.InactiveShare)
+	require.Equal(t, "alice", api.KeyAuthors[0].Nickname)
+	require.True(t, api.KeyAuthors[0].Inactive)
+
+	web := byPath["web"]
+	require.Equal(t, 3, web.BusFactor)
+	require.False(t, web.AtRisk)
+	require.Equal(t, 0.0, web.InactiveShare)
+	require.Equal(t, 15, web.Changes)
+	require.Equal(t, "carol", web.KeyAuthors[0].Nickname)
+	require.Equal(t, 33.33, web.KeyAuthors[0].Share)
+
+	// a lower threshold needs fewer key authors
+	risks, err = gitinsight.GetBusFactor(&gitinsight.CommitLogFilter{Path: "web/", UntilTime: fixture.When.Add(time.Hour)}, &gitinsight.BusFactor{Threshold: 0.6})
+	require.NoError(t, err)
+	require.Len(t, risks, 1)
+	require.Equal(t, "web", risks[0].Path)
+	require.Equal(t, 2, risks[0].BusFactor)
+}
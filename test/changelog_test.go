@@ -0,0 +1,50 @@
+package gitinsight_test
+
+import (
+	"testing"
+
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateChangelog(t *testing.T) {
+	f := newFixtureRepo(t)
+	first := f.Commit("alice", "feat: initial", map[string]string{"main.go": "package main\n"})
+	_, err := f.Repo.CreateTag("v1.0.0", first, nil)
+	require.NoError(t, err)
+
+	f.Commit("alice", "feat(api)!: drop v1\n\nBREAKING CHANGE: v1 clients must move to v2", map[string]string{"api.go": "package main\n"})
+	f.Commit("bob", "fix(ui): color\n\nCo-authored-by: alice <alice@example.com>", map[string]string{"ui.go": "package main\n"})
+	f.Commit("bob", "fix(api): status code", map[string]string{"api.go": "package main\n\n"})
+	f.Commit("alice", "random", map[string]string{"README.md": "# x\n"})
+
+	config := &gitinsight.Config{}
+	changelog, err := gitinsight.GenerateChangelog(config, f.Path, "https://example.com/fixture.git", "v1.0.0", "")
+	require.NoError(t, err)
+	require.Equal(t, "HEAD", changelog.To)
+
+	require.Len(t, changelog.Breaking, 1)
+	require.Equal(t, "drop v1", changelog.Breaking[0].Subject)
+	require.Equal(t, "v1 clients must move to v2", changelog.Breaking[0].BreakingNote)
+
+	types := make([]string, 0)
+	for _, section := range changelog.Sections {
+		types = append(types, section.Type)
+	}
+	require.Equal(t, []string{"feat", "fix", ""}, types)
+	fixes := changelog.Sections[1]
+	require.Equal(t, "Bug Fixes", fixes.Title)
+	require.Len(t, fixes.Scopes, 2)
+	require.Equal(t, "api", fixes.Scopes[0].Scope)
+	require.Equal(t, "ui", fixes.Scopes[1].Scope)
+
+	require.Equal(t, []gitinsight.ChangelogContributor{
+		{Nickname: "alice", Commits: 3},
+		{Nickname: "bob", Commits: 2},
+	}, changelog.Contributors)
+
+	markdown := gitinsight.RenderChangelogMarkdown(changelog)
+	require.Contains(t, markdown, "### BREAKING CHANGES")
+	require.Contains(t, markdown, "- **ui:** color (")
+	require.NotContains(t, markdown, "initial")
+}
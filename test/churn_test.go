@@ -0,0 +1,66 @@
+package gitinsight_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChurn(t *testing.T) {
+	openTestDb(t)
+	config := testConfig()
+	config.Churn = gitinsight.Churn{Enabled: true, Days: 21}
+	fixture := newFixtureRepo(t)
+
+	lines := []string{"l1", "l2", "l3", "l4", "l5", "l6", "l7", "l8", "l9", "l10"}
+	content := func() string { return strings.Join(lines, "\n") + "\n" }
+	fixture.Commit("alice", "feat: add", map[string]string{"a.txt": content()})
+	lines[0], lines[1], lines[2] = "b1", "b2", "b3"
+	fixture.Commit("bob", "fix: rewrite", map[string]string{"a.txt": content()})
+	lines = append(lines[:3], lines[5:]...)
+	fixture.Commit("alice", "fix: drop", map[string]string{"a.txt": content()})
+	// out of the window
+	fixture.When = fixture.When.Add(30 * 24 * time.Hour)
+	lines[0], lines[3] = "c1", "c6"
+	fixture.Commit("carol", "fix: late", map[string]string{"a.txt": content()})
+
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "master"))
+
+	ranking, err := gitinsight.GetRanking(&gitinsight.CommitLogFilter{})
+	require.NoError(t, err)
+	churn := map[string][4]float64{}
+	for _, r := range ranking {
+		churn[r.Nickname] = [4]float64{float64(r.Churned), float64(r.Reworked), r.ChurnRate, r.ReworkRate}
+	}
+	require.Equal(t, [4]float64{5, 2, 50, 20}, churn["alice"])
+	require.Equal(t, [4]float64{0, 0, 0, 0}, churn["bob"])
+
+	authors, err := gitinsight.GetAuthors(&gitinsight.CommitLogFilter{Attribution: gitinsight.AttributionSplit})
+	require.NoError(t, err)
+	for _, a := range authors {
+		if a.Nickname == "alice" {
+			require.Equal(t, 5, a.Churned)
+			require.Equal(t, 2, a.Reworked)
+		}
+	}
+}
+
+func TestChurnDisabled(t *testing.T) {
+	fixture := newFixtureRepo(t)
+	fixture.Commit("alice", "feat: add", map[string]string{"a.txt": "a\nb\n"})
+	hash := fixture.Commit("bob", "fix: rewrite", map[string]string{"a.txt": "c\nb\n"})
+	commit, err := fixture.Repo.CommitObject(hash)
+	require.NoError(t, err)
+
+	churn, err := gitinsight.GetCommitChurn(commit, 0, nil)
+	require.NoError(t, err)
+	require.Empty(t, churn)
+
+	churn, err = gitinsight.GetCommitChurn(commit, time.Hour*24, nil)
+	require.NoError(t, err)
+	require.Len(t, churn, 1)
+	require.Equal(t, 1, churn[0].Lines)
+}
@@ -0,0 +1,60 @@
+package gitinsight_test
+
+import (
+	"testing"
+
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCoAuthors(t *testing.T) {
+	coAuthors := gitinsight.ParseCoAuthors(`feat: pair on billing
+
+Body text mentioning Co-authored-by in prose.
+
+Co-authored-by: Bob <bob@example.com>
+co-authored-by: Carol Doe <carol@example.com>
+Co-authored-by: Bob Again <BOB@example.com>
+Co-authored-by: nobody
+`)
+	require.Equal(t, []gitinsight.CoAuthor{
+		{Name: "Bob", Email: "bob@example.com"},
+		{Name: "Carol Doe", Email: "carol@example.com"},
+	}, coAuthors)
+}
+
+func TestCoAuthorAttribution(t *testing.T) {
+	openTestDb(t)
+	config := testConfig()
+	fixture := newFixtureRepo(t)
+
+	fixture.Commit("alice", "feat: pair\n\nCo-authored-by: bob <bob@example.com>\nCo-authored-by: alice <alice@example.com>", map[string]string{
+		"a.go": "1\n2\n3\n4\n",
+	})
+	fixture.Commit("bob", "feat: solo", map[string]string{
+		"b.go": "1\n2\n",
+	})
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "master"))
+
+	credits := func(attribution string) map[string][2]int {
+		ranking, err := gitinsight.GetRanking(&gitinsight.CommitLogFilter{Attribution: attribution})
+		require.NoError(t, err)
+		result := map[string][2]int{}
+		for _, r := range ranking {
+			result[r.Nickname] = [2]int{r.Commits, r.Additions}
+		}
+		return result
+	}
+	require.Equal(t, map[string][2]int{"alice": {1, 4}, "bob": {1, 2}}, credits(gitinsight.AttributionPrimary))
+	require.Equal(t, map[string][2]int{"alice": {1, 4}, "bob": {2, 6}}, credits(gitinsight.AttributionFull))
+	require.Equal(t, map[string][2]int{"alice": {1, 2}, "bob": {2, 4}}, credits(gitinsight.AttributionSplit))
+
+	authors, err := gitinsight.GetAuthors(&gitinsight.CommitLogFilter{Attribution: gitinsight.AttributionSplit, Nickname: "bob"})
+	require.NoError(t, err)
+	require.Len(t, authors, 1)
+	require.Equal(t, 4, authors[0].Additions)
+	require.Equal(t, 2, authors[0].Commits)
+
+	_, err = gitinsight.GetRanking(&gitinsight.CommitLogFilter{Attribution: "everyone"})
+	require.Error(t, err)
+}
@@ -0,0 +1,113 @@
+package gitinsight_test
+
+import (
+	"testing"
+
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConventionalCommit(t *testing.T) {
+	commit := gitinsight.ParseConventionalCommit(`feat(api)!: drop v1
+
+The v1 endpoints were deprecated a year ago.
+
+BREAKING CHANGE: v1 clients must move to v2,
+see the migration guide.
+Refs: #123
+Closes #45`, nil)
+	require.Equal(t, "feat", commit.Type)
+	require.Equal(t, "api", commit.Scope)
+	require.True(t, commit.Breaking)
+	require.Equal(t, "drop v1", commit.Subject)
+	require.Equal(t, []gitinsight.CommitFooter{
+		{Token: "BREAKING CHANGE", Value: "v1 clients must move to v2,\nsee the migration guide."},
+		{Token: "Refs", Value: "#123"},
+		{Token: "Closes", Value: "#45"},
+	}, commit.Footers)
+
+	cases := []struct {
+		message  string
+		typ      string
+		scope    string
+		breaking bool
+		subject  string
+	}{
+		{"Fix: handle nil", "fix", "", false, "handle nil"},
+		{"fix（ui）：中文冒号", "", "", false, "fix（ui）:中文冒号"},
+		{"docs：readme", "docs", "", false, "readme"},
+		{"Merge: foo", "", "", false, "Merge: foo"},
+		{"Note: remember this", "", "", false, "Note: remember this"},
+		{"Merge branch 'main' into dev", "", "", false, "Merge branch 'main' into dev"},
+		{"refactor(db): split\n\nBREAKING-CHANGE: schema", "refactor", "db", true, "split"},
+	}
+	for _, c := range cases {
+		commit := gitinsight.ParseConventionalCommit(c.message, nil)
+		require.Equal(t, c.typ, commit.Type, c.message)
+		require.Equal(t, c.scope, commit.Scope, c.message)
+		require.Equal(t, c.breaking, commit.Breaking, c.message)
+		require.Equal(t, c.subject, commit.Subject, c.message)
+	}
+
+	commit = gitinsight.ParseConventionalCommit("wip(x): try", []string{"wip", "feat"})
+	require.Equal(t, "wip", commit.Type)
+	require.Equal(t, "x", commit.Scope)
+	require.Empty(t, gitinsight.ParseConventionalCommit("chore: x", []string{"wip", "feat"}).Type)
+}
+
+func TestConventionalCommitFilters(t *testing.T) {
+	openTestDb(t)
+	config := testConfig()
+	fixture := newFixtureRepo(t)
+
+	fixture.Commit("alice", "feat(api)!: drop v1", map[string]string{"a.go": "a\n"})
+	fixture.Commit("alice", "fix(api): nil check\n\nRefs: #7", map[string]string{"a.go": "b\n"})
+	fixture.Commit("bob", "fix(ui): color", map[string]string{"b.go": "b\n"})
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "master"))
+
+	commitLogs, err := gitinsight.GetCommitLogs(&gitinsight.CommitLogFilter{Scope: "api", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, commitLogs, 2)
+	require.Equal(t, "nil check", commitLogs[0].Subject)
+	require.Equal(t, []gitinsight.CommitFooter{{Token: "Refs", Value: "#7"}}, gitinsight.UnmarshalCommitFooters(commitLogs[0].Footers))
+
+	commitLogs, err = gitinsight.GetCommitLogs(&gitinsight.CommitLogFilter{Breaking: "1", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, commitLogs, 1)
+	require.Equal(t, "feat", commitLogs[0].MessageType)
+
+	count, err := gitinsight.CountCommitLogs(&gitinsight.CommitLogFilter{Breaking: "false", MessageType: "fix"})
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+}
+
+func TestRefreshCommitMessages(t *testing.T) {
+	openTestDb(t)
+	config := testConfig()
+	fixture := newFixtureRepo(t)
+
+	fixture.Commit("alice", "wip(api): try", map[string]string{"a.go": "a\n"})
+	fixture.Commit("alice", "feat(api): add", map[string]string{"a.go": "b\n"})
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "master"))
+
+	count, err := gitinsight.CountCommitLogs(&gitinsight.CommitLogFilter{MessageType: "wip"})
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+
+	// the stored commits are up to date, only the configured types changed
+	config.CommitTypes = []string{"wip"}
+	require.NoError(t, gitinsight.RefreshRepoCommitMessages(config, fixture.Path))
+
+	commitLogs, err := gitinsight.GetCommitLogs(&gitinsight.CommitLogFilter{MessageType: "wip", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, commitLogs, 1)
+	require.Equal(t, "api", commitLogs[0].Scope)
+	require.Equal(t, "try", commitLogs[0].Subject)
+	count, err = gitinsight.CountCommitLogs(&gitinsight.CommitLogFilter{MessageType: "feat"})
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+
+	updated, err := gitinsight.RefreshCommitMessages(gitinsight.GetRepoUrl(config, fixture.Path), config.CommitTypes)
+	require.NoError(t, err)
+	require.Zero(t, updated)
+}
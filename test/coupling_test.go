@@ -0,0 +1,49 @@
+package gitinsight_test
+
+import (
+	"testing"
+
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoupling(t *testing.T) {
+	openTestDb(t)
+	config := testConfig()
+	fixture := newFixtureRepo(t)
+
+	fixture.Commit("alice", "feat: init", map[string]string{
+		"api/user.go":   "v1\n",
+		"web/user.ts":   "v1\n",
+		"docs/guide.md": "v1\n",
+	})
+	fixture.Commit("alice", "feat: name", map[string]string{"api/user.go": "v2\n", "web/user.ts": "v2\n"})
+	fixture.Commit("bob", "feat: mail", map[string]string{"api/user.go": "v3\n", "web/user.ts": "v3\n"})
+	fixture.Commit("bob", "fix: api", map[string]string{"api/user.go": "v4\n"})
+	fixture.Commit("carol", "docs: guide", map[string]string{"docs/guide.md": "v2\n", "api/user.go": "v5\n"})
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "master"))
+
+	pairs, total, err := gitinsight.GetCoupling(&gitinsight.CommitLogFilter{Limit: 10}, &gitinsight.Coupling{MinSupport: 2, MinConfidence: 50})
+	require.NoError(t, err)
+	require.Equal(t, 2, total)
+	require.Equal(t, "api/user.go", pairs[0].Path)
+	require.Equal(t, "web/user.ts", pairs[0].Coupled)
+	require.Equal(t, 5, pairs[0].Commits)
+	require.Equal(t, 3, pairs[0].CoupledCommits)
+	require.Equal(t, 3, pairs[0].CoChanges)
+	require.Equal(t, 100.0, pairs[0].Confidence)
+	require.Equal(t, 75.0, pairs[0].Degree)
+	require.Equal(t, "docs/guide.md", pairs[1].Coupled)
+	require.Equal(t, 2, pairs[1].CoChanges)
+
+	// oversized commits are noise
+	_, total, err = gitinsight.GetCoupling(&gitinsight.CommitLogFilter{Limit: 10}, &gitinsight.Coupling{MinSupport: 3, MaxFiles: 2})
+	require.NoError(t, err)
+	require.Equal(t, 0, total)
+
+	pairs, _, err = gitinsight.GetCoupling(&gitinsight.CommitLogFilter{GroupBy: "directory", Limit: 10}, &gitinsight.Coupling{MinSupport: 3, MinConfidence: 90})
+	require.NoError(t, err)
+	require.Len(t, pairs, 1)
+	require.Equal(t, "api", pairs[0].Path)
+	require.Equal(t, "web", pairs[0].Coupled)
+}
@@ -1,42 +1,34 @@
 package gitinsight_test
 
 import (
-	"os"
-	"path/filepath"
 	"testing"
 	"time"
 
-	"github.com/goccy/go-yaml"
 	"github.com/robotism/gitinsight/gitinsight"
-	"github.com/robotism/gitinsight/server"
 	"github.com/stretchr/testify/require"
 )
 
 func TestGetRepoBranches(t *testing.T) {
+	openTestDb(t)
+	fixture := newFixtureRepo(t)
+	initial := fixture.Commit("alice", "feat: init", map[string]string{"main.go": "package main\n"})
+	fixture.Commit("bob", "fix: typo", map[string]string{"main.go": "package main\n\nfunc main() {}\n"})
+	fixture.Branch("feature", initial)
 
-	workDir := "../cmd/gitinsight"
-	configFile := filepath.Join(workDir, "config.yaml")
+	config := testConfig()
+	config.Parallel = false
+	config.Repos = []gitinsight.Repo{{Url: fixture.Path}}
+	gitinsight.HandleCommitLogs(config)
 
-	configContent, err := os.ReadFile(configFile)
-	require.NoError(t, err, "read config failed")
-
-	var config server.AppConfig
-	require.NoError(t, yaml.Unmarshal(configContent, &config), "unmarshal failed")
-
-	require.NoError(t, gitinsight.OpenDb(config.Server.Database.Type, config.Server.Database.Dsn))
-	require.NoError(t, gitinsight.InitDb())
-
-	since, _ := time.Parse("2006-01-02 15:04:05", "2025-09-01 00:00:00")
-	until, _ := time.Parse("2006-01-02 15:04:05", "2025-09-30 23:59:59")
 	branches, err := gitinsight.GetRepoBranches(&gitinsight.CommitLogFilter{
-		SinceTime: since,
-		UntilTime: until,
+		SinceTime: config.SinceTime(),
+		UntilTime: fixture.When.Add(time.Hour),
 	})
-	if err != nil {
-		t.Fatal(err)
-	}
-	if len(branches) == 0 {
-		t.Fatal("no branches")
+	require.NoError(t, err)
+	commits := make(map[string]int)
+	for _, branch := range branches {
+		require.Equal(t, fixture.Path, branch.RepoUrl)
+		commits[branch.BranchName] = branch.Commits
 	}
-	t.Log(branches)
+	require.Equal(t, map[string]int{"feature": 1, "master": 2}, commits)
 }
@@ -0,0 +1,60 @@
+package gitinsight_test
+
+import (
+	"testing"
+
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDora(t *testing.T) {
+	openTestDb(t)
+	config := testConfig()
+	fixture := newFixtureRepo(t)
+
+	initial := fixture.Commit("alice", "feat: init", map[string]string{"main.go": "package main\n"})
+	_, err := fixture.Repo.CreateTag("v1.0.0", initial, nil)
+	require.NoError(t, err)
+
+	fixture.Commit("bob", "feat: api", map[string]string{"api.go": "package main\n"})
+	feature := fixture.Commit("bob", "test: api", map[string]string{"api_test.go": "package main\n"})
+	fixture.Reset(initial)
+	fixture.Commit("alice", "docs: readme", map[string]string{"README.md": "# fixture\n"})
+	merge := fixture.Merge("alice", "Merge branch 'feature'", map[string]string{
+		"api.go":      "package main\n",
+		"api_test.go": "package main\n",
+	}, feature)
+	_, err = fixture.Repo.CreateTag("rc1", merge, nil)
+	require.NoError(t, err)
+	release := fixture.Commit("alice", "fix: typo", map[string]string{"main.go": "package main\n\n"})
+	_, err = fixture.Repo.CreateTag("v1.1.0", release, nil)
+	require.NoError(t, err)
+	hotfix := fixture.Commit("carol", "fix(hotfix): restore api", map[string]string{"api.go": "package main\n\n"})
+	_, err = fixture.Repo.CreateTag("v1.1.1", hotfix, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "master"))
+	require.NoError(t, gitinsight.HandleRepoTagsToDb(config, fixture.Path))
+
+	dora := &gitinsight.Dora{DeployTags: []string{"v*"}}
+	items, err := gitinsight.GetDora(&gitinsight.CommitLogFilter{}, dora)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	item := items[0]
+	require.Equal(t, "2025-10", item.Period)
+	require.Equal(t, 3, item.Deployments)
+	// init, the merged feature, the readme, the typo and the hotfix
+	require.Equal(t, 5, item.Changes)
+	// the feature shipped 4h after its first commit, the readme 2h after
+	require.Equal(t, 0.0, item.LeadTimeHours)
+	require.Equal(t, 1.2, item.MeanLeadTimeHours)
+	require.Equal(t, 1, item.FailedDeployments)
+	require.Equal(t, 0.3333, item.ChangeFailureRate)
+
+	// every tag is a deployment without patterns
+	items, err = gitinsight.GetDora(&gitinsight.CommitLogFilter{Period: "day"}, &gitinsight.Dora{})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	require.Equal(t, "2025-10-01", items[0].Period)
+	require.Equal(t, 4, items[0].Deployments)
+}
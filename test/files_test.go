@@ -0,0 +1,105 @@
+package gitinsight_test
+
+import (
+	"testing"
+
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileAndDirectoryStats(t *testing.T) {
+	openTestDb(t)
+	config := testConfig()
+	fixture := newFixtureRepo(t)
+
+	fixture.Commit("alice", "feat: init", map[string]string{
+		"README.md":              "# fixture\n",
+		"pkg/billing/invoice.go": "package billing\n",
+		"pkg/api/handler.go":     "package api\n",
+		"assets/logo.png":        "\x89PNG\x00\x00binary",
+	})
+	fixture.Commit("bob", "feat: invoice", map[string]string{
+		"pkg/billing/invoice.go": "package billing\n\nfunc Total() int { return 1 }\n",
+		"pkg/billing/tax.go":     "package billing\n",
+	})
+	fixture.Commit("alice", "chore: drop handler", map[string]string{
+		"pkg/api/handler.go": "",
+	})
+	fixture.Commit("carol", "feat: sibling", map[string]string{
+		"pkg/billingx/export.go": "package billingx\n",
+		"pkg/billing_v2/fee.go":  "package billing_v2\n",
+	})
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "master"))
+
+	changes, err := gitinsight.GetFileChanges(&gitinsight.CommitLogFilter{})
+	require.NoError(t, err)
+	changeTypes := map[string]bool{}
+	for _, change := range changes {
+		if change.Path == "assets/logo.png" {
+			require.True(t, change.IsBinary)
+			require.Zero(t, change.Additions)
+		}
+		if change.Path == "pkg/api/handler.go" && change.ChangeType == gitinsight.ChangeTypeDelete {
+			require.Equal(t, 1, change.Deletions)
+		}
+		changeTypes[change.Path+" "+change.ChangeType] = true
+	}
+	require.True(t, changeTypes["pkg/billing/invoice.go "+gitinsight.ChangeTypeModify])
+	require.True(t, changeTypes["pkg/billing/tax.go "+gitinsight.ChangeTypeAdd])
+	require.True(t, changeTypes["pkg/api/handler.go "+gitinsight.ChangeTypeDelete])
+
+	files, total, err := gitinsight.GetFileStats(&gitinsight.CommitLogFilter{Path: "pkg/billing/", Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, 2, total)
+	require.Equal(t, "pkg/billing/invoice.go", files[0].Path)
+	require.Equal(t, 2, files[0].Commits)
+	require.Equal(t, 2, files[0].Authors)
+	require.Equal(t, 3, files[0].Additions)
+
+	// a path prefix matches the path and the paths under it, not its siblings
+	for _, path := range []string{"pkg/billing", "pkg/billing/"} {
+		files, total, err = gitinsight.GetFileStats(&gitinsight.CommitLogFilter{Path: path, Limit: 10})
+		require.NoError(t, err)
+		require.Equal(t, 2, total, path)
+	}
+	files, total, err = gitinsight.GetFileStats(&gitinsight.CommitLogFilter{Path: "pkg/billing_", Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, 0, total)
+	files, total, err = gitinsight.GetFileStats(&gitinsight.CommitLogFilter{Path: "pkg/billing/tax.go", Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, 1, total)
+	require.Equal(t, "pkg/billing/tax.go", files[0].Path)
+
+	directories, total, err := gitinsight.GetDirectoryStats(&gitinsight.CommitLogFilter{Path: "pkg/", Depth: 1})
+	require.NoError(t, err)
+	require.Equal(t, 4, total)
+	byPath := map[string]gitinsight.DirectoryStatDTO{}
+	for _, d := range directories {
+		byPath[d.Path] = d
+	}
+	require.Len(t, byPath, 4)
+	require.Equal(t, 2, byPath["pkg/billing"].Files)
+	require.Equal(t, 2, byPath["pkg/billing"].Commits)
+	require.Equal(t, 2, byPath["pkg/api"].Commits)
+
+	// pages are cut by the database, the total counts every row
+	files, total, err = gitinsight.GetFileStats(&gitinsight.CommitLogFilter{Path: "pkg/", Offset: 1, Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, 5, total)
+	require.Len(t, files, 2)
+	directories, total, err = gitinsight.GetDirectoryStats(&gitinsight.CommitLogFilter{Depth: 2, Offset: 3, Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, 6, total)
+	require.Len(t, directories, 3)
+	directories, _, err = gitinsight.GetDirectoryStats(&gitinsight.CommitLogFilter{Depth: 2})
+	require.NoError(t, err)
+	paths := []string{}
+	for _, d := range directories {
+		paths = append(paths, d.Path)
+	}
+	require.ElementsMatch(t, []string{".", "assets", "pkg/billing", "pkg/api", "pkg/billingx", "pkg/billing_v2"}, paths)
+
+	require.Equal(t, ".", gitinsight.DirectoryOf("", "README.md", 1))
+	require.Equal(t, "pkg", gitinsight.DirectoryOf("", "pkg/billing/invoice.go", 1))
+	require.Equal(t, "pkg/billing", gitinsight.DirectoryOf("", "pkg/billing/invoice.go", 2))
+}
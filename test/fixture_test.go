@@ -0,0 +1,107 @@
+package gitinsight_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/stretchr/testify/require"
+)
+
+// fixtureRepo is a throwaway repository with a worktree for building test
+// histories commit by commit.
+type fixtureRepo struct {
+	t    *testing.T
+	Path string
+	Repo *git.Repository
+	When time.Time
+}
+
+func newFixtureRepo(t *testing.T) *fixtureRepo {
+	path := filepath.Join(t.TempDir(), "fixture")
+	repo, err := git.PlainInit(path, false)
+	require.NoError(t, err)
+	return &fixtureRepo{
+		t:    t,
+		Path: path,
+		Repo: repo,
+		When: time.Date(2025, 10, 1, 8, 0, 0, 0, time.UTC),
+	}
+}
+
+// Commit writes files (an empty content removes the file) and commits them
+// as the given author, one hour after the previous fixture commit.
+func (f *fixtureRepo) Commit(author string, message string, files map[string]string) plumbing.Hash {
+	return f.CommitAs(author, author+"@example.com", message, files)
+}
+
+// CommitAs is Commit with an explicit author email.
+func (f *fixtureRepo) CommitAs(author string, email string, message string, files map[string]string) plumbing.Hash {
+	return f.commit(author, email, message, files, nil)
+}
+
+// Merge commits files as a merge of other into the current branch.
+func (f *fixtureRepo) Merge(author string, message string, files map[string]string, other plumbing.Hash) plumbing.Hash {
+	head, err := f.Repo.Head()
+	require.NoError(f.t, err)
+	return f.commit(author, author+"@example.com", message, files, []plumbing.Hash{head.Hash(), other})
+}
+
+func (f *fixtureRepo) commit(author string, email string, message string, files map[string]string, parents []plumbing.Hash) plumbing.Hash {
+	w, err := f.Repo.Worktree()
+	require.NoError(f.t, err)
+	for name, content := range files {
+		fullPath := filepath.Join(f.Path, name)
+		if content == "" {
+			_, err = w.Remove(name)
+			require.NoError(f.t, err)
+			continue
+		}
+		require.NoError(f.t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+		require.NoError(f.t, os.WriteFile(fullPath, []byte(content), 0644))
+		_, err = w.Add(name)
+		require.NoError(f.t, err)
+	}
+	f.When = f.When.Add(time.Hour)
+	signature := &object.Signature{Name: author, Email: email, When: f.When}
+	hash, err := w.Commit(message, &git.CommitOptions{
+		Author:            signature,
+		Committer:         signature,
+		Parents:           parents,
+		AllowEmptyCommits: true,
+	})
+	require.NoError(f.t, err)
+	return hash
+}
+
+// Reset moves the current branch to hash, discarding the commits after it.
+func (f *fixtureRepo) Reset(hash plumbing.Hash) {
+	w, err := f.Repo.Worktree()
+	require.NoError(f.t, err)
+	require.NoError(f.t, w.Reset(&git.ResetOptions{Commit: hash, Mode: git.HardReset}))
+}
+
+func openTestDb(t *testing.T) {
+	require.NoError(t, gitinsight.OpenDb("sqliteshim", "file:"+filepath.Join(t.TempDir(), "gitinsight.db")))
+	require.NoError(t, gitinsight.InitDb())
+	t.Cleanup(func() {
+		gitinsight.CloseDb()
+	})
+}
+
+func testConfig() *gitinsight.Config {
+	return &gitinsight.Config{
+		Since: "2025-01-01T00:00:00Z",
+	}
+}
+
+// Branch points a branch at hash without checking it out.
+func (f *fixtureRepo) Branch(name string, hash plumbing.Hash) {
+	ref := plumbing.NewHashReference(plumbing.NewBranchReferenceName(name), hash)
+	require.NoError(f.t, f.Repo.Storer.SetReference(ref))
+}
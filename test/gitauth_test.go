@@ -0,0 +1,124 @@
+package gitinsight_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-git/go-git/v6/plumbing/transport/http"
+	"github.com/go-git/go-git/v6/plumbing/transport/ssh"
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestGetAuthMethod(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	block, err := gossh.MarshalPrivateKey(key, "")
+	require.NoError(t, err)
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600))
+
+	require.True(t, gitinsight.IsSSHUrl("git@github.com:robotism/gitinsight.git"))
+	require.True(t, gitinsight.IsSSHUrl("ssh://git@github.com/robotism/gitinsight.git"))
+	require.False(t, gitinsight.IsSSHUrl("https://github.com/robotism/gitinsight.git"))
+
+	auth, err := gitinsight.GetAuthMethod(&gitinsight.Repo{
+		Url:        "git@github.com:robotism/gitinsight.git",
+		PrivateKey: keyFile,
+	})
+	require.NoError(t, err)
+	publicKeys, ok := auth.(*ssh.PublicKeys)
+	require.True(t, ok)
+	require.Equal(t, "git", publicKeys.User)
+
+	auth, err = gitinsight.GetAuthMethod(&gitinsight.Repo{
+		Url:      "ssh://deploy@gitlab.example.com/team/api.git",
+		SSHAgent: "/tmp/agent.sock",
+	})
+	require.NoError(t, err)
+	callback, ok := auth.(*ssh.PublicKeysCallback)
+	require.True(t, ok)
+	require.Equal(t, "deploy", callback.User)
+
+	auth, err = gitinsight.GetAuthMethod(&gitinsight.Repo{
+		Url:      "https://github.com/robotism/gitinsight.git",
+		User:     "robotism",
+		Password: "secret",
+	})
+	require.NoError(t, err)
+	basic, ok := auth.(*http.BasicAuth)
+	require.True(t, ok)
+	require.Equal(t, "robotism", basic.Username)
+
+	domainAuth, err := gitinsight.FindAuth(&gitinsight.Config{
+		Auths: []gitinsight.Auth{{Domain: "gitlab.example.com", PrivateKey: keyFile}},
+	}, &gitinsight.Repo{Url: "git@gitlab.example.com:team/api.git"})
+	require.NoError(t, err)
+	require.NotNil(t, domainAuth)
+	require.Equal(t, keyFile, domainAuth.PrivateKey)
+}
+
+func TestSSHAgentAuth(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	keyring := agent.NewKeyring()
+	require.NoError(t, keyring.Add(agent.AddedKey{PrivateKey: key}))
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	defer listener.Close()
+	var dials atomic.Int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			dials.Add(1)
+			go agent.ServeAgent(keyring, conn)
+		}
+	}()
+
+	auth, err := gitinsight.GetAuthMethod(&gitinsight.Repo{
+		Url:      "gitlab.example.com:team/api.git",
+		User:     "robotism",
+		SSHAgent: socket,
+	})
+	require.NoError(t, err)
+	callback, ok := auth.(*ssh.PublicKeysCallback)
+	require.True(t, ok)
+	// the http user of the repo is not the ssh one
+	require.Equal(t, "git", callback.User)
+
+	for i := 0; i < 3; i++ {
+		signers, err := callback.Callback()
+		require.NoError(t, err)
+		require.Len(t, signers, 1)
+	}
+	require.Equal(t, int32(1), dials.Load())
+}
+
+func TestSyncSkipsReposWithoutAuth(t *testing.T) {
+	fixture := newFixtureRepo(t)
+	fixture.Commit("alice", "feat: init", map[string]string{"main.go": "package main\n"})
+
+	config := testConfig()
+	config.Parallel = false
+	config.Cache.Path = t.TempDir()
+	config.Repos = []gitinsight.Repo{
+		{Url: "git@git.invalid:acme/api.git", PrivateKey: filepath.Join(t.TempDir(), "missing")},
+		{Url: fixture.Path},
+	}
+	repos, err := gitinsight.SyncRepo(config)
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{fixture.Path: {"master"}}, repos)
+}
@@ -0,0 +1,38 @@
+package gitinsight_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHotspots(t *testing.T) {
+	openTestDb(t)
+	config := testConfig()
+	fixture := newFixtureRepo(t)
+
+	fixture.Commit("alice", "feat: init", map[string]string{
+		"pkg/core.go":   "a\nb\nc\nd\n",
+		"pkg/big.go":    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16\n17\n18\n19\n20\n",
+		"pkg/gone.go":   "x\n",
+		"docs/guide.md": "guide\n",
+	})
+	fixture.Commit("bob", "fix: core", map[string]string{"pkg/core.go": "a\nB\nc\nd\n"})
+	fixture.Commit("carol", "fix: core", map[string]string{"pkg/core.go": "a\nB\nC\nd\n", "pkg/gone.go": "y\n"})
+	fixture.Commit("alice", "chore: drop", map[string]string{"pkg/gone.go": ""})
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "master"))
+
+	hotspots, total, err := gitinsight.GetHotspots(&gitinsight.CommitLogFilter{Path: "pkg/", Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, 2, total)
+	require.Equal(t, "pkg/core.go", hotspots[0].Path)
+	require.Equal(t, 3, hotspots[0].Commits)
+	require.Equal(t, 8, hotspots[0].Churn)
+	require.Equal(t, 24, hotspots[0].Score)
+	require.Equal(t, 3, hotspots[0].Authors)
+	require.Equal(t, fixture.When.Add(-time.Hour), hotspots[0].LastDate.UTC())
+	require.Equal(t, "pkg/big.go", hotspots[1].Path)
+	require.Equal(t, 20, hotspots[1].Score)
+}
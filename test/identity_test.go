@@ -0,0 +1,103 @@
+package gitinsight_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v6"
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMailmapResolve(t *testing.T) {
+	mailmap := gitinsight.ParseMailmap(strings.NewReader(`
+# comment
+John Doe <jdoe@corp.example>
+<john@corp.example> <john.doe@users.noreply.github.com>
+John Doe <john@corp.example> John D <shared@corp.example>
+Jane Roe <jane@corp.example> <shared@corp.example>
+broken line
+`))
+
+	name, email := mailmap.Resolve("jdoe", "JDOE@corp.example")
+	require.Equal(t, "John Doe", name)
+	require.Equal(t, "JDOE@corp.example", email)
+
+	name, email = mailmap.Resolve("John D", "john.doe@users.noreply.github.com")
+	require.Equal(t, "John D", name)
+	require.Equal(t, "john@corp.example", email)
+
+	name, email = mailmap.Resolve("John D", "shared@corp.example")
+	require.Equal(t, "John Doe", name)
+	require.Equal(t, "john@corp.example", email)
+
+	name, email = mailmap.Resolve("Someone", "shared@corp.example")
+	require.Equal(t, "Jane Roe", name)
+	require.Equal(t, "jane@corp.example", email)
+
+	var empty *gitinsight.Mailmap
+	name, email = empty.Resolve("a", "b")
+	require.Equal(t, "a", name)
+	require.Equal(t, "b", email)
+}
+
+func TestIdentityResolver(t *testing.T) {
+	mailmapPath := filepath.Join(t.TempDir(), "mailmap")
+	require.NoError(t, os.WriteFile(mailmapPath, []byte("Bot <bot@corp.example> <ci@corp.example>\n"), 0644))
+
+	config := testConfig()
+	config.Mailmap = mailmapPath
+	config.Authors = []gitinsight.Author{
+		{Name: "jdoe", Nickname: "john"},
+		{Email: "john@corp.example", Nickname: "john-by-email"},
+		{Names: []string{"Bot"}, Nickname: "bot"},
+		{Patterns: []string{`^Jane .*<.*@corp\.example>$`}, Nickname: "jane"},
+	}
+	repoMailmap := gitinsight.ParseMailmap(strings.NewReader("<john@corp.example> <john.doe@users.noreply.github.com>\n"))
+	identities := gitinsight.NewIdentityResolver(config, repoMailmap)
+
+	// an email match wins over an earlier name match
+	require.Equal(t, "john-by-email", identities.Nickname("jdoe", "john.doe@users.noreply.github.com"))
+	require.Equal(t, "john", identities.Nickname("jdoe", "jdoe@home.example"))
+	require.Equal(t, "bot", identities.Nickname("ci", "ci@corp.example"))
+	require.Equal(t, "jane", identities.Nickname("Jane Roe", "jane@corp.example"))
+	require.Equal(t, "stranger", identities.Nickname("stranger", ""))
+}
+
+func TestRefreshNicknames(t *testing.T) {
+	openTestDb(t)
+	config := testConfig()
+	fixture := newFixtureRepo(t)
+
+	fixture.CommitAs("jdoe", "jdoe@corp.example", "feat: a", map[string]string{"a.go": "package a\n"})
+	fixture.CommitAs("John D", "john.doe@users.noreply.github.com", "feat: b", map[string]string{"b.go": "package a\n"})
+	fixture.CommitAs("John Doe", "john@corp.example", "feat: c", map[string]string{
+		".mailmap": "John Doe <john@corp.example> <john.doe@users.noreply.github.com>\n",
+	})
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "master"))
+
+	nicknames := func() map[string]int {
+		authors, err := gitinsight.GetAuthors(&gitinsight.CommitLogFilter{})
+		require.NoError(t, err)
+		result := map[string]int{}
+		for _, author := range authors {
+			result[author.Nickname] = author.Commits
+		}
+		return result
+	}
+	require.Equal(t, map[string]int{"jdoe": 1, "John Doe": 2}, nicknames())
+
+	config.Authors = []gitinsight.Author{{Name: "John Doe", Emails: []string{"jdoe@corp.example"}, Nickname: "john"}}
+	repo, err := git.PlainOpen(fixture.Path)
+	require.NoError(t, err)
+	updated, err := gitinsight.RefreshNicknames("", gitinsight.NewRepoIdentityResolver(config, repo))
+	require.NoError(t, err)
+	require.Equal(t, 3, updated)
+	require.Equal(t, map[string]int{"john": 3}, nicknames())
+
+	updated, err = gitinsight.RefreshNicknames("", gitinsight.NewRepoIdentityResolver(config, repo))
+	require.NoError(t, err)
+	require.Zero(t, updated)
+}
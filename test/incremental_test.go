@@ -0,0 +1,53 @@
+package gitinsight_test
+
+import (
+	"testing"
+
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleBranchCommitLogsIncremental(t *testing.T) {
+	openTestDb(t)
+	config := testConfig()
+	fixture := newFixtureRepo(t)
+
+	fixture.Commit("alice", "feat: init", map[string]string{"main.go": "package main\n"})
+	base := fixture.Commit("bob", "fix: typo", map[string]string{"main.go": "package main\n\nfunc main() {}\n"})
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "master"))
+
+	state, err := gitinsight.GetBranchState("", "master")
+	require.NoError(t, err)
+	require.Equal(t, base.String(), state.CommitHash)
+
+	logs, err := gitinsight.GetCommitLogs(&gitinsight.CommitLogFilter{BranchName: "master", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+	firstIds := map[string]int64{}
+	for _, l := range logs {
+		firstIds[l.CommitHash] = l.ID
+	}
+
+	// A new commit is appended, existing rows are kept as they are
+	head := fixture.Commit("alice", "feat: more", map[string]string{"lib.go": "package main\n"})
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "master"))
+	logs, err = gitinsight.GetCommitLogs(&gitinsight.CommitLogFilter{BranchName: "master", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, logs, 3)
+	require.Equal(t, head.String(), logs[0].CommitHash)
+	for _, l := range logs[1:] {
+		require.Equal(t, firstIds[l.CommitHash], l.ID)
+	}
+
+	// A rewritten history falls back to a full rebuild
+	fixture.Reset(base)
+	rewritten := fixture.Commit("carol", "feat: other", map[string]string{"other.go": "package main\n"})
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "master"))
+	logs, err = gitinsight.GetCommitLogs(&gitinsight.CommitLogFilter{BranchName: "master", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, logs, 3)
+	require.Equal(t, rewritten.String(), logs[0].CommitHash)
+	for _, l := range logs {
+		require.NotEqual(t, head.String(), l.CommitHash)
+	}
+}
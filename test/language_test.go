@@ -0,0 +1,96 @@
+package gitinsight_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyLanguage(t *testing.T) {
+	config := testConfig()
+	config.Languages = []gitinsight.LanguageMapping{
+		{Language: "Go Template", Extensions: []string{"tpl"}},
+		{Language: "Starlark", Filenames: []string{"Tiltfile"}},
+	}
+	classifier := gitinsight.NewLanguageClassifier(config)
+
+	cases := []struct {
+		path     string
+		content  string
+		language string
+	}{
+		{"main.go", "", "Go"},
+		{"web/App.TSX", "", "TypeScript"},
+		{"Makefile", "", "Makefile"},
+		{"deploy/Dockerfile", "", "Dockerfile"},
+		{"include/list.h", "struct list { int n; };\n", "C"},
+		{"include/list.h", "namespace util {\nclass List {};\n}\n", "C++"},
+		{"include/View.h", "#import <UIKit/UIKit.h>\n@interface View\n", "Objective-C"},
+		{"bin/release", "#!/usr/bin/env python3\nprint(1)\n", "Python"},
+		{"bin/setup", "#!/bin/bash -e\necho\n", "Shell"},
+		{"bin/unknown", "plain text\n", gitinsight.LanguageOther},
+		{"chart/values.tpl", "", "Go Template"},
+		{"Tiltfile", "", "Starlark"},
+	}
+	for _, c := range cases {
+		content := c.content
+		require.Equal(t, c.language, classifier.Classify(c.path, func() string { return content }), c.path)
+	}
+
+	var defaults *gitinsight.LanguageClassifier
+	require.Equal(t, gitinsight.LanguageOther, defaults.Classify("chart/values.tpl", func() string { return "" }))
+}
+
+func TestLanguageStats(t *testing.T) {
+	openTestDb(t)
+	config := testConfig()
+	fixture := newFixtureRepo(t)
+
+	fixture.Commit("alice", "feat: init", map[string]string{
+		"main.go":    "package main\n\nfunc main() {}\n",
+		"Makefile":   "all:\n\tgo build\n",
+		"bin/deploy": "#!/bin/sh\necho deploy\n",
+	})
+	fixture.Commit("bob", "feat: util", map[string]string{
+		"main.go": "package main\n",
+		"util.go": "package main\n\nfunc util() {}\n",
+	})
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "master"))
+
+	commitLogs, err := gitinsight.GetCommitLogs(&gitinsight.CommitLogFilter{CommitHash: "", Limit: 10})
+	require.NoError(t, err)
+	for _, commitLog := range commitLogs {
+		if commitLog.Message != "feat: util" {
+			continue
+		}
+		languageStats := map[string]gitinsight.LanguageStat{}
+		require.NoError(t, json.Unmarshal([]byte(commitLog.LanguageStats), &languageStats))
+		require.Equal(t, gitinsight.LanguageStat{Files: 2, Additions: 3, Deletions: 2}, languageStats["Go"])
+	}
+
+	languages, err := gitinsight.GetLanguageStats(&gitinsight.CommitLogFilter{})
+	require.NoError(t, err)
+	byLanguage := map[string]gitinsight.LanguageStatItem{}
+	for _, language := range languages {
+		byLanguage[language.Language] = language
+	}
+	require.Equal(t, 6, byLanguage["Go"].Additions)
+	require.Equal(t, 2, byLanguage["Go"].Commits)
+	require.Equal(t, 2, byLanguage["Makefile"].Additions)
+	require.Equal(t, 2, byLanguage["Shell"].Additions)
+
+	languages, err = gitinsight.GetLanguageStats(&gitinsight.CommitLogFilter{GroupBy: "author,period"})
+	require.NoError(t, err)
+	for _, language := range languages {
+		require.Equal(t, "2025-10", language.Period)
+		if language.Language == "Go" && language.Nickname == "bob" {
+			require.Equal(t, 3, language.Additions)
+			require.Equal(t, 2, language.Deletions)
+		}
+	}
+
+	_, err = gitinsight.GetLanguageStats(&gitinsight.CommitLogFilter{GroupBy: "team"})
+	require.Error(t, err)
+}
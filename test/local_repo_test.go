@@ -0,0 +1,68 @@
+package gitinsight_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalRepoPath(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	cases := map[string]string{
+		"/srv/git/api.git":          "/srv/git/api.git",
+		"file:///srv/git/api.git":   "/srv/git/api.git",
+		"file:///srv/git/my%20repo": "/srv/git/my repo",
+		"./checkout":                filepath.Join(wd, "checkout"),
+		"../checkout":               filepath.Join(filepath.Dir(wd), "checkout"),
+		"~/src/api":                 filepath.Join(home, "src/api"),
+	}
+	for url, expected := range cases {
+		path, ok := gitinsight.LocalRepoPath(url)
+		require.True(t, ok, url)
+		require.Equal(t, filepath.FromSlash(expected), path, url)
+	}
+
+	for _, url := range []string{
+		"https://github.com/robotism/gitinsight.git",
+		"git@gitlab.corp:team-a/api.git",
+		"ssh://git@gitlab.corp:2222/team-a/api.git",
+	} {
+		_, ok := gitinsight.LocalRepoPath(url)
+		require.False(t, ok, url)
+	}
+}
+
+func TestSyncLocalRepos(t *testing.T) {
+	fixture := newFixtureRepo(t)
+	initial := fixture.Commit("alice", "feat: init", map[string]string{"main.go": "package main\n"})
+	fixture.Branch("feature", initial)
+
+	// a bare repository behind a file:// url
+	barePath := filepath.Join(t.TempDir(), "api.git")
+	_, err := gitinsight.CloneOrUpdateRepo(fixture.Path, barePath, nil, nil)
+	require.NoError(t, err)
+
+	cachePath := filepath.Join(t.TempDir(), "repos")
+	config := &gitinsight.Config{
+		Cache: gitinsight.Cache{Path: cachePath},
+		Repos: []gitinsight.Repo{{Url: fixture.Path}, {Url: "file://" + filepath.ToSlash(barePath)}},
+	}
+	repos, err := gitinsight.SyncRepo(config)
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{
+		fixture.Path: {"feature", "master"},
+		barePath:     {"feature", "master"},
+	}, repos)
+	_, err = os.Stat(cachePath)
+	require.True(t, os.IsNotExist(err))
+
+	require.Equal(t, fixture.Path, gitinsight.GetRepoUrl(config, fixture.Path))
+	require.Equal(t, "file://"+filepath.ToSlash(barePath), gitinsight.GetRepoUrl(config, barePath))
+}
@@ -0,0 +1,68 @@
+package gitinsight_test
+
+import (
+	"testing"
+
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeDiff(t *testing.T) {
+	openTestDb(t)
+	config := testConfig()
+	fixture := newFixtureRepo(t)
+
+	base := fixture.Commit("alice", "feat: init", map[string]string{"a.txt": "1\n2\n3\n4\n5\n"})
+	feature := fixture.Commit("bob", "feat: feature", map[string]string{
+		"a.txt": "1\n2\n3\n4\nF\n",
+		"b.txt": "b1\nb2\nb3\n",
+	})
+	fixture.Reset(base)
+	fixture.Commit("alice", "feat: main", map[string]string{"a.txt": "M\n2\n3\n4\n5\n"})
+	// the merge resolves a conflict on line 3
+	hash := fixture.Merge("alice", "Merge branch 'feature'", map[string]string{
+		"a.txt": "M\n2\nR\n4\nF\n",
+		"b.txt": "b1\nb2\nb3\n",
+	}, feature)
+	merge, err := fixture.Repo.CommitObject(hash)
+	require.NoError(t, err)
+
+	sum := func(mergeDiff string) [2]int {
+		files, err := gitinsight.GetCommitFiles(merge, &gitinsight.DiffOptions{MergeDiff: mergeDiff})
+		require.NoError(t, err)
+		additions, deletions := gitinsight.SumCommitFiles(files)
+		return [2]int{additions, deletions}
+	}
+	require.Equal(t, [2]int{5, 2}, sum(""))
+	require.Equal(t, [2]int{5, 2}, sum(gitinsight.MergeDiffFirstParent))
+	require.Equal(t, [2]int{1, 2}, sum(gitinsight.MergeDiffCombined))
+	require.Equal(t, [2]int{0, 0}, sum(gitinsight.MergeDiffSkip))
+
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "master"))
+	commitLogs, err := gitinsight.GetCommitLogs(&gitinsight.CommitLogFilter{IsMerge: "1"})
+	require.NoError(t, err)
+	require.Len(t, commitLogs, 1)
+	require.Equal(t, 5, commitLogs[0].Additions)
+	require.Equal(t, 1, commitLogs[0].Introduced)
+
+	// skipped merges count no introduced lines either
+	skipped := gitinsight.AnalyzeCommit(gitinsight.NewCommitAnalyzer(&gitinsight.Config{MergeDiff: gitinsight.MergeDiffSkip}, fixture.Repo, ""),
+		merge, gitinsight.CheckUpTodateFilter{})
+	require.Zero(t, skipped.Additions)
+	require.Zero(t, skipped.Introduced)
+
+	// merges are ranked when asked for only
+	ranking, err := gitinsight.GetRanking(&gitinsight.CommitLogFilter{IsMerge: "1"})
+	require.NoError(t, err)
+	require.Len(t, ranking, 1)
+	require.Equal(t, "alice", ranking[0].Nickname)
+	require.Equal(t, 1, ranking[0].Commits)
+	require.Equal(t, 5, ranking[0].Additions)
+	ranking, err = gitinsight.GetRanking(&gitinsight.CommitLogFilter{})
+	require.NoError(t, err)
+	commits := 0
+	for _, item := range ranking {
+		commits += item.Commits
+	}
+	require.Equal(t, 3, commits)
+}
@@ -0,0 +1,44 @@
+package gitinsight_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloneOrUpdateMirror(t *testing.T) {
+	fixture := newFixtureRepo(t)
+	initial := fixture.Commit("alice", "feat: init", map[string]string{"main.go": "package main\n"})
+	fixture.Commit("alice", "feat: more", map[string]string{"more.go": "package main\n"})
+	fixture.Branch("feature", initial)
+
+	path := filepath.Join(t.TempDir(), "mirror")
+	repo, err := gitinsight.CloneOrUpdateRepo(fixture.Path, path, nil, nil)
+	require.NoError(t, err)
+	_, err = repo.Worktree()
+	require.ErrorIs(t, err, git.ErrIsBareRepository)
+	branches, err := gitinsight.GetBranches(repo)
+	require.NoError(t, err)
+	require.Equal(t, []string{"feature", "master"}, branches)
+	head, err := repo.Head()
+	require.NoError(t, err)
+	require.Equal(t, "master", head.Name().Short())
+
+	// force push master and delete the feature branch
+	fixture.Reset(initial)
+	rewritten := fixture.Commit("bob", "feat: other", map[string]string{"other.go": "package main\n"})
+	require.NoError(t, fixture.Repo.Storer.RemoveReference(plumbing.NewBranchReferenceName("feature")))
+
+	repo, err = gitinsight.CloneOrUpdateRepo(fixture.Path, path, nil, nil)
+	require.NoError(t, err)
+	branches, err = gitinsight.GetBranches(repo)
+	require.NoError(t, err)
+	require.Equal(t, []string{"master"}, branches)
+	ref, err := gitinsight.GetBranchRef(repo, "master")
+	require.NoError(t, err)
+	require.Equal(t, rewritten, ref.Hash())
+}
@@ -0,0 +1,121 @@
+package gitinsight_test
+
+import (
+	"testing"
+
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOwnership(t *testing.T) {
+	openTestDb(t)
+	config := testConfig()
+	config.Ownership = gitinsight.Ownership{Enabled: true}
+	config.Authors = []gitinsight.Author{
+		{Name: "alice", Email: "alice@example.com", Nickname: "alice", Team: "core"},
+		{Name: "bob", Email: "bob@example.com", Nickname: "bob", Team: "core"},
+	}
+	fixture := newFixtureRepo(t)
+	fixture.Commit("alice", "feat: add", map[string]string{
+		"src/a.go":  "a1\na2\na3\na4\n",
+		"docs/a.md": "d1\nd2\n",
+	})
+	fixture.Commit("bob", "fix: a", map[string]string{"src/a.go": "a1\nb2\nb3\na4\n"})
+	fixture.Commit("carol", "feat: b", map[string]string{"src/b/b.go": "c1\nc2\n"})
+
+	require.NoError(t, gitinsight.HandleRepoOwnershipToDb(config, fixture.Path))
+
+	ownership, err := gitinsight.GetOwnership(&gitinsight.CommitLogFilter{}, gitinsight.GetTeams(config))
+	require.NoError(t, err)
+	require.Len(t, ownership, 2)
+	require.Equal(t, "src", ownership[0].Path)
+	require.Equal(t, "master", ownership[0].BranchName)
+	require.Equal(t, 2, ownership[0].Files)
+	require.Equal(t, 6, ownership[0].Lines)
+	owners := map[string]gitinsight.OwnerDTO{}
+	for _, owner := range ownership[0].Owners {
+		owners[owner.Nickname] = owner
+	}
+	require.Equal(t, gitinsight.OwnerDTO{Nickname: "alice", Team: "core", Files: 1, Lines: 2, Share: 33.33}, owners["alice"])
+	require.Equal(t, gitinsight.OwnerDTO{Nickname: "carol", Files: 1, Lines: 2, Share: 33.33}, owners["carol"])
+
+	ownership, err = gitinsight.GetOwnership(&gitinsight.CommitLogFilter{Path: "src/", Depth: 1, GroupBy: "team"}, gitinsight.GetTeams(config))
+	require.NoError(t, err)
+	require.Len(t, ownership, 2)
+	require.Equal(t, "src", ownership[0].Path)
+	require.Equal(t, []gitinsight.OwnerDTO{{Team: "core", Files: 1, Lines: 4, Share: 100}}, ownership[0].Owners)
+	require.Equal(t, "src/b", ownership[1].Path)
+	require.Equal(t, []gitinsight.OwnerDTO{{Team: "", Files: 1, Lines: 2, Share: 100}}, ownership[1].Owners)
+
+	// a moved head replaces the snapshot
+	fixture.Commit("bob", "docs: a", map[string]string{"docs/a.md": "d1\nb2\n"})
+	require.NoError(t, gitinsight.HandleRepoOwnershipToDb(config, fixture.Path))
+	ownership, err = gitinsight.GetOwnership(&gitinsight.CommitLogFilter{Path: "docs/", Nickname: "bob"}, nil)
+	require.NoError(t, err)
+	require.Len(t, ownership, 1)
+	require.Equal(t, []gitinsight.OwnerDTO{{Nickname: "bob", Files: 1, Lines: 1, Share: 50}}, ownership[0].Owners)
+
+	// a path prefix does not match its siblings
+	ownership, err = gitinsight.GetOwnership(&gitinsight.CommitLogFilter{Path: "sr"}, nil)
+	require.NoError(t, err)
+	require.Empty(t, ownership)
+}
+
+func TestOwnershipStaleBranches(t *testing.T) {
+	openTestDb(t)
+	config := testConfig()
+	config.Ownership = gitinsight.Ownership{Enabled: true, Branches: []string{"master", "topic", "release"}}
+	fixture := newFixtureRepo(t)
+	initial := fixture.Commit("alice", "feat: add", map[string]string{"a.go": "a1\na2\n"})
+	fixture.Branch("topic", initial)
+	fixture.Branch("release", initial)
+	fixture.Commit("bob", "fix: a", map[string]string{"a.go": "a1\nb2\n"})
+	repoUrl := gitinsight.GetRepoUrl(config, fixture.Path)
+
+	require.NoError(t, gitinsight.HandleRepoOwnershipToDb(config, fixture.Path))
+	lines := func() map[string]int {
+		ownership, err := gitinsight.GetOwnership(&gitinsight.CommitLogFilter{}, nil)
+		require.NoError(t, err)
+		byBranch := map[string]int{}
+		for _, item := range ownership {
+			byBranch[item.BranchName] += item.Lines
+		}
+		return byBranch
+	}
+	require.Equal(t, map[string]int{"master": 2, "topic": 2, "release": 2}, lines())
+
+	// a deleted branch takes its snapshot along
+	_, err := gitinsight.DeleteStaleBranches(repoUrl, []string{"master", "topic"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"master": 2, "topic": 2}, lines())
+
+	// a branch no longer configured is dropped on the next snapshot
+	config.Ownership.Branches = []string{"master"}
+	require.NoError(t, gitinsight.HandleRepoOwnershipToDb(config, fixture.Path))
+	require.Equal(t, map[string]int{"master": 2}, lines())
+}
+
+func TestOwnershipHeadWithoutFiles(t *testing.T) {
+	openTestDb(t)
+	config := testConfig()
+	config.Ownership = gitinsight.Ownership{Enabled: true}
+	config.Paths.Include = []string{"src/**"}
+	fixture := newFixtureRepo(t)
+	head := fixture.Commit("alice", "docs: add", map[string]string{"docs/a.md": "d1\n"})
+	repoUrl := gitinsight.GetRepoUrl(config, fixture.Path)
+
+	// the head is recorded although no file is counted, it is not blamed again
+	require.NoError(t, gitinsight.HandleRepoOwnershipToDb(config, fixture.Path))
+	ownership, err := gitinsight.GetOwnership(&gitinsight.CommitLogFilter{}, nil)
+	require.NoError(t, err)
+	require.Empty(t, ownership)
+	snapshotHash, err := gitinsight.GetOwnershipHead(repoUrl, "master")
+	require.NoError(t, err)
+	require.Equal(t, head.String(), snapshotHash)
+
+	_, err = gitinsight.DeleteStaleBranches(repoUrl, []string{"topic"})
+	require.NoError(t, err)
+	snapshotHash, err = gitinsight.GetOwnershipHead(repoUrl, "master")
+	require.NoError(t, err)
+	require.Empty(t, snapshotHash)
+}
@@ -0,0 +1,139 @@
+package gitinsight_test
+
+import (
+	"testing"
+
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"vendor/**", "vendor/github.com/x/y.go", true},
+		{"vendor/**", "pkg/vendor/y.go", false},
+		{"**/vendor/**", "pkg/vendor/y.go", true},
+		{"**/*.pb.go", "api/v1/user.pb.go", true},
+		{"*.pb.go", "user.pb.go", true},
+		{"*.lock", "web/yarn.lock", true},
+		{"dist/**", "dist/app.js", true},
+		{"dist/**", "dist", false},
+		{"docs/", "docs/index.md", true},
+		{"/go.sum", "go.sum", true},
+		{"*.go", "main.go.txt", false},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.match, gitinsight.MatchGlob(c.pattern, c.path), "%s %s", c.pattern, c.path)
+	}
+}
+
+func TestPathRulesExcludeLines(t *testing.T) {
+	openTestDb(t)
+	config := testConfig()
+	config.Paths.Exclude = []string{"docs/**"}
+	fixture := newFixtureRepo(t)
+
+	fixture.Commit("alice", "feat: init", map[string]string{
+		"main.go":                   "package main\n",
+		"go.sum":                    "a v1\nb v1\n",
+		"vendor/lib/lib.go":         "package lib\n\nfunc A() {}\n",
+		"docs/guide.md":             "# guide\n",
+		"api/user.pb.go":            "package api\n\n\n",
+		"web/dist/app.min.js":       "x\n",
+		"web/node_modules/x/x.js":   "x\n",
+		"web/package-lock.json":     "{}\n",
+		"internal/zz_generated.go":  "package internal\n",
+		"internal/deepcopy_test.go": "package internal\n",
+	})
+	fixture.Commit("bob", "chore: vendor", map[string]string{
+		"main.go":           "package main\n\nfunc main() {}\n",
+		"vendor/lib/lib.go": "package lib\n",
+		"go.sum":            "a v2\n",
+	})
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "master"))
+
+	commitLogs, err := gitinsight.GetCommitLogs(&gitinsight.CommitLogFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, commitLogs, 2)
+	byMessage := map[string]gitinsight.CommitLogModel{}
+	for _, commitLog := range commitLogs {
+		byMessage[commitLog.Message] = commitLog
+	}
+	require.Equal(t, 2, byMessage["feat: init"].Additions)
+	require.Equal(t, 2, byMessage["chore: vendor"].Additions)
+	require.Equal(t, 0, byMessage["chore: vendor"].Deletions)
+
+	changes, err := gitinsight.GetFileChanges(&gitinsight.CommitLogFilter{})
+	require.NoError(t, err)
+	for _, change := range changes {
+		require.Contains(t, []string{"main.go", "internal/deepcopy_test.go"}, change.Path)
+	}
+}
+
+func TestPathRulesRepoInclude(t *testing.T) {
+	config := testConfig()
+	config.Paths.NoDefaults = true
+	config.Repos = []gitinsight.Repo{{Url: "https://example.com/a.git", Include: []string{"src/**"}}}
+
+	matcher := gitinsight.NewPathMatcher(config, "https://example.com/a.git")
+	require.True(t, matcher.Match("src/vendor/a.go"))
+	require.False(t, matcher.Match("test/a.go"))
+
+	other := gitinsight.NewPathMatcher(config, "https://example.com/b.git")
+	require.True(t, other.Match("test/a.go"))
+	require.True(t, other.Match("go.sum"))
+}
+
+func TestPathRulesChangeReanalyzes(t *testing.T) {
+	openTestDb(t)
+	config := testConfig()
+	fixture := newFixtureRepo(t)
+
+	feature := fixture.Commit("alice", "feat: init", map[string]string{
+		"main.go":       "package main\n",
+		"docs/guide.md": "# guide\n\nintro\n",
+	})
+	fixture.Branch("feature", feature)
+	fixture.Commit("bob", "docs: more", map[string]string{"docs/guide.md": "# guide\n\nintro\nmore\n"})
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "master"))
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "feature"))
+
+	additions := func() map[string]int {
+		commitLogs, err := gitinsight.GetCommitLogs(&gitinsight.CommitLogFilter{Limit: 10})
+		require.NoError(t, err)
+		byMessage := map[string]int{}
+		for _, commitLog := range commitLogs {
+			byMessage[commitLog.Message] = commitLog.Additions
+		}
+		return byMessage
+	}
+	require.Equal(t, map[string]int{"feat: init": 4, "docs: more": 1}, additions())
+
+	// the stored commits are diffed again with the new rules, the commit
+	// shared with feature only once
+	config.Paths.Exclude = []string{"docs/**"}
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "master"))
+	require.Equal(t, map[string]int{"feat: init": 1, "docs: more": 0}, additions())
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "feature"))
+	require.Equal(t, map[string]int{"feat: init": 1, "docs: more": 0}, additions())
+
+	changes, err := gitinsight.GetFileChanges(&gitinsight.CommitLogFilter{})
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	require.Equal(t, "main.go", changes[0].Path)
+
+	branches, err := gitinsight.GetRepoBranches(&gitinsight.CommitLogFilter{})
+	require.NoError(t, err)
+	commits := map[string]int{}
+	for _, b := range branches {
+		commits[b.BranchName] = b.Commits
+	}
+	require.Equal(t, map[string]int{"master": 2, "feature": 1}, commits)
+	repoUrl := gitinsight.GetRepoUrl(config, fixture.Path)
+	state, err := gitinsight.GetBranchState(repoUrl, "feature")
+	require.NoError(t, err)
+	require.Equal(t, gitinsight.AnalysisFingerprint(config, repoUrl), state.Fingerprint)
+}
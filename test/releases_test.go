@@ -0,0 +1,102 @@
+package gitinsight_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleases(t *testing.T) {
+	openTestDb(t)
+	config := testConfig()
+	fixture := newFixtureRepo(t)
+
+	fixture.Commit("alice", "feat: init", map[string]string{"main.go": "package main\n"})
+	first := fixture.Commit("bob", "feat: api", map[string]string{"api.go": "package main\n"})
+	_, err := fixture.Repo.CreateTag("v1.0.0", first, nil)
+	require.NoError(t, err)
+
+	fix := fixture.Commit("alice", "fix: api", map[string]string{"api.go": "package main\n\nvar x = 1\n"})
+	fixture.Commit("carol", "feat: ui", map[string]string{"ui.go": "package main\n"})
+	second := fixture.Commit("alice", "docs: readme", map[string]string{"README.md": "# fixture\n"})
+	_, err = fixture.Repo.CreateTag("v1.1.0", second, &git.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "release", Email: "release@example.com", When: fixture.When.Add(48 * time.Hour)},
+		Message: "Release 1.1.0",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "master"))
+	require.NoError(t, gitinsight.HandleRepoTagsToDb(config, fixture.Path))
+	// unchanged tags are not walked again
+	require.NoError(t, gitinsight.HandleRepoTagsToDb(config, fixture.Path))
+
+	releases, err := gitinsight.GetReleases(&gitinsight.CommitLogFilter{})
+	require.NoError(t, err)
+	require.Len(t, releases, 2)
+
+	latest := releases[0]
+	require.Equal(t, "v1.1.0", latest.TagName)
+	require.True(t, latest.Annotated)
+	require.Equal(t, "release", latest.TaggerName)
+	require.Equal(t, "Release 1.1.0", latest.Message)
+	require.Equal(t, "v1.0.0", latest.PreviousTag)
+	require.Equal(t, 3, latest.Commits)
+	require.Equal(t, 2, latest.Contributors)
+	require.Equal(t, []string{"alice", "carol"}, latest.Nicknames)
+	require.Equal(t, 2.13, latest.Days)
+
+	initial := releases[1]
+	require.Equal(t, "v1.0.0", initial.TagName)
+	require.False(t, initial.Annotated)
+	require.Equal(t, first.String(), initial.CommitHash)
+	require.Equal(t, "", initial.PreviousTag)
+	require.Nil(t, initial.PreviousDate)
+	require.Equal(t, 2, initial.Commits)
+
+	filtered, err := gitinsight.GetReleases(&gitinsight.CommitLogFilter{Nickname: "carol"})
+	require.NoError(t, err)
+	require.Equal(t, 1, filtered[0].Commits)
+	require.Equal(t, []string{"carol"}, filtered[0].Nicknames)
+	require.Equal(t, 0, filtered[1].Commits)
+	require.Equal(t, []string{}, filtered[1].Nicknames)
+
+	// a tag inserted between two tags re-walks the tag after it, a new tag
+	// only itself
+	_, err = fixture.Repo.CreateTag("v1.0.1", fix, nil)
+	require.NoError(t, err)
+	third := fixture.Commit("carol", "feat: export", map[string]string{"export.go": "package main\n"})
+	_, err = fixture.Repo.CreateTag("v1.2.0", third, &git.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "release", Email: "release@example.com", When: fixture.When.Add(72 * time.Hour)},
+		Message: "Release 1.2.0",
+	})
+	require.NoError(t, err)
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "master"))
+	require.NoError(t, gitinsight.HandleRepoTagsToDb(config, fixture.Path))
+	releases, err = gitinsight.GetReleases(&gitinsight.CommitLogFilter{})
+	require.NoError(t, err)
+	byTag := map[string]gitinsight.ReleaseItem{}
+	for _, release := range releases {
+		byTag[release.TagName] = release
+	}
+	require.Len(t, byTag, 4)
+	require.Equal(t, 2, byTag["v1.0.0"].Commits)
+	require.Equal(t, "v1.0.0", byTag["v1.0.1"].PreviousTag)
+	require.Equal(t, 1, byTag["v1.0.1"].Commits)
+	require.Equal(t, "v1.0.1", byTag["v1.1.0"].PreviousTag)
+	require.Equal(t, 2, byTag["v1.1.0"].Commits)
+	require.Equal(t, 1, byTag["v1.2.0"].Commits)
+
+	// removing it walks the tag after it against v1.0.0 again
+	require.NoError(t, fixture.Repo.DeleteTag("v1.0.1"))
+	require.NoError(t, gitinsight.HandleRepoTagsToDb(config, fixture.Path))
+	releases, err = gitinsight.GetReleases(&gitinsight.CommitLogFilter{})
+	require.NoError(t, err)
+	require.Len(t, releases, 3)
+	require.Equal(t, "v1.1.0", releases[1].TagName)
+	require.Equal(t, "v1.0.0", releases[1].PreviousTag)
+	require.Equal(t, 3, releases[1].Commits)
+}
@@ -0,0 +1,126 @@
+package gitinsight_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenames(t *testing.T) {
+	openTestDb(t)
+	config := testConfig()
+	config.Renames = gitinsight.Renames{Similarity: 60, Copies: true}
+	fixture := newFixtureRepo(t)
+
+	content := strings.Repeat("line\n", 9) + "end\n"
+	fixture.Commit("alice", "feat: add", map[string]string{"pkg/a.go": content})
+	moved := fixture.Commit("bob", "refactor: move", map[string]string{"pkg/a.go": "", "lib/a.go": content + "more\n"})
+	fixture.Commit("carol", "fix: edit", map[string]string{"lib/a.go": content})
+	copied := fixture.Commit("dave", "chore: copy", map[string]string{"lib/b.go": content})
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "master"))
+
+	changes, err := gitinsight.GetFileChanges(&gitinsight.CommitLogFilter{})
+	require.NoError(t, err)
+	byCommit := map[string]gitinsight.FileChangeItem{}
+	for _, change := range changes {
+		byCommit[change.CommitHash] = change
+	}
+	move := byCommit[moved.String()]
+	require.Equal(t, gitinsight.ChangeTypeRename, move.ChangeType)
+	require.Equal(t, "lib/a.go", move.Path)
+	require.Equal(t, "pkg/a.go", move.OldPath)
+	require.Equal(t, 1, move.Additions)
+	require.Equal(t, 0, move.Deletions)
+	duplicate := byCommit[copied.String()]
+	require.Equal(t, gitinsight.ChangeTypeCopy, duplicate.ChangeType)
+	require.Equal(t, "lib/a.go", duplicate.OldPath)
+	require.Equal(t, 0, duplicate.Additions)
+
+	files, total, err := gitinsight.GetFileStats(&gitinsight.CommitLogFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, 2, total)
+	require.Equal(t, "lib/a.go", files[0].Path)
+	require.Equal(t, []string{"pkg/a.go"}, files[0].OldPaths)
+	require.Equal(t, 3, files[0].Commits)
+	require.Equal(t, 3, files[0].Authors)
+
+	// a file added again at the old path is not the renamed one
+	fixture.Commit("erin", "feat: new a", map[string]string{"pkg/a.go": "package pkg\n"})
+	fixture.Commit("erin", "fix: new a", map[string]string{"pkg/a.go": "package pkg\n\nvar A = 1\n"})
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "master"))
+	files, total, err = gitinsight.GetFileStats(&gitinsight.CommitLogFilter{Limit: 10, UntilTime: fixture.When.Add(time.Hour)})
+	require.NoError(t, err)
+	require.Equal(t, 3, total)
+	byPath := map[string]gitinsight.FileStatDTO{}
+	for _, file := range files {
+		byPath[file.Path] = file
+	}
+	require.Equal(t, 3, byPath["lib/a.go"].Commits)
+	require.Equal(t, 2, byPath["pkg/a.go"].Commits)
+	require.Equal(t, []string{"erin"}, byPath["pkg/a.go"].Nicknames)
+}
+
+func TestRenamesDisabled(t *testing.T) {
+	fixture := newFixtureRepo(t)
+	content := strings.Repeat("line\n", 10)
+	fixture.Commit("alice", "feat: add", map[string]string{"pkg/a.go": content})
+	hash := fixture.Commit("bob", "refactor: move", map[string]string{"pkg/a.go": "", "lib/a.go": content})
+	commit, err := fixture.Repo.CommitObject(hash)
+	require.NoError(t, err)
+
+	files, err := gitinsight.GetCommitFiles(commit, &gitinsight.DiffOptions{Renames: gitinsight.Renames{Disabled: true}})
+	require.NoError(t, err)
+	additions, deletions := gitinsight.SumCommitFiles(files)
+	require.Equal(t, 10, additions)
+	require.Equal(t, 10, deletions)
+
+	files, err = gitinsight.GetCommitFiles(commit, nil)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, gitinsight.ChangeTypeRename, files[0].ChangeType)
+	additions, deletions = gitinsight.SumCommitFiles(files)
+	require.Zero(t, additions)
+	require.Zero(t, deletions)
+}
+
+func TestNearCopies(t *testing.T) {
+	fixture := newFixtureRepo(t)
+	content := strings.Repeat("line\n", 9) + "end\n"
+	other := strings.Repeat("other\n", 9) + "end\n"
+	fixture.Commit("alice", "feat: add", map[string]string{"pkg/a.go": content, "pkg/b.go": other})
+	hash := fixture.Commit("bob", "feat: copy", map[string]string{
+		"pkg/a.go": content + "edit\n",
+		"lib/a.go": content + "copy\n",
+		"lib/c.go": content + "second copy\n",
+		"lib/b.go": other + "unrelated\n",
+	})
+	commit, err := fixture.Repo.CommitObject(hash)
+	require.NoError(t, err)
+
+	opts := &gitinsight.DiffOptions{Renames: gitinsight.Renames{Similarity: 60, Copies: true}}
+	files, err := gitinsight.GetCommitFiles(commit, opts)
+	require.NoError(t, err)
+	byPath := map[string]gitinsight.CommitFile{}
+	for _, file := range files {
+		byPath[file.Path] = file
+	}
+	// similar to a file the commit modified, each copy counts its own lines
+	for _, path := range []string{"lib/a.go", "lib/c.go"} {
+		require.Equal(t, gitinsight.ChangeTypeCopy, byPath[path].ChangeType, path)
+		require.Equal(t, "pkg/a.go", byPath[path].OldPath, path)
+		require.Equal(t, 1, byPath[path].Additions, path)
+		require.Zero(t, byPath[path].Deletions, path)
+	}
+	// the sources of near copies are the files of the commit, like git -C
+	require.Equal(t, gitinsight.ChangeTypeAdd, byPath["lib/b.go"].ChangeType)
+	require.Equal(t, 11, byPath["lib/b.go"].Additions)
+
+	files, err = gitinsight.GetCommitFiles(commit, &gitinsight.DiffOptions{Renames: gitinsight.Renames{Similarity: 100, Copies: true}})
+	require.NoError(t, err)
+	for _, file := range files {
+		require.NotEqual(t, gitinsight.ChangeTypeCopy, file.ChangeType, file.Path)
+	}
+}
@@ -0,0 +1,110 @@
+package gitinsight_test
+
+import (
+	"testing"
+
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRevert(t *testing.T) {
+	cases := []struct {
+		message  string
+		isRevert bool
+		hash     string
+	}{
+		{"Revert \"feat: api\"\n\nThis reverts commit 0123456789ABCDEF0123456789abcdef01234567.", true, "0123456789abcdef0123456789abcdef01234567"},
+		{"Revert \"feat: api\"", true, ""},
+		{"Reapply \"feat: api\"\n\nThis reverts commit 89abcdef.", true, "89abcdef"},
+		{"revert: drop the noodles\n\nRefs: 676104e, a215868", true, "676104e"},
+		{"revert(api): drop the noodles", true, ""},
+		{"fix: revert the default timeout", false, ""},
+		{"Reverted the change of yesterday", false, ""},
+	}
+	for _, c := range cases {
+		isRevert, hash := gitinsight.ParseRevert(c.message, nil)
+		require.Equal(t, c.isRevert, isRevert, c.message)
+		require.Equal(t, c.hash, hash, c.message)
+	}
+}
+
+func TestExcludeReverts(t *testing.T) {
+	openTestDb(t)
+	config := testConfig()
+	fixture := newFixtureRepo(t)
+
+	feature := fixture.Commit("alice", "feat: api", map[string]string{"api.go": "package main\n\nvar x = 1\n"})
+	fixture.Commit("bob", "Revert \"feat: api\"\n\nThis reverts commit "+feature.String()+".", map[string]string{"api.go": ""})
+	cleanup := fixture.Commit("carol", "chore: cleanup", map[string]string{"main.go": "package main\n"})
+	fixture.Commit("carol", "revert: cleanup\n\nRefs: "+cleanup.String()[:7], map[string]string{"main.go": ""})
+	fixture.Commit("dave", "feat: ui", map[string]string{"ui.go": "package main\n"})
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "master"))
+
+	commits, err := gitinsight.GetCommitLogs(&gitinsight.CommitLogFilter{Limit: 10})
+	require.NoError(t, err)
+	revertsHash := map[string]string{}
+	for _, commit := range commits {
+		if commit.IsRevert {
+			revertsHash[commit.Nickname] = commit.RevertsHash
+		}
+	}
+	// abbreviated hashes are expanded
+	require.Equal(t, map[string]string{"bob": feature.String(), "carol": cleanup.String()}, revertsHash)
+
+	ranking, err := gitinsight.GetRanking(&gitinsight.CommitLogFilter{})
+	require.NoError(t, err)
+	require.Len(t, ranking, 4)
+
+	ranking, err = gitinsight.GetRanking(&gitinsight.CommitLogFilter{ExcludeReverts: true})
+	require.NoError(t, err)
+	require.Len(t, ranking, 1)
+	require.Equal(t, "dave", ranking[0].Nickname)
+
+	reverts, err := gitinsight.GetRevertStats(&gitinsight.CommitLogFilter{ExcludeReverts: true})
+	require.NoError(t, err)
+	counts := map[string][2]int{}
+	for _, item := range reverts {
+		counts[item.Nickname] = [2]int{item.Reverts, item.Reverted}
+	}
+	require.Equal(t, map[string][2]int{
+		"bob":   {1, 0},
+		"carol": {1, 1},
+		"alice": {0, 1},
+	}, counts)
+}
+
+func TestRefreshRevertCommits(t *testing.T) {
+	openTestDb(t)
+	config := testConfig()
+	config.CommitTypes = []string{"feat", "chore"}
+	fixture := newFixtureRepo(t)
+
+	cleanup := fixture.Commit("carol", "chore: cleanup", map[string]string{"main.go": "package main\n"})
+	fixture.Commit("carol", "revert: cleanup\n\nRefs: "+cleanup.String()[:7], map[string]string{"main.go": ""})
+	fixture.Commit("dave", "feat: ui", map[string]string{"ui.go": "package main\n"})
+	require.NoError(t, gitinsight.HandleBranchCommitLogsToDb(config, fixture.Path, "master"))
+
+	ranking, err := gitinsight.GetRanking(&gitinsight.CommitLogFilter{ExcludeReverts: true})
+	require.NoError(t, err)
+	require.Len(t, ranking, 2)
+
+	// revert is a valid type now, the abbreviated hash is expanded against
+	// the stored commits
+	config.CommitTypes = []string{"feat", "chore", "revert"}
+	require.NoError(t, gitinsight.RefreshRepoCommitMessages(config, fixture.Path))
+
+	commitLogs, err := gitinsight.GetCommitLogs(&gitinsight.CommitLogFilter{MessageType: "revert", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, commitLogs, 1)
+	require.True(t, commitLogs[0].IsRevert)
+	require.Equal(t, cleanup.String(), commitLogs[0].RevertsHash)
+
+	ranking, err = gitinsight.GetRanking(&gitinsight.CommitLogFilter{ExcludeReverts: true})
+	require.NoError(t, err)
+	require.Len(t, ranking, 1)
+	require.Equal(t, "dave", ranking[0].Nickname)
+
+	updated, err := gitinsight.RefreshCommitMessages(gitinsight.GetRepoUrl(config, fixture.Path), config.CommitTypes)
+	require.NoError(t, err)
+	require.Zero(t, updated)
+}
@@ -0,0 +1,72 @@
+package gitinsight_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetShallowClone(t *testing.T) {
+	config := testConfig()
+	require.Nil(t, gitinsight.GetShallowClone(config))
+
+	config.Cache.Shallow = true
+	shallow := gitinsight.GetShallowClone(config)
+	require.NotNil(t, shallow)
+	require.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), shallow.Since.UTC())
+	require.Equal(t, 200, shallow.Depth)
+
+	config.Since = ""
+	require.Nil(t, gitinsight.GetShallowClone(config))
+}
+
+func TestIsHistorySince(t *testing.T) {
+	fixture := newFixtureRepo(t)
+	fixture.Commit("alice", "feat: init", map[string]string{"main.go": "package main\n"})
+	boundary := fixture.Commit("alice", "feat: more", map[string]string{"more.go": "package main\n"})
+	boundaryWhen := fixture.When
+	fixture.Commit("alice", "feat: last", map[string]string{"last.go": "package main\n"})
+
+	path := filepath.Join(t.TempDir(), "mirror")
+	repo, err := gitinsight.CloneOrUpdateRepo(fixture.Path, path, nil, nil)
+	require.NoError(t, err)
+	covered, err := gitinsight.IsHistorySince(repo, boundaryWhen)
+	require.NoError(t, err)
+	require.True(t, covered)
+
+	// the local transport ignores the depth of a fetch, cut the history by hand
+	require.NoError(t, repo.Storer.SetShallow([]plumbing.Hash{boundary}))
+	covered, err = gitinsight.IsHistorySince(repo, boundaryWhen.Add(time.Minute))
+	require.NoError(t, err)
+	require.True(t, covered)
+	covered, err = gitinsight.IsHistorySince(repo, boundaryWhen)
+	require.NoError(t, err)
+	require.False(t, covered)
+}
+
+func TestDeepenShallowMirror(t *testing.T) {
+	fixture := newFixtureRepo(t)
+	fixture.Commit("alice", "feat: init", map[string]string{"main.go": "package main\n"})
+	boundary := fixture.Commit("alice", "feat: more", map[string]string{"more.go": "package main\n"})
+
+	path := filepath.Join(t.TempDir(), "mirror")
+	repo, err := gitinsight.CloneOrUpdateRepo(fixture.Path, path, nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, repo.Storer.SetShallow([]plumbing.Hash{boundary}))
+
+	// since moved back past the boundary, deepening stops once a fetch no
+	// longer moves it
+	shallow := &gitinsight.ShallowClone{Since: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), Depth: 1}
+	repo, err = gitinsight.CloneOrUpdateRepo(fixture.Path, path, nil, shallow)
+	require.NoError(t, err)
+	ref, err := gitinsight.GetBranchRef(repo, "master")
+	require.NoError(t, err)
+	require.Equal(t, boundary, ref.Hash())
+
+	_, err = gitinsight.CloneOrUpdateRepo(fixture.Path, path, nil, nil)
+	require.NoError(t, err)
+}
@@ -0,0 +1,195 @@
+package gitinsight_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/robotism/gitinsight/gitinsight"
+	"github.com/robotism/gitinsight/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscoverGithubRepos(t *testing.T) {
+	// the api caps the page size below the requested one and links the
+	// next page
+	firstPage := make([]map[string]any, 0)
+	for i := 0; i < 30; i++ {
+		firstPage = append(firstPage, map[string]any{
+			"name":      fmt.Sprintf("svc-%02d", i),
+			"clone_url": fmt.Sprintf("https://github.com/acme/svc-%02d.git", i),
+			"ssh_url":   fmt.Sprintf("git@github.com:acme/svc-%02d.git", i),
+		})
+	}
+	lastPage := []map[string]any{
+		{"name": "api", "clone_url": "https://github.com/acme/api.git", "ssh_url": "git@github.com:acme/api.git"},
+		{"name": "old", "clone_url": "https://github.com/acme/old.git", "ssh_url": "git@github.com:acme/old.git", "archived": true},
+		{"name": "upstream", "clone_url": "https://github.com/acme/upstream.git", "ssh_url": "git@github.com:acme/upstream.git", "fork": true},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/orgs/acme/repos", r.URL.Path)
+		require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		if r.URL.Query().Get("page") == "1" {
+			w.Header().Set("Link", `<http://`+r.Host+`/orgs/acme/repos?page=2&per_page=50>; rel="next", <http://`+r.Host+`/orgs/acme/repos?page=2&per_page=50>; rel="last"`)
+			json.NewEncoder(w).Encode(firstPage)
+		} else {
+			w.Header().Set("Link", `<http://`+r.Host+`/orgs/acme/repos?page=1&per_page=50>; rel="prev", <http://`+r.Host+`/orgs/acme/repos?page=1&per_page=50>; rel="first"`)
+			json.NewEncoder(w).Encode(lastPage)
+		}
+	}))
+	defer server.Close()
+
+	source := &gitinsight.Source{Type: "github", Api: server.URL, Org: "acme", Token: "secret", Exclude: []string{"svc-*"}}
+	repos, err := gitinsight.DiscoverRepos(source)
+	require.NoError(t, err)
+	require.Len(t, repos, 1)
+	require.Equal(t, "https://github.com/acme/api.git", repos[0].Url)
+	require.Equal(t, "x-access-token", repos[0].User)
+	require.Equal(t, "secret", repos[0].Password)
+
+	source.Include = []string{"svc-0*", "old", "upstream"}
+	source.Exclude = nil
+	source.Archived = true
+	source.SSH = true
+	repos, err = gitinsight.DiscoverRepos(source)
+	require.NoError(t, err)
+	require.Len(t, repos, 11)
+	require.Equal(t, "git@github.com:acme/svc-00.git", repos[0].Url)
+	require.Equal(t, "", repos[0].Password)
+}
+
+func TestDiscoverGitlabProjects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v4/groups/acme%2Fplatform/projects", r.URL.EscapedPath())
+		require.Equal(t, "true", r.URL.Query().Get("include_subgroups"))
+		require.Equal(t, "secret", r.Header.Get("PRIVATE-TOKEN"))
+		// gitlab names the next page, empty on the last one
+		if r.URL.Query().Get("page") == "1" {
+			w.Header().Set("X-Next-Page", "2")
+			json.NewEncoder(w).Encode([]map[string]any{
+				{"path": "api", "path_with_namespace": "acme/platform/api", "http_url_to_repo": "https://gitlab.corp/acme/platform/api.git"},
+				{"path": "web", "path_with_namespace": "acme/platform/frontend/web", "http_url_to_repo": "https://gitlab.corp/acme/platform/frontend/web.git"},
+			})
+			return
+		}
+		require.Equal(t, "2", r.URL.Query().Get("page"))
+		w.Header().Set("X-Next-Page", "")
+		json.NewEncoder(w).Encode([]map[string]any{
+			{"path": "api", "path_with_namespace": "acme/platform/forks/api", "http_url_to_repo": "https://gitlab.corp/acme/platform/forks/api.git",
+				"forked_from_project": map[string]any{"id": 1}},
+		})
+	}))
+	defer server.Close()
+
+	source := &gitinsight.Source{Type: "gitlab", Api: server.URL + "/api/v4/", Org: "acme/platform", Token: "secret", Include: []string{"frontend/**"}}
+	repos, err := gitinsight.DiscoverRepos(source)
+	require.NoError(t, err)
+	require.Len(t, repos, 1)
+	require.Equal(t, "https://gitlab.corp/acme/platform/frontend/web.git", repos[0].Url)
+	require.Equal(t, "oauth2", repos[0].User)
+
+	listed, err := gitinsight.ListSourceRepos(source)
+	require.NoError(t, err)
+	require.Equal(t, "forks/api", listed[2].Path)
+	require.True(t, listed[2].Fork)
+}
+
+func TestSyncRepoSources(t *testing.T) {
+	fixture := newFixtureRepo(t)
+	fixture.Commit("alice", "feat: init", map[string]string{"main.go": "package main\n"})
+	other := newFixtureRepo(t)
+	other.Commit("bob", "feat: init", map[string]string{"main.go": "package main\n"})
+
+	failing := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v1/orgs/acme/repos", r.URL.Path)
+		require.Equal(t, "token secret", r.Header.Get("Authorization"))
+		if failing {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		// without paging headers the pages are listed until an empty one
+		if r.URL.Query().Get("page") != "1" {
+			json.NewEncoder(w).Encode([]map[string]any{})
+			return
+		}
+		json.NewEncoder(w).Encode([]map[string]any{
+			{"name": "fixture", "clone_url": fixture.Path},
+			{"name": "other", "clone_url": other.Path},
+		})
+	}))
+	defer server.Close()
+
+	config := testConfig()
+	config.Parallel = false
+	config.Cache.Path = t.TempDir()
+	config.Repos = []gitinsight.Repo{{Url: other.Path}}
+	config.Sources = []gitinsight.Source{{Type: "gitea", Api: server.URL + "/api/v1", Org: "acme", Token: "secret"}}
+
+	repos, err := gitinsight.SyncRepo(config)
+	require.NoError(t, err)
+	require.Len(t, repos, 2)
+	require.Contains(t, repos, fixture.Path)
+	require.Contains(t, repos, other.Path)
+	require.Len(t, config.Repos, 1)
+	discovered := gitinsight.GetRepos(config)
+	require.Len(t, discovered, 2)
+	require.Equal(t, other.Path, discovered[0].Url)
+	require.True(t, gitinsight.IsConfiguredRepo(config, fixture.Path))
+
+	// the repositories discovered before are kept while the api fails
+	failing = true
+	repos, err = gitinsight.SyncRepo(config)
+	require.NoError(t, err)
+	require.Len(t, repos, 2)
+	require.Len(t, gitinsight.GetRepos(config), 2)
+}
+
+func TestChangelogOfDiscoveredRepo(t *testing.T) {
+	fixture := newFixtureRepo(t)
+	first := fixture.Commit("alice", "feat: init", map[string]string{"main.go": "package main\n"})
+	_, err := fixture.Repo.CreateTag("v1.0.0", first, nil)
+	require.NoError(t, err)
+	fixture.Commit("bob", "fix: exit code", map[string]string{"main.go": "package main\n\nfunc main() {}\n"})
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") != "1" {
+			json.NewEncoder(w).Encode([]map[string]any{})
+			return
+		}
+		json.NewEncoder(w).Encode([]map[string]any{{"name": "fixture", "clone_url": fixture.Path}})
+	}))
+	defer api.Close()
+
+	config := &server.AppConfig{Insight: *testConfig()}
+	config.Insight.Cache.Path = t.TempDir()
+	config.Insight.Sources = []gitinsight.Source{{Type: "gitea", Api: api.URL, Org: "changelog"}}
+	server.SetConfig(config)
+	t.Cleanup(func() {
+		server.SetConfig(nil)
+	})
+
+	// the sync refreshes its own copy of the config, as the cron does
+	insight := config.Insight
+	_, err = gitinsight.SyncRepo(&insight)
+	require.NoError(t, err)
+
+	g := gin.New()
+	server.RegisterRoute(g.Group("/v1"))
+	query := url.Values{"repo": {fixture.Path}, "from": {"v1.0.0"}}
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/changelog?"+query.Encode(), nil))
+	var body struct {
+		Code int                  `json:"code"`
+		Data gitinsight.Changelog `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Equal(t, 200, body.Code, w.Body.String())
+	require.Equal(t, "v1.0.0", body.Data.From)
+	require.Len(t, body.Data.Sections, 1)
+	require.Equal(t, "fix", body.Data.Sections[0].Type)
+}
@@ -1,60 +1,54 @@
 package gitinsight_test
 
 import (
-	"log"
-	"os"
-	"path/filepath"
-	"strings"
 	"testing"
 
 	"github.com/go-git/go-git/v6"
-	"github.com/goccy/go-yaml"
 	"github.com/robotism/gitinsight/gitinsight"
-	"github.com/robotism/gitinsight/server"
 	"github.com/stretchr/testify/require"
 )
 
 func TestIsRepoUpToDate(t *testing.T) {
-	t.Parallel() // 并发安全起点
-	workDir := "../cmd/gitinsight"
-	configFile := filepath.Join(workDir, "config.yaml")
-
-	configContent, err := os.ReadFile(configFile)
-	require.NoError(t, err, "read config failed")
-
-	var config server.AppConfig
-	require.NoError(t, yaml.Unmarshal(configContent, &config), "unmarshal failed")
-
-	require.NoError(t, gitinsight.OpenDb(config.Server.Database.Type, config.Server.Database.Dsn))
-	require.NoError(t, gitinsight.InitDb())
-
-	for _, repoCfg := range config.Insight.Repos {
-		repoUrl := repoCfg.Url
-		repoName := strings.TrimSuffix(filepath.Base(repoUrl), ".git")
-		repoPath := filepath.Join(workDir, config.Insight.Cache.Path, repoName)
-
-		repo, err := git.PlainOpen(repoPath)
-		require.NoError(t, err)
-
-		branches, err := gitinsight.GetBranches(repo)
+	openTestDb(t)
+	fixture := newFixtureRepo(t)
+	initial := fixture.Commit("alice", "feat: init", map[string]string{"main.go": "package main\n"})
+	fixture.Commit("bob", "fix: typo", map[string]string{"main.go": "package main\n\nfunc main() {}\n"})
+	fixture.Branch("feature", initial)
+
+	config := testConfig()
+	config.Parallel = false
+	config.Repos = []gitinsight.Repo{{Url: "file://" + fixture.Path}}
+	repoPath := gitinsight.GetRepoPath(config, config.Repos[0].Url)
+	require.Equal(t, fixture.Path, repoPath)
+	gitinsight.HandleCommitLogs(config)
+
+	repo, err := git.PlainOpen(repoPath)
+	require.NoError(t, err)
+	branches, err := gitinsight.GetBranches(repo)
+	require.NoError(t, err)
+	require.Equal(t, []string{"feature", "master"}, branches)
+
+	filter := gitinsight.CheckUpTodateFilter{
+		RepoUrl:   config.Repos[0].Url,
+		SinceUTC:  config.Since,
+		SinceTime: config.SinceTime(),
+	}
+	for _, branch := range branches {
+		filter.BranchName = branch
+		isUpToDate, err := gitinsight.IsRepoUpToDate(repoPath, filter)
 		require.NoError(t, err)
-
-		for _, branch := range branches {
-			isUp, err := gitinsight.IsRepoUpToDate(repoPath, gitinsight.CheckUpTodateFilter{
-				RepoUrl:    repoUrl,
-				BranchName: branch,
-				SinceUTC:   config.Insight.Since,
-				SinceTime:  config.Insight.SinceTime(),
-			})
-			if err != nil {
-				log.Printf("check failed: %s %s: %v\n", repoUrl, branch, err)
-			}
-			if !isUp {
-				log.Printf("repo not up to date: %s %s\n", repoUrl, branch)
-			} else {
-				log.Printf("✅ %s %s up to date!\n", repoPath, branch)
-			}
-		}
+		require.True(t, isUpToDate, branch)
 	}
 
+	// a new commit on master is analyzed in place by the next sync
+	fixture.Commit("alice", "feat: more", map[string]string{"more.go": "package main\n"})
+	filter.BranchName = "master"
+	isUpToDate, err := gitinsight.IsRepoUpToDate(repoPath, filter)
+	require.NoError(t, err)
+	require.False(t, isUpToDate)
+
+	gitinsight.HandleCommitLogs(config)
+	isUpToDate, err = gitinsight.IsRepoUpToDate(repoPath, filter)
+	require.NoError(t, err)
+	require.True(t, isUpToDate)
 }